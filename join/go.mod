@@ -0,0 +1,3 @@
+module github.com/magpierre/operators/join
+
+go 1.23.2
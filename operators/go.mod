@@ -0,0 +1,11 @@
+module github.com/magpierre/operators/operators
+
+go 1.23.2
+
+require (
+	github.com/chzyer/readline v1.5.1
+	go.opentelemetry.io/otel/trace v1.35.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require go.opentelemetry.io/otel v1.35.0 // indirect
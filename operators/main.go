@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/magpierre/operators/operators/ops"
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// commands are the subcommands of operators that are not operators.
+var commands = map[string]func(args []string){
+	"run":      runCommand,
+	"shell":    shellCommand,
+	"install":  installCommand,
+	"timeline": timelineCommand,
+	"replay":   replayCommand,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s command [flags] [args]\n\nOperators:\n", os.Args[0])
+	for _, op := range ops.All() {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", op.Name(), op.Description())
+	}
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "run", "run a pipeline spec")
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "shell", "explore a CSV or gob file interactively")
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "install", "create a symlink per operator in a directory")
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "timeline", "show the stages of pipelines from their metrics")
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "replay", "print the pipeline that produced a gob file")
+	fmt.Fprintf(os.Stderr, "\nRun %s command -h for the flags of a command.\n", os.Args[0])
+}
+
+// installCommand creates a symlink named after every operator pointing to
+// this binary, which then runs the operator like its own binary.
+func installCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s install directory\n", os.Args[0])
+		os.Exit(2)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, op := range ops.All() {
+		link := filepath.Join(args[0], op.Name())
+		if fi, err := os.Lstat(link); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			os.Remove(link)
+		}
+		if err := os.Symlink(self, link); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func main() {
+	// Invoked through a symlink named after an operator, busybox style.
+	if op, found := ops.Lookup(filepath.Base(os.Args[0])); found {
+		if err := lib.RunOperator(op, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name, args := os.Args[1], os.Args[2:]
+	if op, found := ops.Lookup(name); found {
+		if err := lib.RunOperator(op, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	command, found := commands[name]
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	command(args)
+}
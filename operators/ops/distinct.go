@@ -0,0 +1,45 @@
+package ops
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Distinct keeps the distinct rows, or counts the values of one column.
+type Distinct struct {
+	cols   string
+	counts bool
+}
+
+func (*Distinct) Name() string { return "distinct" }
+
+func (*Distinct) Description() string { return "keep distinct rows or count values" }
+
+func (o *Distinct) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.cols, "cols", "", "Comma separated list of columns, all columns if empty")
+	fs.BoolVar(&o.counts, "counts", false, "Emit each value of the single column in -cols with its count, most frequent first")
+}
+
+func (o *Distinct) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	columns := splitList(o.cols)
+	if o.counts && len(columns) != 1 {
+		return nil, errors.New("-counts needs exactly one column in -cols")
+	}
+	b, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	var df *lib.DataFrame
+	if o.counts {
+		df, err = b.ValueCounts(columns[0])
+	} else {
+		df, err = b.DistinctRows(columns...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("While performing distinct: %w", err)
+	}
+	return df, nil
+}
@@ -0,0 +1,33 @@
+package ops
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDistinct(t *testing.T) {
+	input := frame([]string{"zone", "id"}, []string{"a", "1"}, []string{"b", "2"}, []string{"a", "3"}, []string{"a", "4"})
+
+	df, err := run(t, &Distinct{}, input, "-cols", "zone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := column(t, df, "zone"), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("zones = %v, want %v", got, want)
+	}
+
+	df, err = run(t, &Distinct{}, input, "-cols", "zone", "-counts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := column(t, df, "count"), []string{"3", "1"}; !slices.Equal(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+
+	for _, args := range [][]string{{"-counts"}, {"-counts", "-cols", "zone,id"}} {
+		if _, err := run(t, &Distinct{}, input, args...); err == nil || !strings.Contains(err.Error(), "exactly one column") {
+			t.Errorf("distinct %v: err = %v, want exactly one column", args, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package ops
+
+import (
+	"flag"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Dump prints its input to stderr and passes it on unchanged.
+type Dump struct{}
+
+func (*Dump) Name() string { return "dump" }
+
+func (*Dump) Description() string { return "print the input to stderr and pass it on" }
+
+func (*Dump) SetFlags(fs *flag.FlagSet) {}
+
+// Unbuffered makes dump print its input on every run.
+func (*Dump) Unbuffered() {}
+
+func (*Dump) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return df, lib.WriteTable(env.Stderr, df)
+}
+
+// RunStream prints every batch of a stream as it arrives.
+func (*Dump) RunStream(env *lib.OperatorEnv, emit func(*lib.DataFrame) error) error {
+	return eachFrame(env, emit, func(df *lib.DataFrame) (*lib.DataFrame, error) {
+		return df, lib.WriteTable(env.Stderr, df)
+	})
+}
@@ -0,0 +1,58 @@
+package ops
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// run runs op with args on input, a nil input is an empty stdin.
+func run(t *testing.T, op lib.Operator, input *lib.DataFrame, args ...string) (*lib.DataFrame, error) {
+	t.Helper()
+	fs := flag.NewFlagSet(op.Name(), flag.ContinueOnError)
+	op.SetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	var stdin bytes.Buffer
+	if input != nil {
+		if err := lib.WriteFrame(&stdin, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return op.Run(&lib.OperatorEnv{Args: fs.Args(), Stdin: &stdin, Stdout: io.Discard, Stderr: io.Discard})
+}
+
+// frame returns a DataFrame of string columns.
+func frame(header []string, records ...[]string) *lib.DataFrame {
+	return lib.NewDataFrameFromRecords(header, records)
+}
+
+// writeFrame writes df to name in dir and returns its path.
+func writeFrame(t *testing.T, dir, name string, df *lib.DataFrame) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := lib.WriteFrameFile(path, df); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// column returns the values of a column formatted with fmt.Sprint.
+func column(t *testing.T, df *lib.DataFrame, name string) []string {
+	t.Helper()
+	x := df.GetFieldNumber(name)
+	if x < 0 {
+		t.Fatalf("no column %s in %v", name, df.GetFieldNames())
+	}
+	values := make([]string, 0)
+	for _, v := range df.GetColumnByIndex(x) {
+		values = append(values, fmt.Sprint(v))
+	}
+	return values
+}
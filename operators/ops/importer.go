@@ -0,0 +1,233 @@
+package ops
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Importer reads a CSV file, all columns are strings unless a schema is given.
+// With a state file it imports the rows appended to its source files since
+// the last run, the sources are the arguments or the input file. With
+// -follow it tails its input and emits a frame for every batch of new rows.
+// Records that can not be parsed or cast to the schema fail the import, or
+// with -on-error they are skipped or written to a quarantine file, and how
+// many were rejected is reported on stderr.
+type Importer struct {
+	schema        string
+	schemaFile    string
+	state         string
+	follow        bool
+	batchRows     int
+	batchInterval time.Duration
+	filterCmd     string
+	firstN        int
+	normalize     bool
+	onError       string
+	fields        int
+	lazyQuotes    bool
+	quarantine    string
+	// imported is the state of an incremental import, saved by Commit
+	// once the rows are written.
+	imported *lib.ImportState
+}
+
+// followPoll is how often a followed file at its end is checked for new rows.
+const followPoll = 250 * time.Millisecond
+
+func (*Importer) Name() string { return "importer" }
+
+func (*Importer) Description() string { return "import a CSV file" }
+
+func (o *Importer) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.schema, "schema", "", "The schema of the input file, e.g. \"amount:decimal(10,2),id:int\"")
+	fs.StringVar(&o.schemaFile, "schemaFile", "", "The path to a file that contains the schema")
+	fs.StringVar(&o.state, "state", "", "State file of an incremental import, only the rows added to the source files since the last run are read")
+	fs.BoolVar(&o.follow, "follow", false, "Tail the input like tail -F and emit the new rows in batches")
+	fs.IntVar(&o.batchRows, "batch-rows", 1000, "Most rows in a batch of -follow")
+	fs.DurationVar(&o.batchInterval, "batch-interval", time.Second, "Longest wait before a batch of -follow is emitted")
+	fs.StringVar(&o.filterCmd, "filter", "", "Filter Command")
+	fs.IntVar(&o.firstN, "first", 0, "Read first N lines")
+	fs.BoolVar(&o.normalize, "normalize-headers", false, "Rename the columns to snake_case without punctuation and repeated names, before the schema is applied")
+	fs.StringVar(&o.onError, "on-error", "fail", "What to do with a record that can not be parsed: fail, skip or quarantine")
+	fs.IntVar(&o.fields, "fields-per-record", 0, "Fields of every record, 0 for as many as the header, negative to pad short records with empty fields")
+	fs.BoolVar(&o.lazyQuotes, "lazy-quotes", false, "Allow quotes in unquoted fields and single quotes in quoted fields")
+	fs.StringVar(&o.quarantine, "quarantine", "", "File the records rejected by -on-error=quarantine are written to with their line and error, a frame stream when it ends with .gob")
+}
+
+// types returns the column types given with --schema or --schemaFile, for
+// example "Fare_amount:decimal(10,2),Vendor_id:int", nil when there are none.
+func (o *Importer) types() ([]lib.Field, error) {
+	spec := o.schema
+	if o.schemaFile != "" {
+		b, err := os.ReadFile(o.schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		spec = string(b)
+	}
+	if spec == "" {
+		return nil, nil
+	}
+	return lib.ParseSchemaSpec(spec)
+}
+
+// rejects counts the records left out by -on-error and quarantines them.
+type rejects struct {
+	count      int
+	quarantine *lib.Quarantine
+}
+
+func (r *rejects) add(record lib.RejectedRecord) error {
+	r.count++
+	if r.quarantine != nil {
+		return r.quarantine.Add(record)
+	}
+	return nil
+}
+
+// csvOptions returns how the records are parsed and cast and what is
+// rejected, which must be closed.
+func (o *Importer) csvOptions() (lib.CSVOptions, *rejects, error) {
+	opts := lib.CSVOptions{FieldsPerRecord: o.fields, LazyQuotes: o.lazyQuotes, NormalizeHeader: o.normalize}
+	types, err := o.types()
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.Schema = types
+	r := &rejects{}
+	switch o.onError {
+	case "fail":
+		if o.quarantine != "" {
+			return opts, nil, errors.New("-quarantine needs -on-error=quarantine")
+		}
+		return opts, r, nil
+	case "skip":
+		if o.quarantine != "" {
+			return opts, nil, errors.New("-quarantine needs -on-error=quarantine")
+		}
+	case "quarantine":
+		if o.quarantine == "" {
+			return opts, nil, errors.New("-on-error=quarantine needs a -quarantine file")
+		}
+		q, err := lib.CreateQuarantine(o.quarantine)
+		if err != nil {
+			return opts, nil, err
+		}
+		r.quarantine = q
+	default:
+		return opts, nil, fmt.Errorf("unknown -on-error %q, use fail, skip or quarantine", o.onError)
+	}
+	opts.Reject = r.add
+	return opts, r, nil
+}
+
+// close closes the quarantine and reports the rejected records, if any.
+func (o *Importer) close(r *rejects, stderr io.Writer) error {
+	switch {
+	case r.count == 0:
+	case r.quarantine == nil:
+		fmt.Fprintf(stderr, "importer: bad records skipped: %d\n", r.count)
+	default:
+		fmt.Fprintf(stderr, "importer: bad records quarantined in %s: %d\n", o.quarantine, r.count)
+	}
+	if r.quarantine == nil {
+		return nil
+	}
+	return r.quarantine.Close()
+}
+
+func (o *Importer) Run(env *lib.OperatorEnv) (d *lib.DataFrame, err error) {
+	if o.follow {
+		return nil, errors.New("-follow streams its output and can not be checkpointed")
+	}
+	opts, rejected, err := o.csvOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = errors.Join(err, o.close(rejected, env.Stderr))
+	}()
+	var state *lib.ImportState
+	if o.state != "" {
+		sources := env.Args
+		if len(sources) == 0 && env.Input != "" && env.Input != "-" {
+			sources = []string{env.Input}
+		}
+		if len(sources) == 0 {
+			return nil, errors.New("an incremental import needs source files")
+		}
+		if state, err = lib.LoadImportState(o.state); err != nil {
+			return nil, err
+		}
+		if d, err = state.ImportNew(sources, opts); err != nil {
+			return nil, err
+		}
+	} else {
+		source := env.Input
+		if source == "" || source == "-" {
+			source = "stdin"
+		}
+		if d, err = lib.ReadCSV(env.Stdin, source, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	d.IndexRows()
+	o.imported = state
+	return d, nil
+}
+
+// Commit saves the state of an incremental import after its rows were
+// written, rows that were not are imported again by the next run.
+func (o *Importer) Commit() error {
+	if o.imported == nil {
+		return nil
+	}
+	state := o.imported
+	o.imported = nil
+	return state.Save(o.state)
+}
+
+// RunStream follows the input with -follow, otherwise it imports it at once.
+func (o *Importer) RunStream(env *lib.OperatorEnv, emit func(*lib.DataFrame) error) (err error) {
+	if !o.follow {
+		df, err := o.Run(env)
+		if err != nil {
+			return err
+		}
+		return emit(df)
+	}
+	if o.state != "" {
+		return errors.New("-follow and -state can not be used together")
+	}
+	if o.batchRows < 1 || o.batchInterval <= 0 {
+		return errors.New("-batch-rows and -batch-interval must be positive")
+	}
+	path := env.Input
+	if path == "" {
+		path = "-"
+	}
+	csvOpts, rejected, err := o.csvOptions()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, o.close(rejected, env.Stderr))
+	}()
+	opts := lib.FollowOptions{BatchRows: o.batchRows, Interval: o.batchInterval, Poll: followPoll, CSV: csvOpts}
+	return lib.FollowCSV(env.Context(), path, opts, func(d *lib.DataFrame) error {
+		if rejected.quarantine != nil {
+			if err := rejected.quarantine.Flush(); err != nil {
+				return err
+			}
+		}
+		d.IndexRows()
+		return emit(d)
+	})
+}
@@ -0,0 +1,146 @@
+package ops
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+func TestImporterState(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log.csv")
+	state := filepath.Join(dir, "state.json")
+	os.WriteFile(log, []byte("id,event\n1,start\n"), 0o644)
+
+	// importIDs runs an incremental import of log, the rows are written when
+	// emit succeeds and the state is then committed as by RunOperator
+	importIDs := func(emitErr error) ([]string, *lib.DataFrame, error) {
+		t.Helper()
+		op := &Importer{}
+		fs := flag.NewFlagSet("importer", flag.ContinueOnError)
+		op.SetFlags(fs)
+		fs.Parse([]string{"-state", state, "-schema", "id:int", log})
+		var imported *lib.DataFrame
+		err := op.RunStream(&lib.OperatorEnv{Args: fs.Args(), Stderr: io.Discard}, func(df *lib.DataFrame) error {
+			imported = df
+			return emitErr
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return column(t, imported, "id"), imported, op.Commit()
+	}
+
+	got, _, err := importIDs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"1"}) {
+		t.Errorf("first import = %v, want [1]", got)
+	}
+
+	file, _ := os.OpenFile(log, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString("2,run\n3,stop\n")
+	file.Close()
+	// rows whose output failed are imported again by the next run
+	broken := errors.New("broken pipe")
+	if _, _, err := importIDs(broken); !errors.Is(err, broken) {
+		t.Fatalf("err = %v, want the error of emit", err)
+	}
+	got, df, err := importIDs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"2", "3"}) {
+		t.Errorf("second import = %v, want [2 3]", got)
+	}
+	if got, want := df.Metadata.Source, log+":17-30"; got != want {
+		t.Errorf("source = %s, want %s", got, want)
+	}
+	if got := df.GetFieldTypes(); !slices.Equal(got, []string{"int", "string"}) {
+		t.Errorf("types = %v", got)
+	}
+	if got, _, err := importIDs(nil); err != nil || len(got) != 0 {
+		t.Errorf("third import = %v, %v, want no rows", got, err)
+	}
+
+	if _, err := run(t, &Importer{}, nil, "-state", state); err == nil || !strings.Contains(err.Error(), "needs source files") {
+		t.Errorf("err = %v, want needs source files", err)
+	}
+}
+
+func TestImporterFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.csv")
+	os.WriteFile(path, []byte("id,event\n1,start\n2,run\n3,stop\n"), 0o644)
+
+	op := &Importer{}
+	fs := flag.NewFlagSet("importer", flag.ContinueOnError)
+	op.SetFlags(fs)
+	fs.Parse([]string{"-follow", "-batch-rows", "2", "-batch-interval", "20ms", "-schema", "id:int"})
+	env := &lib.OperatorEnv{OperatorOptions: lib.OperatorOptions{Input: path}, Stderr: io.Discard}
+	if _, err := op.Run(env); err == nil {
+		t.Error("-follow ran as a checkpointed import")
+	}
+
+	stop := errors.New("stop")
+	batches := make([][]string, 0)
+	start := time.Now()
+	err := op.RunStream(env, func(df *lib.DataFrame) error {
+		if got := df.GetFieldTypes(); !slices.Equal(got, []string{"int", "string"}) {
+			t.Errorf("types = %v", got)
+		}
+		batches = append(batches, column(t, df, "id"))
+		if len(batches) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("err = %v, want the error of emit", err)
+	}
+	if len(batches) != 2 || !slices.Equal(batches[0], []string{"1", "2"}) || !slices.Equal(batches[1], []string{"3"}) {
+		t.Errorf("batches = %v, want [[1 2] [3]]", batches)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the last batch waited %v", time.Since(start))
+	}
+
+	fs.Parse([]string{"-state", "state.json"})
+	if err := op.RunStream(env, nil); err == nil || !strings.Contains(err.Error(), "can not be used together") {
+		t.Errorf("err = %v, want -follow and -state to conflict", err)
+	}
+}
+
+func TestImporterNormalizeHeaders(t *testing.T) {
+	op := &Importer{}
+	fs := flag.NewFlagSet("importer", flag.ContinueOnError)
+	op.SetFlags(fs)
+	// the schema names the columns as they are after normalizing
+	fs.Parse([]string{"-normalize-headers", "-schema", "hours_per_week:int"})
+	stdin := strings.NewReader("age,native.country,Hours per week,age\n39,US,40,x\n50,DE,13,y\n")
+	df, err := op.Run(&lib.OperatorEnv{Stdin: stdin, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := df.GetFieldNames(), []string{"age", "native_country", "hours_per_week", "age_2"}; !slices.Equal(got, want) {
+		t.Errorf("columns %v, want %v", got, want)
+	}
+	if got := df.GetFieldTypes(); got[2] != "int" {
+		t.Errorf("types %v, want hours_per_week int", got)
+	}
+	where, err := df.Where("native_country == 'US'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := column(t, where, "age_2"); !slices.Equal(got, []string{"x"}) {
+		t.Errorf("age_2 where native_country == 'US' = %v, want [x]", got)
+	}
+}
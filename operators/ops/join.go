@@ -0,0 +1,39 @@
+package ops
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Join joins the input with the frame in -right on key columns.
+type Join struct {
+	right string
+	keys  string
+}
+
+func (*Join) Name() string { return "join" }
+
+func (*Join) Description() string { return "inner join the input with another frame" }
+
+func (o *Join) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.right, "right", "", "file with the right side")
+	fs.StringVar(&o.keys, "keys", "", "Comma separated list of key columns")
+}
+
+func (o *Join) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	if o.right == "" || o.keys == "" {
+		return nil, errors.New("join needs -right and -keys")
+	}
+	l, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	r, err := lib.ReadFrameFile(o.right)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", o.right, err)
+	}
+	return l.JoinContext(env.Context(), r, splitList(o.keys))
+}
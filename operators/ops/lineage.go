@@ -0,0 +1,37 @@
+package ops
+
+import (
+	"flag"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Lineage prints how its input was produced: its source and the operations
+// of its history, with the columns they computed.
+type Lineage struct {
+	dot bool
+}
+
+func (*Lineage) Name() string { return "lineage" }
+
+func (*Lineage) Description() string {
+	return "print the history and column lineage of the input"
+}
+
+func (o *Lineage) SetFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.dot, "dot", false, "Print a Graphviz DOT graph instead of text")
+}
+
+// Unbuffered makes lineage print on every run, it has no output to save.
+func (*Lineage) Unbuffered() {}
+
+func (o *Lineage) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	if o.dot {
+		return nil, lib.WriteHistoryDOT(env.Stdout, df.Metadata)
+	}
+	return nil, lib.WriteHistory(env.Stdout, df.Metadata)
+}
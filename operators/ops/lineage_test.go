@@ -0,0 +1,50 @@
+package ops
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+func TestLineage(t *testing.T) {
+	trips := frame([]string{"zone", "fare"}, []string{"a", "12"}, []string{"b", "3"})
+	trips.Metadata.Source = "trips.csv"
+	df, err := trips.Where("fare == '12'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := df.RenameColumn("fare", "amount"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"trips.csv\n1. Where(fare == '12')\n   2 rows in, at ", "2. RenameColumn(fare, amount)\n", "   amount <- fare\n"}},
+		{[]string{"-dot"}, []string{"digraph lineage {\n", `n1 [label="trips.csv", shape=note];`, `n3 [label="RenameColumn(fare, amount)\namount <- fare"];`}},
+	} {
+		var stdin, stdout bytes.Buffer
+		if err := lib.WriteFrame(&stdin, df); err != nil {
+			t.Fatal(err)
+		}
+		op := &Lineage{}
+		fs := flag.NewFlagSet("lineage", flag.ContinueOnError)
+		op.SetFlags(fs)
+		fs.Parse(tt.args)
+		// the history crosses the pipe and lineage has no output frame
+		out, err := op.Run(&lib.OperatorEnv{Stdin: &stdin, Stdout: &stdout, Stderr: io.Discard})
+		if err != nil || out != nil {
+			t.Fatalf("lineage %v = %v, %v", tt.args, out, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("lineage %v printed\n%s\nwithout %q", tt.args, stdout.String(), want)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package ops implements the operators of the operators binary. Every
+// operator is a lib.Operator and runs as a subcommand, as a symlink to the
+// binary named after it, or as its own binary.
+package ops
+
+import (
+	"strings"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// All returns the operators in the order they are listed in the usage.
+func All() []lib.Operator {
+	return []lib.Operator{
+		&Importer{},
+		&Dump{},
+		&Project{},
+		&Rename{},
+		&Where{},
+		&Transform{},
+		&Join{},
+		&Union{},
+		&Distinct{},
+		&SQL{},
+		&Window{},
+		&Lineage{},
+	}
+}
+
+// Lookup returns the operator with the given name.
+func Lookup(name string) (lib.Operator, bool) {
+	for _, op := range All() {
+		if op.Name() == name {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// splitList splits a comma separated flag value, empty gives no items.
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// eachFrame runs a row-wise operator on every frame of the input as it
+// arrives and emits its output.
+func eachFrame(env *lib.OperatorEnv, emit func(*lib.DataFrame) error, apply func(*lib.DataFrame) (*lib.DataFrame, error)) error {
+	return env.EachFrame(func(df *lib.DataFrame) error {
+		out, err := apply(df)
+		if err != nil {
+			return err
+		}
+		return emit(out)
+	})
+}
@@ -0,0 +1,34 @@
+package ops
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	names := make(map[string]bool)
+	for _, op := range All() {
+		if names[op.Name()] {
+			t.Errorf("two operators are named %s", op.Name())
+		}
+		names[op.Name()] = true
+		if op.Description() == "" {
+			t.Errorf("%s has no description", op.Name())
+		}
+		// the flags of an operator are declared once per run
+		op.SetFlags(flag.NewFlagSet(op.Name(), flag.ContinueOnError))
+
+		found, ok := Lookup(op.Name())
+		if !ok || found.Name() != op.Name() {
+			t.Errorf("Lookup(%s) = %v, %v", op.Name(), found, ok)
+		}
+	}
+	for _, name := range []string{"importer", "where", "union", "sql"} {
+		if !names[name] {
+			t.Errorf("no %s operator", name)
+		}
+	}
+	if op, ok := Lookup("operators"); ok {
+		t.Errorf("Lookup(operators) = %s, want none", op.Name())
+	}
+}
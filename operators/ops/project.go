@@ -0,0 +1,45 @@
+package ops
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Project keeps the given columns.
+type Project struct {
+	cols string
+}
+
+func (*Project) Name() string { return "project" }
+
+func (*Project) Description() string { return "keep a list of columns" }
+
+func (o *Project) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.cols, "cols", "", "Comma separated list of columns")
+}
+
+func (o *Project) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return o.apply(env.Context(), df)
+}
+
+// RunStream projects every batch of a stream as it arrives.
+func (o *Project) RunStream(env *lib.OperatorEnv, emit func(*lib.DataFrame) error) error {
+	return eachFrame(env, emit, func(df *lib.DataFrame) (*lib.DataFrame, error) {
+		return o.apply(env.Context(), df)
+	})
+}
+
+func (o *Project) apply(ctx context.Context, df *lib.DataFrame) (*lib.DataFrame, error) {
+	df, err := df.ProjectContext(ctx, splitList(o.cols)...)
+	if err != nil {
+		return nil, fmt.Errorf("While performing project: %w", err)
+	}
+	return df, nil
+}
@@ -0,0 +1,47 @@
+package ops
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Rename renames columns, with -normalize all of them to snake_case first.
+type Rename struct {
+	cols      string
+	normalize bool
+}
+
+func (*Rename) Name() string { return "rename" }
+
+func (*Rename) Description() string { return "rename columns" }
+
+func (o *Rename) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.cols, "cols", "", "Comma separated list of old:new column names")
+	fs.BoolVar(&o.normalize, "normalize", false, "Rename the columns to snake_case without punctuation and repeated names first")
+}
+
+func (o *Rename) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return o.apply(df)
+}
+
+// RunStream renames the columns of every batch of a stream as it arrives.
+func (o *Rename) RunStream(env *lib.OperatorEnv, emit func(*lib.DataFrame) error) error {
+	return eachFrame(env, emit, o.apply)
+}
+
+func (o *Rename) apply(df *lib.DataFrame) (*lib.DataFrame, error) {
+	if o.cols == "" && !o.normalize {
+		return nil, errors.New("rename needs -cols or -normalize")
+	}
+	if err := df.RenameColumns(o.normalize, splitList(o.cols)...); err != nil {
+		return nil, fmt.Errorf("While performing rename: %w", err)
+	}
+	return df, nil
+}
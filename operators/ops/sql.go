@@ -0,0 +1,60 @@
+package ops
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// SQL runs a SELECT query. Without arguments the input is the table in
+// FROM, otherwise the arguments name the tables as name=file.gob, or
+// file.gob for a table named after the file.
+type SQL struct {
+	query string
+}
+
+func (*SQL) Name() string { return "sql" }
+
+func (*SQL) Description() string { return "run a SELECT query on the input or on [name=]file.gob ..." }
+
+func (o *SQL) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.query, "q", "", "SELECT query, e.g. \"SELECT a, count(*) AS n FROM t GROUP BY a\"")
+}
+
+func (o *SQL) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	if o.query == "" {
+		return nil, errors.New("sql needs a query in -q")
+	}
+	if len(env.Args) == 0 {
+		df, err := env.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		return df.SQL(o.query)
+	}
+
+	tables := make(map[string]*lib.DataFrame)
+	for _, arg := range env.Args {
+		name, path, found := strings.Cut(arg, "=")
+		if !found {
+			path = arg
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		var df *lib.DataFrame
+		var err error
+		if path == "-" {
+			df, err = env.ReadFrame()
+		} else {
+			df, err = lib.ReadFrameFile(path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		tables[name] = df
+	}
+	return lib.SQL(o.query, tables)
+}
@@ -0,0 +1,46 @@
+package ops
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSQL(t *testing.T) {
+	dir := t.TempDir()
+	trips := frame([]string{"id", "cid"}, []string{"1", "1"}, []string{"2", "2"}, []string{"3", "1"})
+	countries := writeFrame(t, dir, "countries.gob", frame([]string{"cid", "country"}, []string{"1", "se"}, []string{"2", "no"}))
+
+	df, err := run(t, &SQL{}, trips, "-q", "SELECT id FROM trips WHERE cid = '1'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := column(t, df, "id"), []string{"1", "3"}; !slices.Equal(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+
+	// the file is the table named after it, stdin is named t
+	df, err = run(t, &SQL{}, trips, "-q", "SELECT country, COUNT(*) AS n FROM t JOIN countries USING (cid) GROUP BY country", "t=-", countries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := column(t, df, "country"), []string{"se", "no"}; !slices.Equal(got, want) {
+		t.Errorf("countries = %v, want %v", got, want)
+	}
+	if got, want := column(t, df, "n"), []string{"2", "1"}; !slices.Equal(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "sql needs a query in -q"},
+		{[]string{"-q", "SELECT * FROM trips", dir + "/missing.gob"}, "missing.gob"},
+		{[]string{"-q", "SELECT * FROM trips JOIN cities USING (cid)", "trips=-"}, "unknown table cities"},
+	} {
+		if _, err := run(t, &SQL{}, trips, tt.args...); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("sql %v: err = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ops
+
+import (
+	"context"
+	"flag"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Transform evaluates a statement and stores the result in a column.
+type Transform struct {
+	statement string
+}
+
+func (*Transform) Name() string { return "transform" }
+
+func (*Transform) Description() string { return "evaluate a statement into a column" }
+
+func (o *Transform) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.statement, "statement", "", "statement to evaluate")
+}
+
+func (o *Transform) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return o.apply(env.Context(), df)
+}
+
+// RunStream transforms every batch of a stream as it arrives when the
+// statement is row-wise, see lib.RowWise. Other statements may aggregate
+// whole columns, the stream is then read to its end and transformed as one
+// frame; use window to aggregate a stream as it goes.
+func (o *Transform) RunStream(env *lib.OperatorEnv, emit func(*lib.DataFrame) error) error {
+	if !lib.RowWise(o.statement) {
+		df, err := o.Run(env)
+		if err != nil {
+			return err
+		}
+		return emit(df)
+	}
+	return eachFrame(env, emit, func(df *lib.DataFrame) (*lib.DataFrame, error) {
+		return o.apply(env.Context(), df)
+	})
+}
+
+func (o *Transform) apply(ctx context.Context, df *lib.DataFrame) (*lib.DataFrame, error) {
+	return df, df.TransformContext(ctx, o.statement)
+}
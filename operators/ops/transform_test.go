@@ -0,0 +1,49 @@
+package ops
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"testing"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+func TestTransformStream(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string
+	}{
+		// row-wise statements transform every batch as it arrives
+		{"map(n, int(#) * 2)", "[[2 4] [6]]"},
+		// a sum needs the whole stream
+		{"sum(map(n, int(#)))", "[[6 6 6]]"},
+	}
+	for _, tt := range tests {
+		var stdin bytes.Buffer
+		frames := lib.NewFrameWriter(&stdin)
+		for _, batch := range [][][]string{{{"1"}, {"2"}}, {{"3"}}} {
+			if err := frames.Write(frame([]string{"n"}, batch...)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		frames.Close()
+
+		op := &Transform{}
+		fs := flag.NewFlagSet("transform", flag.ContinueOnError)
+		op.SetFlags(fs)
+		fs.Parse([]string{"-statement", tt.statement})
+		emitted := make([][]string, 0)
+		err := op.RunStream(&lib.OperatorEnv{Stdin: &stdin, Stderr: io.Discard}, func(df *lib.DataFrame) error {
+			emitted = append(emitted, column(t, df, "n"))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(emitted); got != tt.want {
+			t.Errorf("%s emitted %s, want %s", tt.statement, got, tt.want)
+		}
+	}
+}
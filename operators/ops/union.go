@@ -0,0 +1,118 @@
+package ops
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Union appends the rows of the files given as arguments.
+type Union struct {
+	byName       bool
+	allowMissing bool
+	promote      bool
+	sourceColumn string
+}
+
+func (*Union) Name() string { return "union" }
+
+func (*Union) Description() string {
+	return "append the rows of file.gob|pattern ..., \"-\" reads the input"
+}
+
+func (o *Union) SetFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.byName, "by-name", false, "Match columns by name instead of by position")
+	fs.BoolVar(&o.allowMissing, "allow-missing", true, "With -by-name, add columns missing on one side as nulls")
+	fs.BoolVar(&o.promote, "promote", true, "With -by-name, promote compatible column types")
+	fs.StringVar(&o.sourceColumn, "source-column", "", "Add a column with this name holding the input file of each row")
+}
+
+// expandInputs expands glob patterns such as part-*.gob that the shell did
+// not expand, in the order they are given.
+func expandInputs(args []string) ([]string, error) {
+	inputs := make([]string, 0)
+	for _, arg := range args {
+		if arg == "-" {
+			inputs = append(inputs, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no such file", arg)
+		}
+		inputs = append(inputs, matches...)
+	}
+	return inputs, nil
+}
+
+func (o *Union) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	if len(env.Args) == 0 {
+		return nil, errors.New("union needs at least one file.gob or pattern")
+	}
+	inputs, err := expandInputs(env.Args)
+	if err != nil {
+		return nil, err
+	}
+	opts := lib.UnionOptions{
+		AllowMissingColumns: o.allowMissing,
+		PromoteTypes:        o.promote,
+	}
+
+	// Inputs are decoded one at a time. By position their columns are
+	// appended to columns, by name the schema may change with every input
+	// so the frames are combined with UnionByName.
+	var result *lib.DataFrame
+	var columns []*lib.Data
+	for _, path := range inputs {
+		var df *lib.DataFrame
+		if path == "-" {
+			df, err = env.ReadFrame()
+		} else {
+			df, err = lib.ReadFrameFile(path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if o.sourceColumn != "" {
+			source := make(lib.Data, df.GetNumberOfRows())
+			for i := range source {
+				source[i] = path
+			}
+			if err := df.AddColumn(o.sourceColumn, source); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		switch {
+		case result == nil:
+			result = df
+			columns = make([]*lib.Data, df.GetNumberOfColumns())
+			for i := range columns {
+				column := append(lib.Data{}, df.GetColumnByIndex(i)...)
+				columns[i] = &column
+			}
+		case o.byName:
+			result, err = result.UnionByName(df, opts)
+			if err != nil {
+				return nil, fmt.Errorf("input %s does not match the inputs before it: %w", path, err)
+			}
+		default:
+			if err := result.Schema.MatchByPosition(&df.Schema); err != nil {
+				return nil, fmt.Errorf("input %s does not match %s: %w", path, inputs[0], err)
+			}
+			for i, column := range columns {
+				*column = append(*column, df.GetColumnByIndex(i)...)
+			}
+		}
+	}
+	if !o.byName {
+		result = lib.NewDataFrameWithArgs(result.Schema.Fields, columns)
+	}
+	return result, nil
+}
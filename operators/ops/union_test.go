@@ -0,0 +1,68 @@
+package ops
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnion(t *testing.T) {
+	dir := t.TempDir()
+	part1 := writeFrame(t, dir, "part-1.gob", frame([]string{"id", "name"}, []string{"1", "anna"}, []string{"2", "bob"}))
+	part2 := writeFrame(t, dir, "part-2.gob", frame([]string{"id", "name"}, []string{"3", "carl"}))
+	input := frame([]string{"id", "name"}, []string{"0", "stdin"})
+
+	df, err := run(t, &Union{}, input, "-source-column", "file", "-", filepath.Join(dir, "part-*.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := column(t, df, "name"), []string{"stdin", "anna", "bob", "carl"}; !slices.Equal(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+	if got, want := column(t, df, "file"), []string{"-", part1, part1, part2}; !slices.Equal(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestUnionByName(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFrame(t, dir, "a.gob", frame([]string{"id", "name"}, []string{"1", "anna"}))
+	b := writeFrame(t, dir, "b.gob", frame([]string{"name", "city"}, []string{"bob", "Oslo"}))
+
+	df, err := run(t, &Union{}, nil, "-by-name", a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := df.GetFieldNames(), []string{"id", "name", "city"}; !slices.Equal(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+	if got, want := column(t, df, "city"), []string{"<nil>", "Oslo"}; !slices.Equal(got, want) {
+		t.Errorf("cities = %v, want %v", got, want)
+	}
+
+	_, err = run(t, &Union{}, nil, "-by-name", "-allow-missing=false", a, b)
+	if err == nil || !strings.Contains(err.Error(), "input "+b+" does not match") || !strings.Contains(err.Error(), "+ city (string): missing in this frame") {
+		t.Errorf("err = %v, want a report on %s", err, b)
+	}
+}
+
+func TestUnionErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFrame(t, dir, "a.gob", frame([]string{"id", "name"}, []string{"1", "anna"}))
+	b := writeFrame(t, dir, "b.gob", frame([]string{"name", "id"}, []string{"bob", "2"}))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "at least one file"},
+		{[]string{a, b}, "input " + b + " does not match " + a},
+		{[]string{filepath.Join(dir, "missing-*.gob")}, "no such file"},
+	}
+	for _, tt := range tests {
+		if _, err := run(t, &Union{}, nil, tt.args...); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("union %v: err = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
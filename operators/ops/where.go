@@ -0,0 +1,40 @@
+package ops
+
+import (
+	"context"
+	"flag"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Where keeps the rows matching a condition.
+type Where struct {
+	cond string
+}
+
+func (*Where) Name() string { return "where" }
+
+func (*Where) Description() string { return "keep the rows matching a condition" }
+
+func (o *Where) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.cond, "cond", "", "condition of the rows to keep")
+}
+
+func (o *Where) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return o.apply(env.Context(), df)
+}
+
+// RunStream filters every batch of a stream as it arrives.
+func (o *Where) RunStream(env *lib.OperatorEnv, emit func(*lib.DataFrame) error) error {
+	return eachFrame(env, emit, func(df *lib.DataFrame) (*lib.DataFrame, error) {
+		return o.apply(env.Context(), df)
+	})
+}
+
+func (o *Where) apply(ctx context.Context, df *lib.DataFrame) (*lib.DataFrame, error) {
+	return df.WhereContext(ctx, o.cond)
+}
@@ -0,0 +1,39 @@
+package ops
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"slices"
+	"testing"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+func TestWhereStream(t *testing.T) {
+	var stdin bytes.Buffer
+	frames := lib.NewFrameWriter(&stdin)
+	for _, batch := range [][][]string{{{"1"}, {"12"}}, {{"15"}, {"3"}, {"20"}}} {
+		if err := frames.Write(frame([]string{"distance"}, batch...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	frames.Close()
+
+	op := &Where{}
+	fs := flag.NewFlagSet("where", flag.ContinueOnError)
+	op.SetFlags(fs)
+	fs.Parse([]string{"-cond", "int(distance) > 10"})
+	// every batch is filtered and emitted as it arrives
+	emitted := make([][]string, 0)
+	err := op.RunStream(&lib.OperatorEnv{Stdin: &stdin, Stderr: io.Discard}, func(df *lib.DataFrame) error {
+		emitted = append(emitted, column(t, df, "distance"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emitted) != 2 || !slices.Equal(emitted[0], []string{"12"}) || !slices.Equal(emitted[1], []string{"15", "20"}) {
+		t.Errorf("emitted %v, want [[12] [15 20]]", emitted)
+	}
+}
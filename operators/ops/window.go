@@ -0,0 +1,120 @@
+package ops
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// Window aggregates a stream by windows of event time and emits a frame for
+// every window as it closes.
+type Window struct {
+	kind       string
+	column     string
+	size       time.Duration
+	slide      time.Duration
+	gap        time.Duration
+	lateness   time.Duration
+	timeLayout string
+	keys       string
+	aggs       string
+}
+
+func (*Window) Name() string { return "window" }
+
+func (*Window) Description() string { return "aggregate a stream by windows of event time" }
+
+func (o *Window) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.kind, "type", lib.TumblingWindow, "Kind of windows: tumbling, sliding or session")
+	fs.StringVar(&o.column, "time", "", "Event time column, e.g. Pickup_datetime")
+	fs.DurationVar(&o.size, "size", 0, "Length of tumbling and sliding windows, e.g. 5m")
+	fs.DurationVar(&o.slide, "slide", 0, "How often a sliding window starts")
+	fs.DurationVar(&o.gap, "gap", 0, "Inactivity that ends a session window")
+	fs.DurationVar(&o.lateness, "lateness", 0, "How far the watermark stays behind the latest event time, later rows are dropped")
+	fs.StringVar(&o.timeLayout, "time-format", "", "Go layout of the event times, e.g. \"01/02/2006 03:04:05 PM\"; RFC 3339 and common layouts by default")
+	fs.StringVar(&o.keys, "keys", "", "Comma separated columns to group by within a window")
+	fs.StringVar(&o.aggs, "aggs", "count(*)", "Aggregations, e.g. \"count(*), sum(Fare_amount) as fares\"")
+}
+
+func (o *Window) windower() (*lib.Windower, error) {
+	aggs, err := lib.ParseAggregations(o.aggs)
+	if err != nil {
+		return nil, err
+	}
+	return lib.NewWindower(lib.WindowSpec{
+		Kind:       o.kind,
+		Column:     o.column,
+		Size:       o.size,
+		Slide:      o.slide,
+		Gap:        o.gap,
+		Lateness:   o.lateness,
+		TimeLayout: o.timeLayout,
+		Keys:       splitList(o.keys),
+		Aggs:       aggs,
+	})
+}
+
+// dropped reports the rows that were left out of every window.
+func dropped(env *lib.OperatorEnv, w *lib.Windower) {
+	if w.Dropped > 0 {
+		fmt.Fprintf(env.Stderr, "window: dropped %d late rows or rows without event time\n", w.Dropped)
+	}
+}
+
+func (o *Window) Run(env *lib.OperatorEnv) (*lib.DataFrame, error) {
+	w, err := o.windower()
+	if err != nil {
+		return nil, err
+	}
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	frames, err := w.Add(df)
+	if err != nil {
+		return nil, err
+	}
+	rest, err := w.Close()
+	if err != nil {
+		return nil, err
+	}
+	dropped(env, w)
+	frames = append(frames, rest...)
+	if len(frames) == 0 {
+		return nil, nil
+	}
+	result := frames[0]
+	for _, f := range frames[1:] {
+		if result, err = result.UnionAll(f); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// RunStream emits the windows every batch closes, and the windows still
+// open at the end of the stream.
+func (o *Window) RunStream(env *lib.OperatorEnv, emit func(*lib.DataFrame) error) error {
+	w, err := o.windower()
+	if err != nil {
+		return err
+	}
+	emitAll := func(frames []*lib.DataFrame, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, f := range frames {
+			if err := emit(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := env.EachFrame(func(df *lib.DataFrame) error { return emitAll(w.Add(df)) }); err != nil {
+		return err
+	}
+	defer dropped(env, w)
+	return emitAll(w.Close())
+}
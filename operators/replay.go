@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// replayCommand turns the history of a saved frame into a pipeline spec or
+// a shell script that repeats it, see lib.Replay.
+func replayCommand(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	spec := flags.Bool("spec", false, "Print a pipeline spec for run instead of a shell script")
+	bin := flags.String("bin", "./bin", "Directory of the operator binaries used by the shell script")
+	source := flags.String("source", "", "CSV file to import instead of the source of the frame")
+	output := flags.String("output", "-", "File the result is exported to, - for stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s replay [flags] result.gob\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	df, err := lib.ReadFrameFile(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, err := lib.Replay(df.Metadata, lib.ReplayOptions{Source: *source, Output: *output})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *spec {
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(p); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	script, err := p.Shell(*bin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(script)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// readPipeline decodes a pipeline spec, unknown keys are an error so that a
+// misspelled argument is not silently ignored.
+func readPipeline(path string) (*lib.Pipeline, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	var p lib.Pipeline
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// runCommand executes a pipeline spec in-process and reports rows and time
+// per step on stderr, or with -shell prints the equivalent shell pipeline.
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	shell := flags.Bool("shell", false, "Print the pipeline as a shell script instead of running it")
+	bin := flags.String("bin", "./bin", "Directory of the operator binaries used by -shell")
+	budget := flags.String("memory-budget", os.Getenv("OPERATORS_MEMORY_BUDGET"), "Memory joins, sorts, distinct and grouping may use before they spill to temporary files")
+	checkpoint := flags.String("checkpoint", os.Getenv("OPERATORS_CHECKPOINT"), "Checkpoint directory, overrides the checkpoint of the pipeline")
+	metrics := flags.String("metrics", os.Getenv("OPERATORS_METRICS"), "Write JSON metrics of every step to a file, - for stderr")
+	traceID := flags.String("trace-id", os.Getenv("OPERATORS_TRACE_ID"), "Trace id of the metrics and spans, a new one when empty")
+	compression := flags.String("compression", os.Getenv("OPERATORS_COMPRESSION"), "Compress exported frames and checkpoints with none, zstd, snappy or lz4")
+	traceExporter := flags.String("trace", os.Getenv("OPERATORS_TRACE"), "Export OpenTelemetry spans to stdout (written to stderr) or otlp-file:path, continuing the trace in $TRACEPARENT")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s run [flags] pipeline.yaml\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	p, err := readPipeline(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *shell {
+		script, err := p.Shell(*bin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(script)
+		return
+	}
+
+	if *checkpoint != "" {
+		p.Checkpoint = *checkpoint
+	}
+	if err := lib.SetCompression(*compression); err != nil {
+		log.Fatal(err)
+	}
+	if *budget != "" {
+		size, err := lib.ParseByteSize(*budget)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lib.SetMemoryBudget(size)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+	// an export to a closed stdout fails with EPIPE instead of killing the process
+	signal.Ignore(syscall.SIGPIPE)
+	if parent := os.Getenv(lib.TraceParentEnv); parent != "" {
+		ctx = lib.ContextWithTraceParent(ctx, parent)
+		if *traceID == "" {
+			*traceID = trace.SpanContextFromContext(ctx).TraceID().String()
+		}
+	}
+	if *traceID == "" {
+		*traceID = lib.NewTraceID()
+	}
+	stopTracing := func(context.Context) error { return nil }
+	if *traceExporter != "" {
+		if stopTracing, err = lib.StartTracing(*traceExporter, *traceID); err != nil {
+			log.Fatal(err)
+		}
+	}
+	reports, err := p.RunContext(ctx)
+	if stopErr := stopTracing(context.WithoutCancel(ctx)); stopErr != nil {
+		log.Print(stopErr)
+	}
+	if errors.Is(err, syscall.EPIPE) {
+		err = nil
+	}
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "step\top\trows in\trows out\ttime\tcheckpoint\t")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%v\t%s\t\n", r.Step, r.Op, r.RowsIn, r.RowsOut, r.Duration.Round(time.Microsecond), r.Checkpoint)
+	}
+	w.Flush()
+	for _, r := range lib.Spills() {
+		fmt.Fprintln(os.Stderr, r)
+	}
+	if *metrics != "" {
+		records := make([]lib.OperatorMetrics, 0, len(reports))
+		for _, r := range reports {
+			if r.Checkpoint != "skipped" {
+				records = append(records, r.Metrics(*traceID))
+			}
+		}
+		if err := lib.WriteMetrics(*metrics, records...); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPipeline(t *testing.T) {
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "pipeline.yaml")
+	os.WriteFile(spec, []byte(`steps:
+  - name: trips
+    op: importer
+    file: trips.csv
+  - name: long
+    op: where
+    input: trips
+    cond: "Trip_distance > 10"
+`), 0o644)
+	p, err := readPipeline(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Steps) != 2 || p.Steps[1].Cond != "Trip_distance > 10" || p.Steps[1].Input != "trips" {
+		t.Errorf("steps = %+v", p.Steps)
+	}
+
+	misspelled := filepath.Join(dir, "misspelled.yaml")
+	os.WriteFile(misspelled, []byte("steps:\n  - name: trips\n    op: importer\n    files: trips.csv\n"), 0o644)
+	if _, err := readPipeline(misspelled); err == nil || !strings.Contains(err.Error(), "field files not found") {
+		t.Errorf("err = %v, want field files not found", err)
+	}
+}
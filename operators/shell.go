@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/chzyer/readline"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// shell keeps a DataFrame in memory and the versions before it for undo.
+type shell struct {
+	df      *lib.DataFrame
+	undo    []*lib.DataFrame
+	rl      *readline.Instance
+	out     io.Writer
+	changed bool
+}
+
+type shellCommandSpec struct {
+	name string
+	args string
+	help string
+}
+
+var shellCommands = []shellCommandSpec{
+	{"project", "col[,col...]", "keep the given columns"},
+	{"where", "condition", "keep the rows matching the condition"},
+	{"transform", "statement", "evaluate a statement into a column"},
+	{"sql", "query", "replace the frame by the result of a SELECT query"},
+	{"describe", "", "count, nulls, min, max and mean of every column"},
+	{"schema", "", "list the columns and their types"},
+	{"head", "[n]", "show the first n rows, 10 by default"},
+	{"undo", "", "go back to the frame before the last change"},
+	{"save", "file.gob|file.csv", "write the frame, as CSV for .csv files"},
+	{"help", "", "list the commands"},
+	{"quit", "", "leave the shell"},
+}
+
+// loadFrame reads a gob file, or a CSV file with an optional schema.
+func loadFrame(path, schema string) (*lib.DataFrame, error) {
+	if filepath.Ext(path) == ".gob" {
+		return lib.ReadFrameFile(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	d := lib.CreateDataFrameFromCSV(csv.NewReader(file))
+	if schema != "" {
+		types, err := lib.ParseSchemaSpec(schema)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.ApplySchema(types); err != nil {
+			return nil, err
+		}
+	}
+	return &d, nil
+}
+
+// Do completes command names at the start of the line and column names of
+// the frame everywhere else.
+func (s *shell) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && (unicode.IsLetter(line[start-1]) || unicode.IsDigit(line[start-1]) || line[start-1] == '_') {
+		start--
+	}
+	prefix := string(line[start:pos])
+	candidates := make([]string, 0)
+	suffix := ""
+	if strings.TrimSpace(string(line[:start])) == "" {
+		for _, c := range shellCommands {
+			candidates = append(candidates, c.name)
+		}
+		suffix = " "
+	} else {
+		for _, f := range s.df.Schema.Fields {
+			candidates = append(candidates, f.FieldName)
+		}
+	}
+	matches := make([][]rune, 0)
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, []rune(c[len(prefix):]+suffix))
+		}
+	}
+	return matches, len([]rune(prefix))
+}
+
+// page writes text a screen at a time when the output is a terminal.
+func (s *shell) page(text string) {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	_, height, err := readline.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height < 3 || len(lines) < height {
+		fmt.Fprint(s.out, text)
+		return
+	}
+	prompt := s.rl.Config.Prompt
+	defer s.rl.SetPrompt(prompt)
+	s.rl.SetPrompt("-- more, enter to continue, q to stop -- ")
+	for len(lines) > 0 {
+		n := min(height-1, len(lines))
+		fmt.Fprint(s.out, strings.Join(lines[:n], ""))
+		lines = lines[n:]
+		if len(lines) == 0 {
+			break
+		}
+		answer, err := s.rl.Readline()
+		if err != nil || strings.TrimSpace(answer) == "q" {
+			break
+		}
+	}
+}
+
+// set makes df the frame, the current one can be restored with undo.
+func (s *shell) set(df *lib.DataFrame) {
+	s.undo = append(s.undo, s.df)
+	s.df = df
+	s.changed = true
+}
+
+func (s *shell) prompt() string {
+	return fmt.Sprintf("[%d rows x %d columns]> ", s.df.GetNumberOfRows(), s.df.GetNumberOfColumns())
+}
+
+func (s *shell) describe() string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "column\ttype\tcount\tnulls\tmin\tmax\tmean")
+	stats := s.df.Describe()
+	for _, f := range s.df.Schema.Fields {
+		c := stats[f.FieldName].(map[string]interface{})
+		value := func(key string) string {
+			if c[key] == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%v", c[key])
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.FieldName, f.FieldType,
+			value("count"), value("nulls"), value("min"), value("max"), value("mean"))
+	}
+	w.Flush()
+	return b.String()
+}
+
+// run executes one command line.
+func (s *shell) run(line string) error {
+	name, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	needsArgument := func() error {
+		if rest == "" {
+			return fmt.Errorf("%s needs an argument, see help", name)
+		}
+		return nil
+	}
+
+	switch name {
+	case "project":
+		if err := needsArgument(); err != nil {
+			return err
+		}
+		columns := strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		df, err := s.df.Project(columns...)
+		if err != nil {
+			return err
+		}
+		s.set(df)
+	case "where":
+		if err := needsArgument(); err != nil {
+			return err
+		}
+		df, err := s.df.Where(rest)
+		if err != nil {
+			return err
+		}
+		s.set(df)
+	case "transform":
+		if err := needsArgument(); err != nil {
+			return err
+		}
+		// Transform changes its frame, keep the current one for undo
+		df, err := s.df.Project(s.df.GetFieldNames()...)
+		if err != nil {
+			return err
+		}
+		if err := df.Transform(rest); err != nil {
+			return err
+		}
+		s.set(df)
+	case "sql":
+		if err := needsArgument(); err != nil {
+			return err
+		}
+		df, err := s.df.SQL(rest)
+		if err != nil {
+			return err
+		}
+		s.set(df)
+	case "describe":
+		s.page(s.describe())
+	case "schema":
+		var b bytes.Buffer
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, f := range s.df.Schema.Fields {
+			fmt.Fprintf(w, "%s\t%s\n", f.FieldName, f.FieldType)
+		}
+		w.Flush()
+		s.page(b.String())
+	case "head":
+		n := 10
+		if rest != "" {
+			var err error
+			if n, err = strconv.Atoi(rest); err != nil || n < 0 {
+				return fmt.Errorf("head expects a number of rows, not %q", rest)
+			}
+		}
+		var b bytes.Buffer
+		if err := lib.WriteTable(&b, s.df.Head(n)); err != nil {
+			return err
+		}
+		s.page(b.String())
+	case "undo":
+		if len(s.undo) == 0 {
+			return errors.New("nothing to undo")
+		}
+		s.df = s.undo[len(s.undo)-1]
+		s.undo = s.undo[:len(s.undo)-1]
+	case "save":
+		if err := needsArgument(); err != nil {
+			return err
+		}
+		format := lib.GobFormat
+		if filepath.Ext(rest) == ".csv" {
+			format = lib.CSVFormat
+		}
+		file, err := os.Create(rest)
+		if err != nil {
+			return err
+		}
+		if err := lib.WriteFrameFormat(file, s.df, format); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		s.changed = false
+		fmt.Fprintf(s.out, "saved %d rows to %s\n", s.df.GetNumberOfRows(), rest)
+	case "help":
+		var b bytes.Buffer
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, c := range shellCommands {
+			fmt.Fprintf(w, "%s %s\t%s\n", c.name, c.args, c.help)
+		}
+		w.Flush()
+		fmt.Fprint(s.out, b.String())
+	default:
+		names := make([]string, 0, len(shellCommands))
+		for _, c := range shellCommands {
+			names = append(names, c.name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, commands are %s", name, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// shellCommand explores a CSV or gob file interactively.
+func shellCommand(args []string) {
+	flags := flag.NewFlagSet("shell", flag.ExitOnError)
+	schema := flags.String("schema", "", "The schema of a CSV file, e.g. \"amount:decimal(10,2),id:int\"")
+	compression := flags.String("compression", os.Getenv("OPERATORS_COMPRESSION"), "Compress frames saved as gob with none, zstd, snappy or lz4")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s shell [flags] file.csv|file.gob\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err := lib.SetCompression(*compression); err != nil {
+		log.Fatal(err)
+	}
+	df, err := loadFrame(flags.Arg(0), *schema)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &shell{df: df, out: os.Stdout}
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, ".operators_history")
+	}
+	s.rl, err = readline.NewEx(&readline.Config{
+		Prompt:                 s.prompt(),
+		HistoryFile:            history,
+		AutoComplete:           s,
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "quit",
+		DisableAutoSaveHistory: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.rl.Close()
+	fmt.Fprintf(s.out, "%s: %d rows, %d columns. Type help for the commands.\n", flags.Arg(0), df.GetNumberOfRows(), df.GetNumberOfColumns())
+
+	for {
+		line, err := s.rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s.rl.SaveHistory(line)
+		if slices.Contains([]string{"quit", "exit"}, line) {
+			break
+		}
+		if err := s.run(line); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		s.rl.SetPrompt(s.prompt())
+	}
+	if s.changed {
+		fmt.Fprintln(os.Stderr, "the frame was changed after it was last saved")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testShell(t *testing.T) (*shell, *bytes.Buffer) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trips.csv")
+	os.WriteFile(path, []byte("Vendor_id,Fare_amount,zone\n1,12.50,a\n2,3.25,b\n1,7.00,b\n"), 0o644)
+	df, err := loadFrame(path, "Vendor_id:int,Fare_amount:decimal(6,2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	return &shell{df: df, out: out}, out
+}
+
+func TestShellCommands(t *testing.T) {
+	s, out := testShell(t)
+	for _, line := range []string{
+		"where Vendor_id == 1",
+		"transform map(Fare_amount, # * 2)",
+		"project zone, Fare_amount",
+	} {
+		if err := s.run(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	if got, want := s.prompt(), "[2 rows x 2 columns]> "; got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+
+	out.Reset()
+	if err := s.run("head 1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, " 25.00") || strings.Contains(got, "14.00") {
+		t.Errorf("head 1 =\n%s", got)
+	}
+
+	saved := filepath.Join(t.TempDir(), "out.csv")
+	if err := s.run("save " + saved); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(saved); string(got) != "zone,Fare_amount\na,25.00\nb,14.00\n" {
+		t.Errorf("saved %q", got)
+	}
+
+	// undo goes back through project, transform and where
+	for _, want := range []string{"[2 rows x 3 columns]> ", "[2 rows x 3 columns]> ", "[3 rows x 3 columns]> "} {
+		if err := s.run("undo"); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.prompt(); got != want {
+			t.Errorf("prompt after undo = %q, want %q", got, want)
+		}
+	}
+	if got := fmt.Sprint(s.df.GetColumn("Fare_amount")[0]); got != "12.50" {
+		t.Errorf("the transform changed the frame before it, Fare_amount = %v", got)
+	}
+	if err := s.run("undo"); err == nil || err.Error() != "nothing to undo" {
+		t.Errorf("undo of the loaded frame: %v", err)
+	}
+
+	out.Reset()
+	if err := s.run("schema"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "Vendor_id    int\nFare_amount  DECIMAL\nzone         string\n"; got != want {
+		t.Errorf("schema =\n%s\nwant\n%s", got, want)
+	}
+
+	for line, want := range map[string]string{
+		"where":        "where needs an argument",
+		"head many":    `head expects a number of rows, not "many"`,
+		"project taxi": "",
+		"drop zone":    `unknown command "drop"`,
+	} {
+		err := s.run(line)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%s did not fail", line)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", line, err, want)
+		}
+	}
+}
+
+func TestShellCompletion(t *testing.T) {
+	s, _ := testShell(t)
+	complete := func(line string) []string {
+		matches, n := s.Do([]rune(line), len([]rune(line)))
+		got := make([]string, len(matches))
+		for i, m := range matches {
+			got[i] = line[len(line)-n:] + string(m)
+		}
+		return got
+	}
+	if got, want := complete("s"), []string{"sql ", "schema ", "save "}; !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if got, want := complete("where Fa"), []string{"Fare_amount"}; !slices.Equal(got, want) {
+		t.Errorf("columns = %q, want %q", got, want)
+	}
+	if got := complete("project zone, "); len(got) != 3 {
+		t.Errorf("all columns = %q", got)
+	}
+}
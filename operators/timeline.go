@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+// timelineCommand rebuilds the timeline of the stages of a pipeline from
+// the metrics its operators wrote with -metrics.
+func timelineCommand(args []string) {
+	flags := flag.NewFlagSet("timeline", flag.ExitOnError)
+	traceID := flags.String("trace-id", "", "Only show this trace")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s timeline [flags] [metrics files]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	records := make([]lib.OperatorMetrics, 0)
+	read := func(file *os.File) {
+		r, err := lib.ReadMetrics(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		records = append(records, r...)
+	}
+	if flags.NArg() == 0 {
+		read(os.Stdin)
+	}
+	for _, path := range flags.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		read(file)
+		file.Close()
+	}
+	if *traceID != "" {
+		records = slices.DeleteFunc(records, func(r lib.OperatorMetrics) bool { return r.TraceID != *traceID })
+	}
+	if len(records) == 0 {
+		log.Fatal("no metrics found")
+	}
+	if err := lib.Timeline(os.Stdout, records); err != nil {
+		log.Fatal(err)
+	}
+}
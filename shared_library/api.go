@@ -1,5 +1,7 @@
 package sharedlibrary
 
+import "context"
+
 type DataFrameInterface interface {
 	// Getters
 	GetColumn(colname string) Data
@@ -22,20 +24,30 @@ type DataFrameInterface interface {
 	AddColumn(fieldname string, data Data) error
 	AddFunction(name string, f interface{})
 	Count() int
+	CreateIndex(fieldname string, kind IndexKind) error
 	Describe() map[string]interface{}
-	Distinct(fieldname string) []string
+	Distinct(fieldname string) []any
+	DistinctRows(fields ...string) (*DataFrame, error)
 	DropColumn(fieldname string) error
+	DropIndex(fieldname string)
 	GenerateStats()
+	Head(n int) *DataFrame
 	IndexRows() error
 	Project(fields ...string) (*DataFrame, error)
 	RenameColumn(old_fieldname, new_fieldname string) error
 	RemoveFunction(name string)
+	Sort(keys ...SortKey) (*DataFrame, error)
+	SQL(query string) (*DataFrame, error)
 	Transform(value string) error
+	TransformContext(ctx context.Context, value string) error
 	UnionAll(otherDF *DataFrame) (*DataFrame, error)
+	ValueCounts(fieldname string) (*DataFrame, error)
 	Where(value string) (*DataFrame, error)
+	WhereContext(ctx context.Context, value string) (*DataFrame, error)
 }
 
-// Ensure the file ends with a newline
+// DataFrame must implement DataFrameInterface.
+var _ DataFrameInterface = (*DataFrame)(nil)
 
 type DataStructure interface {
 	// Getters
@@ -56,11 +68,16 @@ type DataStructure interface {
 
 // types
 
-type metadata struct {
+// Metadata describes where a DataFrame comes from. Source names the input,
+// for an incremental import the byte ranges of the files that were read.
+type Metadata struct {
 	Source   string
 	Rows     int
 	Columns  int
 	RowStats map[string]RowStat
+	// History are the operations that produced the DataFrame from its
+	// source, oldest first.
+	History []HistoryEntry
 }
 
 type Data = []any
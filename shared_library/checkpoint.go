@@ -0,0 +1,108 @@
+package sharedlibrary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoints is a content addressed cache of DataFrames in a directory.
+// A checkpoint is keyed by what produced the frame: the operator, its
+// arguments and the keys or hashes of its inputs, see CheckpointKey.
+type Checkpoints struct {
+	Dir string
+}
+
+// HashBytes returns the hex encoded SHA-256 of data.
+func HashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashFile returns the hex encoded SHA-256 of the content of a file.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CheckpointKey returns the key of the output of an operator run with args
+// on inputs, which are hashes of input data or keys of other checkpoints.
+func CheckpointKey(operator string, args []string, inputs ...string) string {
+	h := sha256.New()
+	// every part is length prefixed so that parts can not run into each other
+	write := func(part string) {
+		var n [8]byte
+		for i := range n {
+			n[i] = byte(len(part) >> (8 * i))
+		}
+		h.Write(n[:])
+		h.Write([]byte(part))
+	}
+	write(operator)
+	write("args")
+	for _, a := range args {
+		write(a)
+	}
+	write("inputs")
+	for _, in := range inputs {
+		write(in)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c Checkpoints) path(key string) string {
+	return filepath.Join(c.Dir, key+".gob")
+}
+
+// Has reports whether there is a checkpoint for key.
+func (c Checkpoints) Has(key string) bool {
+	_, err := os.Stat(c.path(key))
+	return err == nil
+}
+
+// Load returns the DataFrame saved for key, it returns false when there is
+// no checkpoint for key.
+func (c Checkpoints) Load(key string) (*DataFrame, bool, error) {
+	df, err := ReadFrameFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return df, true, nil
+}
+
+// Save writes the checkpoint for key. The file is written under a temporary
+// name and renamed so that a failed run never leaves a partial checkpoint.
+func (c Checkpoints) Save(key string, d *DataFrame) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := WriteFrame(tmp, d); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
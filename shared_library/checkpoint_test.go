@@ -0,0 +1,137 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCheckpoints(t *testing.T) {
+	c := Checkpoints{Dir: filepath.Join(t.TempDir(), "cache")}
+	key := CheckpointKey("where", []string{"-cond=a > 1"}, HashBytes([]byte("input")))
+	if c.Has(key) {
+		t.Fatal("an empty directory has a checkpoint")
+	}
+	if df, found, err := c.Load(key); df != nil || found || err != nil {
+		t.Fatalf("Load of a missing checkpoint = %v, %v, %v", df, found, err)
+	}
+	if err := c.Save(key, newTestFrame(t, "a:int", []string{"a"}, []string{"2"})); err != nil {
+		t.Fatal(err)
+	}
+	df, found, err := c.Load(key)
+	if err != nil || !found || !c.Has(key) {
+		t.Fatalf("Load = %v, %v, %v", df, found, err)
+	}
+	if got := rows(t, df); !slices.Equal(got, []string{"a", "2"}) {
+		t.Errorf("loaded %v", got)
+	}
+	if files, _ := filepath.Glob(filepath.Join(c.Dir, "*")); len(files) != 1 {
+		t.Errorf("files in the checkpoint directory = %v, want only the checkpoint", files)
+	}
+
+	keys := []string{
+		key,
+		CheckpointKey("project", []string{"-cond=a > 1"}, HashBytes([]byte("input"))),
+		CheckpointKey("where", []string{"-cond=a > 2"}, HashBytes([]byte("input"))),
+		CheckpointKey("where", []string{"-cond=a > 1"}, HashBytes([]byte("other"))),
+		CheckpointKey("where", []string{"ab", "c"}),
+		CheckpointKey("where", []string{"a", "bc"}),
+		CheckpointKey("where", nil, "ab"),
+	}
+	if got := len(slices.Compact(slices.Sorted(slices.Values(keys)))); got != len(keys) {
+		t.Errorf("%d keys of %d are distinct", got, len(keys))
+	}
+}
+
+func TestOperatorCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	input := filepath.Join(dir, "in.gob")
+	write := func(name string) {
+		if err := WriteFrameFile(input, newTestFrame(t, "", []string{"name"}, []string{name})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	op := &upperOperator{}
+	upper := func(args ...string) string {
+		out, err := runWithStdout(t, op, append([]string{"-input", input, "-checkpoint", cache, "-format", "csv"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	write("anna")
+	for range 2 {
+		if got := upper("-col", "name"); got != "name\nANNA\n" {
+			t.Errorf("output = %q", got)
+		}
+	}
+	if op.runs != 1 {
+		t.Errorf("ran %d times with the same input and flags, want once", op.runs)
+	}
+	// the frame is written again, with a new time in its history
+	write("anna")
+	upper("-col", "name")
+	if op.runs != 1 {
+		t.Errorf("ran again for the same frame written again")
+	}
+	write("bob")
+	if got := upper("-col", "name"); got != "name\nBOB\n" {
+		t.Errorf("output for a new input = %q", got)
+	}
+	// the shared flags do not change the output
+	upper("-col", "name", "-debug=false")
+	if op.runs != 2 {
+		t.Errorf("ran %d times, want twice", op.runs)
+	}
+}
+
+func TestPipelineCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	trips := filepath.Join(dir, "trips.csv")
+	os.WriteFile(trips, []byte(tripsCSV), 0o644)
+	p := &Pipeline{Checkpoint: filepath.Join(dir, "cache"), Steps: []PipelineStep{
+		{Name: "trips", Op: "importer", File: trips},
+		{Name: "long", Op: "where", Input: "trips", Cond: "int(Trip_distance) > 10"},
+		{Name: "slim", Op: "project", Input: "long", Cols: []string{"Fare_amount"}},
+		{Name: "out", Op: "export", Input: "slim", File: filepath.Join(dir, "out.gob")},
+	}}
+	run := func() []string {
+		t.Helper()
+		reports, err := p.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(reports))
+		for i, r := range reports {
+			got[i] = fmt.Sprintf("%s %s %d", r.Step, r.Checkpoint, r.RowsOut)
+		}
+		return got
+	}
+
+	if got, want := run(), []string{"trips saved 4", "long saved 3", "slim saved 3", "out  3"}; !slices.Equal(got, want) {
+		t.Errorf("first run = %q, want %q", got, want)
+	}
+	// only the last checkpoint before the export is needed
+	if got, want := run(), []string{"trips skipped 0", "long skipped 0", "slim loaded 3", "out  3"}; !slices.Equal(got, want) {
+		t.Errorf("second run = %q, want %q", got, want)
+	}
+	p.Steps[2].Cols = []string{"Fare_amount", "Vendor_id"}
+	if got, want := run(), []string{"trips skipped 0", "long loaded 3", "slim saved 3", "out  3"}; !slices.Equal(got, want) {
+		t.Errorf("run with new cols = %q, want %q", got, want)
+	}
+	os.WriteFile(trips, []byte(tripsCSV+"4,1.00,30\n"), 0o644)
+	if got, want := run(), []string{"trips saved 5", "long saved 4", "slim saved 4", "out  4"}; !slices.Equal(got, want) {
+		t.Errorf("run with a new file = %q, want %q", got, want)
+	}
+	df, err := ReadFrameFile(filepath.Join(dir, "out.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, df, "Vendor_id"); !slices.Equal(got, []string{"1", "1", "3", "4"}) {
+		t.Errorf("exported Vendor_id = %v", got)
+	}
+}
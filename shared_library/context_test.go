@@ -0,0 +1,155 @@
+package sharedlibrary
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// largeFrame returns a frame of more rows than the context variants
+// process between checks of their context.
+func largeFrame(t *testing.T) *DataFrame {
+	t.Helper()
+	records := make([][]string, 2*checkRows+1)
+	for i := range records {
+		records[i] = []string{strconv.Itoa(i % 7), strconv.Itoa(i)}
+	}
+	return newTestFrame(t, "Vendor_id:int,Trip:int", []string{"Vendor_id", "Trip"}, records...)
+}
+
+func TestContextCanceled(t *testing.T) {
+	d := largeFrame(t)
+	vendors := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "name"}, []string{"1", "anna"}, []string{"2", "bob"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"Where", func() error { _, err := d.WhereContext(ctx, "Trip > 10"); return err }},
+		{"Join", func() error { _, err := d.JoinContext(ctx, vendors, []string{"Vendor_id"}); return err }},
+		{"GroupBy", func() error {
+			_, err := d.GroupByContext(ctx, []string{"Vendor_id"}, Aggregation{Column: "Trip", Function: "sum"})
+			return err
+		}},
+		{"Transform", func() error { return d.TransformContext(ctx, "map(Trip, # * 2)") }},
+		{"Collect", func() error {
+			records := "Vendor_id,Trip\n" + strings.Repeat("1,2\n", 2*checkRows)
+			_, err := ScanCSV(csv.NewReader(strings.NewReader(records))).Where("Trip == '2'").CollectContext(ctx)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.run(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s with a canceled context returned %v, want context.Canceled", tt.name, err)
+		}
+	}
+
+	// a canceled Transform leaves the frame as it was
+	if got := columnStrings(t, d, "Trip")[:3]; !slices.Equal(got, []string{"0", "1", "2"}) {
+		t.Errorf("Trip after a canceled Transform = %v, want [0 1 2]", got)
+	}
+
+	// the same operations run to their end without cancellation
+	df, err := d.WhereContext(context.Background(), "Trip >= 2048")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, df, "Trip"); !slices.Equal(got, []string{"2048"}) {
+		t.Errorf("Where Trip >= 2048 = %v, want [2048]", got)
+	}
+}
+
+// doneAfter is a context that is canceled after its error has been checked
+// a number of times.
+type doneAfter struct {
+	context.Context
+	checks int
+}
+
+func (c *doneAfter) Err() error {
+	if c.checks--; c.checks < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestTransformCanceledWhileRunning(t *testing.T) {
+	d := largeFrame(t)
+	ctx := &doneAfter{Context: context.Background(), checks: 1}
+	if err := d.TransformContext(ctx, "map(Trip, # * 2)"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Transform canceled after the first chunk = %v, want context.Canceled", err)
+	}
+	if got := columnStrings(t, d, "Trip")[:3]; !slices.Equal(got, []string{"0", "1", "2"}) {
+		t.Errorf("Trip after a canceled Transform = %v, want [0 1 2]", got)
+	}
+
+	// row-wise statements give the same result in chunks, #index counts
+	// the rows of the whole frame
+	if err := d.Transform("map(Trip, # + 1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Transform("map(Vendor_id, #index)"); err != nil {
+		t.Fatal(err)
+	}
+	trips, vendors := columnStrings(t, d, "Trip"), columnStrings(t, d, "Vendor_id")
+	if last := len(trips) - 1; trips[last] != strconv.Itoa(last+1) || vendors[last] != strconv.Itoa(last) {
+		t.Errorf("last row = Trip %s, Vendor_id %s, want %d and %d", trips[last], vendors[last], last+1, last)
+	}
+}
+
+func TestCanceledChecksEveryChunk(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, row := range []int{1, checkRows - 1, checkRows + 1} {
+		if err := canceled(ctx, row); err != nil {
+			t.Errorf("canceled(ctx, %d) = %v, want nil between checks", row, err)
+		}
+	}
+	for _, row := range []int{0, checkRows, 2 * checkRows} {
+		if err := canceled(ctx, row); !errors.Is(err, context.Canceled) {
+			t.Errorf("canceled(ctx, %d) = %v, want context.Canceled", row, err)
+		}
+	}
+}
+
+func TestRunOperatorClosedPipe(t *testing.T) {
+	records := make([][]string, 2*checkRows)
+	for i := range records {
+		records[i] = []string{"name " + strconv.Itoa(i)}
+	}
+	input := filepath.Join(t.TempDir(), "in.gob")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := NewFrameWriter(file)
+	if err := frames.Write(newTestFrame(t, "", []string{"name"}, records...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := errors.Join(frames.Close(), file.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reader of stdout went away like head after its lines
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	defer w.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	if err := RunOperator(&upperOperator{}, []string{"-input", input, "-format", "csv", "-col", "name"}); err != nil {
+		t.Errorf("RunOperator writing to a closed pipe = %v, want nil", err)
+	}
+}
@@ -0,0 +1,339 @@
+package sharedlibrary
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CSVOptions are how ReadCSV, FollowCSV and ImportNew parse CSV files.
+type CSVOptions struct {
+	// FieldsPerRecord is the number of fields of every record. 0 is the
+	// number of fields of the header, a negative number lets records have
+	// fewer fields than the header, the missing ones are empty.
+	FieldsPerRecord int
+	// LazyQuotes allows quotes in unquoted fields and single quotes in
+	// quoted fields.
+	LazyQuotes bool
+	// Schema, when set, casts its columns to their type, a record with a
+	// value that can not be cast is a bad record too. Its columns are named
+	// as in the header, or as NormalizeNames names them with NormalizeHeader.
+	Schema []Field
+	// NormalizeHeader renames the columns with NormalizeNames.
+	NormalizeHeader bool
+	// Reject is called with every bad record, which is left out then.
+	// Without it the first bad record is an error.
+	Reject func(RejectedRecord) error
+}
+
+// RejectedRecord is a record of a CSV file that could not be parsed or cast
+// to the schema. Line counts from where reading started, which is the start
+// of the file unless an incremental import continues it.
+type RejectedRecord struct {
+	Source string
+	Line   int
+	Err    error
+	// Record is the text of the record without its line break.
+	Record string
+}
+
+// recordingReader keeps what was read since the start of the record being
+// parsed, so that the text of a bad record can be rejected with it.
+type recordingReader struct {
+	r    io.Reader
+	buf  []byte
+	base int64
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// text returns what was read from offset start to end.
+func (r *recordingReader) text(start, end int64) string {
+	return string(r.buf[start-r.base : end-r.base])
+}
+
+// forget drops what was read before offset, once enough of it piled up.
+func (r *recordingReader) forget(offset int64) {
+	if len(r.buf) < 1<<16 {
+		return
+	}
+	n := copy(r.buf, r.buf[offset-r.base:])
+	r.buf = r.buf[:n]
+	r.base = offset
+}
+
+// csvRecords reads the records of a CSV file after its header and passes
+// the bad ones to the Reject function of the options.
+type csvRecords struct {
+	source string
+	header []string
+	reader *csv.Reader
+	input  *recordingReader
+	opts   CSVOptions
+	// casts are the columns of the schema with their position in a record
+	casts []columnCast
+}
+
+type columnCast struct {
+	position int
+	field    Field
+}
+
+// newCSVRecords starts reading a CSV file, with its first record as the
+// header when header is nil. An error reading the header is returned as it
+// is, io.EOF for an empty file.
+func newCSVRecords(r io.Reader, source string, header []string, opts CSVOptions) (*csvRecords, error) {
+	c := &csvRecords{source: source, header: header, opts: opts}
+	if opts.Reject != nil {
+		c.input = &recordingReader{r: r}
+		r = c.input
+	}
+	c.reader = csv.NewReader(r)
+	c.reader.LazyQuotes = opts.LazyQuotes
+	c.reader.FieldsPerRecord = max(opts.FieldsPerRecord, 0)
+	if c.header == nil {
+		record, err := c.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		c.header = append([]string(nil), record...)
+	}
+	c.reader.FieldsPerRecord = len(c.header)
+	if opts.FieldsPerRecord < 0 {
+		c.reader.FieldsPerRecord = -1
+	}
+	names := c.header
+	if opts.NormalizeHeader {
+		names = NormalizeNames(names)
+	}
+	for _, f := range opts.Schema {
+		// a column that is not there is left to newCSVFrame to report
+		if x := slices.Index(names, f.FieldName); x >= 0 {
+			c.casts = append(c.casts, columnCast{x, f})
+		}
+	}
+	return c, nil
+}
+
+// next returns the values of the next record that can be parsed and cast,
+// io.EOF at the end.
+func (c *csvRecords) next() ([]any, error) {
+	for {
+		start := c.reader.InputOffset()
+		if c.input != nil {
+			c.input.forget(start)
+		}
+		record, err := c.reader.Read()
+		if err == io.EOF {
+			return nil, err
+		}
+		var line int
+		var values []any
+		var bad error
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			line, bad = parseErr.StartLine, parseErr.Err
+		case err != nil:
+			return nil, fmt.Errorf("%s: %w", c.source, err)
+		default:
+			line, _ = c.reader.FieldPos(0)
+			values, bad = c.cast(record)
+		}
+		if bad == nil {
+			return values, nil
+		}
+		if c.opts.Reject == nil {
+			return nil, fmt.Errorf("%s: line %d: %w", c.source, line, bad)
+		}
+		rejected := RejectedRecord{
+			Source: c.source,
+			Line:   line,
+			Err:    bad,
+			Record: strings.TrimRight(c.input.text(start, c.reader.InputOffset()), "\r\n"),
+		}
+		if err := c.opts.Reject(rejected); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// cast pads a short record when the options allow it and returns its values
+// cast to the schema, or why the record is bad: it has the wrong number of
+// fields or a value that can not be cast.
+func (c *csvRecords) cast(record []string) ([]any, error) {
+	if len(record) < len(c.header) && c.opts.FieldsPerRecord < 0 {
+		record = append(record, make([]string, len(c.header)-len(record))...)
+	}
+	if len(record) != len(c.header) {
+		return nil, csv.ErrFieldCount
+	}
+	values := make([]any, len(record))
+	for i, v := range record {
+		values[i] = v
+	}
+	for _, cast := range c.casts {
+		v, err := castValue(record[cast.position], cast.field)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", cast.field.FieldName, err)
+		}
+		values[cast.position] = v
+	}
+	return values, nil
+}
+
+// newCSVFrame returns the values of records read with the options as a
+// DataFrame, with the renaming and the casts in its history.
+func newCSVFrame(source string, header []string, rows [][]any, opts CSVOptions) (*DataFrame, error) {
+	fields := make([]Field, len(header))
+	data := make([]*Data, len(header))
+	for i, name := range header {
+		fields[i] = Field{FieldName: name, FieldPosition: i, FieldType: "string"}
+		column := make(Data, len(rows))
+		for j, row := range rows {
+			column[j] = row[i]
+		}
+		data[i] = &column
+	}
+	d := NewDataFrameWithArgs(fields, data)
+	d.Metadata.Source = source
+	if opts.NormalizeHeader {
+		d.NormalizeColumnNames()
+	}
+	if err := d.applySchema(opts.Schema, d.setColumnType); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ReadCSV reads a CSV file into a DataFrame of string columns named by its
+// header, or as the options say, source names the file in errors and in the
+// metadata.
+func ReadCSV(r io.Reader, source string, opts CSVOptions) (*DataFrame, error) {
+	c, err := newCSVRecords(r, source, nil, opts)
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s: no header", source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	rows := make([][]any, 0)
+	for {
+		row, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return newCSVFrame(source, c.header, rows, opts)
+}
+
+// quarantineFields are the columns of a quarantine.
+var quarantineFields = []Field{
+	{FieldName: "source", FieldPosition: 0, FieldType: "string"},
+	{FieldName: "line", FieldPosition: 1, FieldType: "int"},
+	{FieldName: "error", FieldPosition: 2, FieldType: "string"},
+	{FieldName: "record", FieldPosition: 3, FieldType: "string"},
+}
+
+// Quarantine is a file of rejected records with the columns source, line,
+// error and record. It is a CSV file, or a frame stream when its name ends
+// with .gob.
+type Quarantine struct {
+	file    *os.File
+	csv     *csv.Writer
+	frames  *FrameWriter
+	pending []RejectedRecord
+	written bool
+}
+
+// CreateQuarantine creates or truncates a quarantine file.
+func CreateQuarantine(path string) (*Quarantine, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	q := &Quarantine{file: file}
+	if strings.HasSuffix(path, ".gob") {
+		q.frames = NewFrameWriter(file)
+		return q, nil
+	}
+	q.csv = csv.NewWriter(file)
+	header := make([]string, len(quarantineFields))
+	for i, f := range quarantineFields {
+		header[i] = f.FieldName
+	}
+	if err := q.csv.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Add writes a rejected record, to a frame stream at the next Flush.
+func (q *Quarantine) Add(r RejectedRecord) error {
+	if q.frames != nil {
+		q.pending = append(q.pending, r)
+		return nil
+	}
+	return q.csv.Write([]string{r.Source, strconv.Itoa(r.Line), r.Err.Error(), r.Record})
+}
+
+// Flush writes the records added since the last Flush, as a frame when the
+// quarantine is a frame stream.
+func (q *Quarantine) Flush() error {
+	if q.csv != nil {
+		q.csv.Flush()
+		return q.csv.Error()
+	}
+	if len(q.pending) == 0 {
+		return nil
+	}
+	if err := q.frames.Write(quarantineFrame(q.pending)); err != nil {
+		return err
+	}
+	q.pending, q.written = nil, true
+	return nil
+}
+
+// Close flushes the quarantine and closes its file. A frame stream without
+// rejected records gets an empty frame, so that it can be read.
+func (q *Quarantine) Close() error {
+	err := q.Flush()
+	if err == nil && q.frames != nil && !q.written {
+		err = q.frames.Write(quarantineFrame(nil))
+	}
+	if q.frames != nil {
+		err = errors.Join(err, q.frames.Close())
+	}
+	return errors.Join(err, q.file.Close())
+}
+
+// quarantineFrame returns rejected records as a DataFrame.
+func quarantineFrame(records []RejectedRecord) *DataFrame {
+	data := make([]*Data, len(quarantineFields))
+	for i := range data {
+		column := make(Data, len(records))
+		data[i] = &column
+	}
+	for j, r := range records {
+		(*data[0])[j] = r.Source
+		(*data[1])[j] = r.Line
+		(*data[2])[j] = r.Err.Error()
+		(*data[3])[j] = r.Record
+	}
+	fields := append([]Field(nil), quarantineFields...)
+	return NewDataFrameWithArgs(fields, data)
+}
@@ -0,0 +1,169 @@
+package sharedlibrary
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const badCSV = "id,name,amount\n1,a,10\n2,b\n3,c\"x,30\n4,d,40,extra\n5,e,abc\n6,f,60\n"
+
+// readRejecting reads badCSV with a schema and returns the ids of the rows
+// that were read and the records that were rejected.
+func readRejecting(t *testing.T, opts CSVOptions) ([]string, []RejectedRecord) {
+	t.Helper()
+	rejected := make([]RejectedRecord, 0)
+	opts.Reject = func(r RejectedRecord) error {
+		rejected = append(rejected, r)
+		return nil
+	}
+	opts.Schema = []Field{{FieldName: "amount", FieldType: "int"}}
+	d, err := ReadCSV(strings.NewReader(badCSV), "bad.csv", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return columnStrings(t, d, "id"), rejected
+}
+
+func TestReadCSVRejects(t *testing.T) {
+	ids, rejected := readRejecting(t, CSVOptions{})
+	if want := []string{"1", "6"}; !slices.Equal(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	want := []struct {
+		line   int
+		record string
+		err    string
+	}{
+		{3, "2,b", "wrong number of fields"},
+		{4, `3,c"x,30`, `bare " in non-quoted-field`},
+		{5, "4,d,40,extra", "wrong number of fields"},
+		{6, "5,e,abc", "column amount: strconv.Atoi"},
+	}
+	if len(rejected) != len(want) {
+		t.Fatalf("rejected %v, want %d records", rejected, len(want))
+	}
+	for i, w := range want {
+		r := rejected[i]
+		if r.Source != "bad.csv" || r.Line != w.line || r.Record != w.record || !strings.Contains(r.Err.Error(), w.err) {
+			t.Errorf("rejected[%d] = %+v, want line %d, record %q and error %q", i, r, w.line, w.record, w.err)
+		}
+	}
+	if !errors.Is(rejected[0].Err, csv.ErrFieldCount) {
+		t.Errorf("error of a short record = %v, want %v", rejected[0].Err, csv.ErrFieldCount)
+	}
+}
+
+func TestReadCSVLenient(t *testing.T) {
+	ids, rejected := readRejecting(t, CSVOptions{FieldsPerRecord: -1, LazyQuotes: true})
+	if want := []string{"1", "2", "3", "6"}; !slices.Equal(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if len(rejected) != 2 || rejected[0].Line != 5 || rejected[1].Line != 6 {
+		t.Errorf("rejected %+v, want the records on lines 5 and 6", rejected)
+	}
+}
+
+func TestReadCSVFails(t *testing.T) {
+	_, err := ReadCSV(strings.NewReader(badCSV), "bad.csv", CSVOptions{})
+	if err == nil || err.Error() != "bad.csv: line 3: wrong number of fields" {
+		t.Errorf("error = %v, want the short record on line 3", err)
+	}
+	if _, err := ReadCSV(strings.NewReader(""), "empty.csv", CSVOptions{}); err == nil {
+		t.Error("an empty file was read without an error")
+	}
+}
+
+func TestReadCSVNormalizedSchema(t *testing.T) {
+	opts := CSVOptions{
+		NormalizeHeader: true,
+		Schema:          []Field{{FieldName: "fare_amount", FieldType: "int"}},
+		Reject:          func(RejectedRecord) error { return nil },
+	}
+	d, err := ReadCSV(strings.NewReader("Fare.Amount\n1\nx\n"), "fares.csv", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, d, "fare_amount"); !slices.Equal(got, []string{"1"}) {
+		t.Errorf("fares = %v, want [1]", got)
+	}
+	if got := d.GetFieldType("fare_amount"); got != "int" {
+		t.Errorf("fares are %s, want int", got)
+	}
+	if v := d.GetColumn("fare_amount")[0]; v != 1 {
+		t.Errorf("fare = %#v, want the int 1", v)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	records := []RejectedRecord{
+		{Source: "bad.csv", Line: 3, Err: csv.ErrFieldCount, Record: "2,b"},
+		{Source: "bad.csv", Line: 5, Err: errors.New("column amount: bad"), Record: `5,"e",abc`},
+	}
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "quarantine.csv")
+	q, err := CreateQuarantine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := q.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "source,line,error,record\nbad.csv,3,wrong number of fields,\"2,b\"\nbad.csv,5,column amount: bad,\"5,\"\"e\"\",abc\"\n"
+	if string(b) != want {
+		t.Errorf("quarantine.csv =\n%s\nwant\n%s", b, want)
+	}
+
+	path = filepath.Join(dir, "quarantine.gob")
+	if q, err = CreateQuarantine(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := q.Add(r); err != nil {
+			t.Fatal(err)
+		}
+		// every flush is a frame of the stream
+		if err := q.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ReadFrameFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, d, "line"); !slices.Equal(got, []string{"3", "5"}) {
+		t.Errorf("lines = %v, want [3 5]", got)
+	}
+	if got := d.GetFieldType("line"); got != "int" {
+		t.Errorf("type of line = %s, want int", got)
+	}
+
+	// a quarantine without records is an empty frame
+	path = filepath.Join(dir, "empty.gob")
+	if q, err = CreateQuarantine(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = ReadFrameFile(path); err != nil || d.GetNumberOfRows() != 0 {
+		t.Errorf("empty quarantine = %v rows, %v", d, err)
+	}
+}
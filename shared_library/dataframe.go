@@ -1,23 +1,31 @@
 package sharedlibrary
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"maps"
+	"math/big"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+	"github.com/expr-lang/expr/vm/runtime"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type DataFrame struct {
 	Schema    Schema
 	Data      DataStructure //[]*Data
 	row       map[int]Row
-	metadata  metadata
+	Metadata  Metadata
 	functions map[string]interface{}
+	indexes   map[string]*columnIndex
 }
 
 func NewDataFrame() *DataFrame {
@@ -58,15 +66,69 @@ func (d DataFrame) GetPositionValue(col, row int) (any, error) {
 	return d.Data.getPositionValue(col, row)
 }
 
+func (d DataFrame) SetPositionValue(col, row int, value any) error {
+	if col >= 0 && col < len(d.Schema.Fields) {
+		delete(d.indexes, d.Schema.Fields[col].FieldName)
+	}
+	return d.Data.setPositionValue(col, row, value)
+}
+
 func (d *DataFrame) RenameColumn(old_fieldname, new_fieldname string) error {
 	x := d.Schema.GetField(old_fieldname)
 	if x < 0 {
 		return errors.New("field not found")
 	}
+	entry := startHistory("RenameColumn", []string{old_fieldname, new_fieldname}, d)
+	entry.Columns = sameColumns(d.Schema.Fields, 0)
+	entry.Columns[x].Column = new_fieldname
 	d.Schema.Fields[x].FieldName = new_fieldname
+	if idx, found := d.indexes[old_fieldname]; found {
+		delete(d.indexes, old_fieldname)
+		d.indexes[new_fieldname] = idx
+	}
+	d.recordHistory(d, entry, nil)
+	return nil
+}
+
+// RenameColumns renames the columns given as old:new pairs, after all
+// columns were renamed with NormalizeColumnNames when normalize is set.
+// This is what the rename operator does.
+func (d *DataFrame) RenameColumns(normalize bool, renames ...string) error {
+	if normalize {
+		d.NormalizeColumnNames()
+	}
+	for _, r := range renames {
+		old, name, found := strings.Cut(r, ":")
+		if !found {
+			return fmt.Errorf("rename %q is not old:new", r)
+		}
+		if err := d.RenameColumn(old, name); err != nil {
+			return fmt.Errorf("rename %s: %w", old, err)
+		}
+	}
 	return nil
 }
 
+// NormalizeColumnNames renames the columns to snake_case identifiers, see
+// NormalizeNames.
+func (d *DataFrame) NormalizeColumnNames() {
+	entry := startHistory("NormalizeColumnNames", nil, d)
+	entry.Columns = sameColumns(d.Schema.Fields, 0)
+	names := NormalizeNames(d.GetFieldNames())
+	indexes := make(map[string]*columnIndex, len(d.indexes))
+	for i := range d.Schema.Fields {
+		if idx, found := d.indexes[d.Schema.Fields[i].FieldName]; found {
+			indexes[names[i]] = idx
+		}
+		d.Schema.Fields[i].FieldName = names[i]
+		entry.Columns[i].Column = names[i]
+	}
+	if d.indexes != nil {
+		d.indexes = indexes
+	}
+	d.recordHistory(d, entry, nil)
+}
+
 // AddColumn adds a new column to the DataFrame.
 func (d *DataFrame) AddColumn(fieldname string, data Data) error {
 	if len(data) != d.Data.getNumberOfRows() {
@@ -77,6 +139,121 @@ func (d *DataFrame) AddColumn(fieldname string, data Data) error {
 	return nil
 }
 
+// CastColumn converts the values of a column to the given type, one of
+// string, int, float or decimal(precision,scale), and updates the schema.
+// Empty strings become nil.
+func (d *DataFrame) CastColumn(fieldname, fieldtype string) error {
+	f, err := ParseFieldType(fieldtype)
+	if err != nil {
+		return err
+	}
+	f.FieldName = fieldname
+	return d.castColumn(f)
+}
+
+// ApplySchema casts every column named in fields to the type of that field,
+// see ParseSchemaSpec.
+func (d *DataFrame) ApplySchema(fields []Field) error {
+	return d.applySchema(fields, d.castColumn)
+}
+
+// applySchema is ApplySchema with the columns given their type by set.
+func (d *DataFrame) applySchema(fields []Field, set func(Field) error) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	entry := startHistory("ApplySchema", nil, d)
+	for _, f := range fields {
+		if err := set(f); err != nil {
+			return err
+		}
+		entry.Args = append(entry.Args, f.FieldName+":"+f.TypeSpec())
+	}
+	entry.Columns = sameColumns(d.Schema.Fields, 0)
+	for i := range entry.Columns {
+		entry.Columns[i].Computed = slices.ContainsFunc(fields, func(f Field) bool { return f.FieldName == entry.Columns[i].Column })
+	}
+	d.recordHistory(d, entry, nil)
+	return nil
+}
+
+func (d *DataFrame) castColumn(f Field) error {
+	x := d.Schema.GetField(f.FieldName)
+	if x < 0 {
+		return fmt.Errorf("field %s not found", f.FieldName)
+	}
+	column := d.Data.getColumn(x)
+	newData := make(Data, len(column))
+	for i, v := range column {
+		value, err := castValue(v, f)
+		if err != nil {
+			return fmt.Errorf("column %s, row %d: %w", f.FieldName, i, err)
+		}
+		newData[i] = value
+	}
+	if err := d.Data.replaceColumn(x, newData); err != nil {
+		return err
+	}
+	return d.setColumnType(f)
+}
+
+// setColumnType sets the type of a column whose values have that type.
+func (d *DataFrame) setColumnType(f Field) error {
+	x := d.Schema.GetField(f.FieldName)
+	if x < 0 {
+		return fmt.Errorf("field %s not found", f.FieldName)
+	}
+	delete(d.indexes, f.FieldName)
+	d.Schema.Fields[x].FieldType = f.FieldType
+	d.Schema.Fields[x].Precision = f.Precision
+	d.Schema.Fields[x].Scale = f.Scale
+	return nil
+}
+
+// castValue converts a single value to the type described by f.
+func castValue(v any, f Field) (any, error) {
+	if s, ok := v.(string); v == nil || ok && s == "" {
+		return nil, nil
+	}
+	switch f.FieldType {
+	case "string":
+		return fmt.Sprint(v), nil
+	case "int":
+		switch n := v.(type) {
+		case string:
+			return strconv.Atoi(strings.TrimSpace(n))
+		case Decimal:
+			r, err := n.Rescale(0)
+			return int(r.Unscaled), err
+		default:
+			return runtime.ToInt(v), nil
+		}
+	case "float":
+		switch n := v.(type) {
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(n), 64)
+		case Decimal:
+			return n.Float64(), nil
+		default:
+			return runtime.ToFloat64(v), nil
+		}
+	case DecimalType:
+		dec, err := ToDecimal(v)
+		if err != nil {
+			return nil, err
+		}
+		dec, err = dec.Rescale(f.Scale)
+		if err != nil {
+			return nil, err
+		}
+		if f.Precision > 0 && pow10(f.Precision).CmpAbs(big.NewInt(dec.Unscaled)) <= 0 {
+			return nil, fmt.Errorf("value %v does not fit decimal(%d,%d)", v, f.Precision, f.Scale)
+		}
+		return dec, nil
+	}
+	return nil, fmt.Errorf("unknown field type %q", f.FieldType)
+}
+
 // DropColumn removes a column from the DataFrame.
 func (d *DataFrame) DropColumn(fieldname string) error {
 	x := d.Schema.GetField(fieldname)
@@ -85,6 +262,7 @@ func (d *DataFrame) DropColumn(fieldname string) error {
 	}
 	d.Schema.Fields = append(d.Schema.Fields[:x], d.Schema.Fields[x+1:]...)
 	d.Data.dropColumn(x)
+	delete(d.indexes, fieldname)
 	return nil
 }
 
@@ -114,18 +292,50 @@ func (d DataFrame) GetNumberOfRows() int {
 	return d.Data.getNumberOfRows()
 }
 
-// Get statistics for a DataFrame
+// Describe returns statistics for every column: count of values, nulls,
+// min, max and, for numeric columns, mean. min, max and mean are nil when
+// the column has no values to compute them from.
 func (d *DataFrame) Describe() map[string]interface{} {
 	stats := make(map[string]interface{})
 	for _, v := range d.Schema.Fields {
 		x := d.GetFieldNumber(v.FieldName)
 		field := d.Data.getColumn(x)
-		stats[v.FieldName] = map[string]interface{}{
-			"count": len(field),
-			"mean":  0,
-			"min":   0,
-			"max":   0,
+		count, nulls := 0, 0
+		minimum, maximum := &extremeAggregator{keep: func(c int) bool { return c < 0 }}, &extremeAggregator{keep: func(c int) bool { return c > 0 }}
+		mean := &meanAggregator{}
+		numeric, comparable := true, true
+		for _, value := range field {
+			if value == nil {
+				nulls++
+				continue
+			}
+			count++
+			if comparable && (minimum.add(value) != nil || maximum.add(value) != nil) {
+				comparable = false
+			}
+			switch value.(type) {
+			case int, int8, int16, int32, int64, uint8, uint16, uint32, float32, float64, Decimal:
+				if numeric && mean.add(value) != nil {
+					numeric = false
+				}
+			default:
+				numeric = false
+			}
+		}
+		column := map[string]interface{}{
+			"count": count,
+			"nulls": nulls,
+			"mean":  nil,
+			"min":   nil,
+			"max":   nil,
+		}
+		if comparable {
+			column["min"], column["max"] = minimum.result(), maximum.result()
+		}
+		if numeric {
+			column["mean"] = mean.result()
 		}
+		stats[v.FieldName] = column
 	}
 	return stats
 }
@@ -137,9 +347,9 @@ func (d *DataFrame) GenerateStats() {
 		field := d.Data.getColumn(x)
 		stats := make(map[string]interface{})
 		rlen := len(field)
-		d.metadata.Columns = rlen
-		d.metadata.RowStats = make(map[string]RowStat)
-		d.metadata.RowStats[v.FieldName] = stats
+		d.Metadata.Columns = rlen
+		d.Metadata.RowStats = make(map[string]RowStat)
+		d.Metadata.RowStats[v.FieldName] = stats
 	}
 }
 
@@ -151,6 +361,23 @@ func (d *DataFrame) GetFieldType(fieldname string) string {
 // Project creates a new DataFrame containing only the specified fields.
 // Returns an error if any of the fields are not found in the schema.
 func (d *DataFrame) Project(fields ...string) (*DataFrame, error) {
+	return d.ProjectContext(context.Background(), fields...)
+}
+
+// ProjectContext is Project in the trace of ctx.
+func (d *DataFrame) ProjectContext(ctx context.Context, fields ...string) (*DataFrame, error) {
+	_, span := startSpan(ctx, "Project", d, attribute.StringSlice("columns", fields))
+	entry := startHistory("Project", fields, d)
+	df, err := d.project(fields...)
+	if err == nil {
+		entry.Columns = sameColumns(df.Schema.Fields, 0)
+		d.recordHistory(df, entry, nil)
+	}
+	endSpan(span, df, err)
+	return df, err
+}
+
+func (d *DataFrame) project(fields ...string) (*DataFrame, error) {
 	_fields := make([]Field, 0)
 	_data := make([]*Data, 0)
 
@@ -179,58 +406,60 @@ func (d *DataFrame) Project(fields ...string) (*DataFrame, error) {
 }
 
 // UnionAll combines the current DataFrame with another DataFrame.
-// Returns an error if the schemas of the two DataFrames do not match.
+// Returns a *SchemaMismatchError if the schemas of the two DataFrames do not
+// match column by column.
 func (d *DataFrame) UnionAll(otherDF *DataFrame) (*DataFrame, error) {
-
-	other_df_field_len := len(otherDF.Schema.Fields)
-	current_df_field_len := len(d.Schema.Fields)
-	if other_df_field_len != current_df_field_len {
-		return nil, errors.New("columns does not match")
+	if err := d.Schema.MatchByPosition(&otherDF.Schema); err != nil {
+		return nil, err
 	}
-
-	for i, v := range d.Schema.Fields {
-		other_f := otherDF.Schema.Fields[i]
-		if v.FieldName != other_f.FieldName {
-			return nil, errors.New("column Names does not match")
-		}
-		if v.FieldType != other_f.FieldType {
-			return nil, errors.New("column types does not match")
-		}
-	}
-	// Ensure d.Data and otherDF.Data are cast to InternalDataStructure
-	dInternal, ok := d.Data.(*InternalDataStructure)
-	if !ok {
-		return nil, errors.New("d.Data is not of type *InternalDataStructure")
-	}
-	otherInternal, ok := otherDF.Data.(*InternalDataStructure)
-	if !ok {
-		return nil, errors.New("otherDF.Data is not of type *InternalDataStructure")
+	entry := startHistory("UnionAll", nil, d, otherDF)
+	entry.Columns = sameColumns(d.Schema.Fields, 0)
+	for i := range entry.Columns {
+		entry.Columns[i].From = append(entry.Columns[i].From, ColumnRef{Input: 1, Column: otherDF.Schema.Fields[i].FieldName})
 	}
 
 	// Combine data from both DataFrames
-	ds2 := make([]*Data, len(dInternal.Data))
-	copy(ds2, dInternal.Data)
+	ds2 := make([]*Data, len(d.Schema.Fields))
 	for i := range ds2 {
 		x := make([]any, 0)
-		x = append(x, (*ds2[i])...)
-		x = append(x, (*otherInternal.Data[i])...)
+		x = append(x, d.Data.getColumn(i)...)
+		x = append(x, otherDF.Data.getColumn(i)...)
 		ds2[i] = &x
 	}
 
 	// Return a new DataFrame with the combined data
-	return &DataFrame{
+	df := &DataFrame{
 		Schema: d.Schema,
 		Data: &InternalDataStructure{
 			Data:    ds2,
-			Rows:    dInternal.Rows + otherInternal.Rows,
-			Columns: dInternal.Columns,
+			Rows:    d.GetNumberOfRows() + otherDF.GetNumberOfRows(),
+			Columns: len(ds2),
 		},
-	}, nil
+	}
+	d.recordHistory(df, entry, otherDF)
+	return df, nil
 }
 
 // Join performs an inner join between two DataFrames based on the specified keys.
 // Returns a new DataFrame containing the joined data.
 func (d *DataFrame) Join(otherDF *DataFrame, keys []string) (*DataFrame, error) {
+	return d.JoinContext(context.Background(), otherDF, keys)
+}
+
+// JoinContext is Join that stops with ctx.Err() when ctx is done.
+func (d *DataFrame) JoinContext(ctx context.Context, otherDF *DataFrame, keys []string) (*DataFrame, error) {
+	ctx, span := startSpan(ctx, "Join", d, attribute.StringSlice("keys", keys), attribute.Int("right.rows", otherDF.GetNumberOfRows()))
+	entry := startHistory("Join", keys, d, otherDF)
+	df, err := d.join(ctx, otherDF, keys)
+	if err == nil {
+		entry.Columns = append(sameColumns(d.Schema.Fields, 0), sameColumns(otherDF.Schema.Fields, 1)...)
+		d.recordHistory(df, entry, otherDF)
+	}
+	endSpan(span, df, err)
+	return df, err
+}
+
+func (d *DataFrame) join(ctx context.Context, otherDF *DataFrame, keys []string) (*DataFrame, error) {
 	// Validate keys
 	if len(keys) == 0 {
 		return nil, errors.New("no keys provided for join")
@@ -247,20 +476,39 @@ func (d *DataFrame) Join(otherDF *DataFrame, keys []string) (*DataFrame, error)
 		keyIndices[i] = [2]int{leftIndex, rightIndex}
 	}
 
-	// Create a map for the right DataFrame to index rows by key values
+	// Reuse a hash index on one of the keys of the right DataFrame, or
+	// create a map for the right DataFrame to index rows by key values
+	var rightIndex *columnIndex
+	rightIndexKey := 0
+	for j, key := range keys {
+		if idx := otherDF.indexes[key]; idx != nil && idx.hash != nil {
+			rightIndex, rightIndexKey = idx, j
+			break
+		}
+	}
 	rightIndexMap := make(map[string][]Row)
-	for i := 0; i < otherDF.GetNumberOfRows(); i++ {
-		row := otherDF.getRow(i)
-		keyValues := make([]string, len(keys))
-		for j, indices := range keyIndices {
-			keyValues[j] = fmt.Sprintf("%v", (*row[indices[1]]))
+	if rightIndex == nil {
+		used := int64(0)
+		num_rows := otherDF.GetNumberOfRows()
+		for i := 0; i < num_rows; i++ {
+			if err := canceled(ctx, i); err != nil {
+				return nil, err
+			}
+			row := otherDF.getRow(i)
+			compoundKey := joinKey(row, keyIndices, 1)
+			rightIndexMap[compoundKey] = append(rightIndexMap[compoundKey], row)
+			// over the memory budget the join starts over by partitions
+			if used += int64(len(compoundKey)) + joinEntryBytes; overBudget(used) {
+				return d.graceJoin(ctx, otherDF, keyIndices, estimateTotal(used, i+1, num_rows))
+			}
 		}
-		compoundKey := strings.Join(keyValues, "|")
-		rightIndexMap[compoundKey] = append(rightIndexMap[compoundKey], row)
 	}
 
 	// Prepare schema and data for the resulting DataFrame
-	newFields := append(d.Schema.Fields, otherDF.Schema.Fields...)
+	newFields := append(slices.Clone(d.Schema.Fields), otherDF.Schema.Fields...)
+	for i := range newFields {
+		newFields[i].FieldPosition = i
+	}
 	newData := make([]*Data, len(newFields))
 	for i := range newData {
 		newData[i] = &Data{}
@@ -268,22 +516,33 @@ func (d *DataFrame) Join(otherDF *DataFrame, keys []string) (*DataFrame, error)
 
 	// Perform the join
 	for i := 0; i < d.GetNumberOfRows(); i++ {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
 		leftRow := d.getRow(i)
-		keyValues := make([]string, len(keys))
-		for j, indices := range keyIndices {
-			keyValues[j] = fmt.Sprintf("%v", (*leftRow[indices[0]]))
-		}
-		compoundKey := strings.Join(keyValues, "|")
-
-		if matchingRows, found := rightIndexMap[compoundKey]; found {
-			for _, rightRow := range matchingRows {
-				// Combine rows from both DataFrames
-				combinedRow := append(leftRow, rightRow...)
-				for j, value := range combinedRow {
-					(*newData[j]) = append((*newData[j]), *value)
+		compoundKey := joinKey(leftRow, keyIndices, 0)
+
+		matchingRows := rightIndexMap[compoundKey]
+		if rightIndex != nil {
+			matchingRows = nil
+			for _, r := range rightIndex.hash[valueKey(*leftRow[keyIndices[rightIndexKey][0]])] {
+				rightRow := otherDF.getRow(r)
+				matches := true
+				for _, indices := range keyIndices {
+					matches = matches && valueKey(*rightRow[indices[1]]) == valueKey(*leftRow[indices[0]])
+				}
+				if matches {
+					matchingRows = append(matchingRows, rightRow)
 				}
 			}
 		}
+		for _, rightRow := range matchingRows {
+			// Combine rows from both DataFrames
+			combinedRow := append(leftRow, rightRow...)
+			for j, value := range combinedRow {
+				(*newData[j]) = append((*newData[j]), *value)
+			}
+		}
 	}
 
 	// Return the new DataFrame
@@ -299,6 +558,113 @@ func (d *DataFrame) Join(otherDF *DataFrame, keys []string) (*DataFrame, error)
 	}, nil
 }
 
+// joinKey returns the key of a row of the left (side 0) or right (side 1)
+// DataFrame of a join.
+func joinKey(row Row, keyIndices [][2]int, side int) string {
+	keyValues := make([]string, len(keyIndices))
+	for j, indices := range keyIndices {
+		keyValues[j] = valueKey(*row[indices[side]])
+	}
+	return strings.Join(keyValues, "|")
+}
+
+// graceJoin is Join for right DataFrames whose index does not fit the
+// memory budget. The keys of both sides are split by hash into partitions
+// on disk and the partitions are joined one at a time, the rows come out in
+// the same order as from Join.
+func (d *DataFrame) graceJoin(ctx context.Context, otherDF *DataFrame, keyIndices [][2]int, estimate int64) (*DataFrame, error) {
+	s, err := newSpill("join")
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	n := partitionsFor(estimate)
+	right, err := s.keyPartitions(n)
+	if err != nil {
+		return nil, err
+	}
+	left, err := s.keyPartitions(n)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < otherDF.GetNumberOfRows(); i++ {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		if err := right.write(joinKey(otherDF.getRow(i), keyIndices, 1), i); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < d.GetNumberOfRows(); i++ {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		if err := left.write(joinKey(d.getRow(i), keyIndices, 0), i); err != nil {
+			return nil, err
+		}
+	}
+
+	pairs := make([][2]int, 0)
+	for p := 0; p < n; p++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		matches := make(map[string][]int)
+		err := right.read(p, func(key string, row int) error {
+			matches[key] = append(matches[key], row)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = left.read(p, func(key string, row int) error {
+			for _, r := range matches[key] {
+				pairs = append(pairs, [2]int{row, r})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the right rows of a left row are in order already
+	slices.SortStableFunc(pairs, func(a, b [2]int) int { return a[0] - b[0] })
+
+	newFields := append(slices.Clone(d.Schema.Fields), otherDF.Schema.Fields...)
+	for i := range newFields {
+		newFields[i].FieldPosition = i
+	}
+	newData := make([]*Data, len(newFields))
+	leftColumns := d.GetNumberOfColumns()
+	for j := range newData {
+		var column Data
+		if j < leftColumns {
+			column = d.Data.getColumn(j)
+		} else {
+			column = otherDF.Data.getColumn(j - leftColumns)
+		}
+		values := make(Data, len(pairs))
+		for i, pair := range pairs {
+			if j < leftColumns {
+				values[i] = column[pair[0]]
+			} else {
+				values[i] = column[pair[1]]
+			}
+		}
+		newData[j] = &values
+	}
+	return &DataFrame{
+		Schema: Schema{
+			Fields: newFields,
+		},
+		Data: &InternalDataStructure{
+			Data:    newData,
+			Rows:    len(pairs),
+			Columns: len(newData),
+		},
+	}, nil
+}
+
 // getRow retrieves a row from the DataFrame at the specified position.
 // Uses unsafe operations to access the data directly.
 func (d *DataFrame) getRow(position int) Row {
@@ -355,8 +721,11 @@ func (d *DataFrame) GetFieldNameByIndex(index int) string {
 	return d.Schema.Fields[index].FieldName
 }
 
-// AddFunction adds a custom function to the DataFrame's environment.
+// AddFunction adds a custom function to the DataFrame's environment. Only
+// the name crosses a pipe, the next operator binds it to the function
+// registered under the name with RegisterFunction.
 func (d *DataFrame) AddFunction(name string, f interface{}) {
+	d.addInitialFunctions()
 	d.functions[name] = f
 }
 
@@ -365,8 +734,52 @@ func (d *DataFrame) RemoveFunction(name string) {
 	delete(d.functions, name)
 }
 
+// registeredFunctions are the functions of RegisterFunction.
+var registeredFunctions sync.Map
+
+// RegisterFunction registers a function under a name, a DataFrame read from
+// a frame stream gets it for every function of the name it was sent with.
+func RegisterFunction(name string, f interface{}) {
+	registeredFunctions.Store(name, f)
+}
+
+// bindFunctions adds the registered functions of names, a name nothing is
+// registered under is kept without a function and passed on.
+func (d *DataFrame) bindFunctions(names []string) {
+	d.addInitialFunctions()
+	for _, name := range names {
+		f, _ := registeredFunctions.Load(name)
+		d.functions[name] = f
+	}
+}
+
+// functionNames returns the names of the functions of the DataFrame, sorted.
+func (d *DataFrame) functionNames() []string {
+	names := make([]string, 0, len(d.functions))
+	for name := range d.functions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// functionEnv returns the functions of the expression environment, without
+// the names that have none.
+func (d *DataFrame) functionEnv() map[string]interface{} {
+	env := make(map[string]interface{}, len(d.functions))
+	for name, f := range d.functions {
+		if f != nil {
+			env[name] = f
+		}
+	}
+	return env
+}
+
 // addInitialFunctions initializes the default functions for the DataFrame.
 func (d *DataFrame) addInitialFunctions() {
+	if d.functions != nil {
+		return
+	}
 	d.functions = make(map[string]interface{})
 	/*d.functions["contains"] = func(s, substr string) bool {
 		return strings.Contains(s, substr)
@@ -407,41 +820,211 @@ func (d *DataFrame) addInitialFunctions() {
 	*/
 }
 
+// checkRows is how many rows the context variants of the operations
+// process between checks of their context.
+const checkRows = 1024
+
+// canceled returns the error of a done context, checked every checkRows rows.
+func canceled(ctx context.Context, row int) error {
+	if row%checkRows != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// runChunks evaluates a row-wise program, see RowWise, on chunks of
+// checkRows rows of its column and checks ctx between them.
+func runChunks(ctx context.Context, program *vm.Program, env map[string]any, column string) (any, error) {
+	data, _ := env[column].(Data)
+	defer func() { env[column] = data }()
+	result := make([]any, 0, len(data))
+	var machine vm.VM
+	for start := 0; start < len(data) || start == 0; start += checkRows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		env[column] = data[start:min(start+checkRows, len(data))]
+		part, err := machine.Run(program, env)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := part.([]any)
+		if !ok {
+			return part, nil
+		}
+		result = append(result, values...)
+	}
+	return result, nil
+}
+
+// RowWise reports whether the statement maps a single column row by row,
+// as in map(col, int(#)), so that filtering or splitting the rows before or
+// after it gives the same result. Other statements may aggregate whole
+// columns or use the position of the rows with #index.
+func RowWise(statement string) bool {
+	tree, err := parseExpression(statement)
+	if err != nil {
+		return false
+	}
+	builtin, ok := tree.Node.(*ast.BuiltinNode)
+	if !ok || builtin.Name != "map" {
+		return false
+	}
+	v := &indexVisitor{}
+	ast.Walk(&tree.Node, v)
+	identifiers, _ := expressionIdentifiers(statement)
+	return len(identifiers) == 1 && !v.found
+}
+
+// indexVisitor finds #index in an expression.
+type indexVisitor struct {
+	found bool
+}
+
+func (v *indexVisitor) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.PointerNode); ok && n.Name == "index" {
+		v.found = true
+	}
+}
+
+// compileExpression compiles a Transform or Where expression against an
+// environment of column values.
+func compileExpression(value string, env map[string]any) (*vm.Program, error) {
+	return expr.Compile(value, append([]expr.Option{expr.Env(env), expr.Patch(columnRefPatcher{})}, decimalOptions()...)...)
+}
+
+// columnRefPatcher replaces col("name") with the column of that name, so that
+// expressions can use columns such as native.country whose names are not
+// identifiers. Backticks already quote raw strings in expressions.
+type columnRefPatcher struct{}
+
+func (columnRefPatcher) Visit(node *ast.Node) {
+	call, ok := (*node).(*ast.CallNode)
+	if !ok || len(call.Arguments) != 1 {
+		return
+	}
+	callee, ok := call.Callee.(*ast.IdentifierNode)
+	name, quoted := call.Arguments[0].(*ast.StringNode)
+	if ok && quoted && callee.Value == "col" {
+		ast.Patch(node, &ast.IdentifierNode{Value: name.Value})
+	}
+}
+
+// parseExpression parses an expression with its col("name") references
+// replaced by the columns.
+func parseExpression(value string) (*parser.Tree, error) {
+	tree, err := parser.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	ast.Walk(&tree.Node, columnRefPatcher{})
+	return tree, nil
+}
+
 type Visitor struct {
 	Identifiers []string
+	callees     map[ast.Node]bool
 }
 
 func (v *Visitor) Visit(node *ast.Node) {
-	if n, ok := (*node).(*ast.IdentifierNode); ok {
+	if n, ok := (*node).(*ast.IdentifierNode); ok && !v.callees[n] {
 		v.Identifiers = append(v.Identifiers, n.Value)
 	}
 }
 
+// calleeVisitor collects the identifiers that name a called function, such
+// as decimal in decimal(#, 2), so they are not taken for columns.
+type calleeVisitor struct {
+	callees map[ast.Node]bool
+}
+
+func (v *calleeVisitor) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.CallNode); ok {
+		v.callees[n.Callee] = true
+	}
+}
+
+// expressionIdentifiers returns the identifiers used in an expression as
+// written, in the order they appear, leaving out called functions.
+func expressionIdentifiers(value string) ([]string, error) {
+	tree, err := parseExpression(value)
+	if err != nil {
+		return nil, err
+	}
+	c := &calleeVisitor{callees: make(map[ast.Node]bool)}
+	ast.Walk(&tree.Node, c)
+	v := &Visitor{callees: c.callees}
+	ast.Walk(&tree.Node, v)
+	return v.Identifiers, nil
+}
+
 func (d *DataFrame) Transform(value string) error {
+	return d.TransformContext(context.Background(), value)
+}
+
+// TransformContext is Transform that returns ctx.Err() when ctx is done,
+// the DataFrame is left unchanged. Row-wise statements, see RowWise, are
+// evaluated in chunks of rows with ctx checked between them, other
+// statements in one go once ctx has been checked.
+func (d *DataFrame) TransformContext(ctx context.Context, value string) error {
+	ctx, span := startSpan(ctx, "Transform", d, attribute.String("statement", value))
+	entry := startHistory("Transform", []string{value}, d)
+	// the target is computed from the columns in the statement as they were
+	from := make([]ColumnRef, 0)
+	identifiers, _ := expressionIdentifiers(value)
+	for _, name := range identifiers {
+		if d.GetFieldNumber(name) >= 0 && !slices.Contains(from, ColumnRef{Column: name}) {
+			from = append(from, ColumnRef{Column: name})
+		}
+	}
+	err := d.transform(ctx, value)
+	if err == nil {
+		entry.Columns = sameColumns(d.Schema.Fields, 0)
+		for i := range entry.Columns {
+			if entry.Columns[i].Column == identifiers[len(identifiers)-1] {
+				entry.Columns[i].From, entry.Columns[i].Computed = from, true
+			}
+		}
+		d.recordHistory(d, entry, nil)
+	}
+	endSpan(span, d, err)
+	return err
+}
+
+func (d *DataFrame) transform(ctx context.Context, value string) error {
 	d.addInitialFunctions()
 	// Define the environment for the expression
 	num_fields := len(d.Schema.Fields)
 	env := map[string]interface{}{
-		"functions": d.functions,
+		"functions": d.functionEnv(),
 	}
 
 	for x := 0; x < num_fields; x++ {
 		env[d.GetFieldNameByIndex(x)] = d.GetColumnByIndex(x)
 	}
 	// Compile the expression
-	program, err := expr.Compile(value, expr.Env(env))
+	program, err := compileExpression(value, env)
+	if err != nil {
+		return err
+	}
+	// Walk the expression as written, operator overloading replaces operators
+	// with calls to the decimal functions in the compiled program.
+	identifiers, err := expressionIdentifiers(value)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	target := identifiers[len(identifiers)-1]
 	// Evaluate the expression
-	result, err := expr.Run(program, env)
+	var result any
+	if RowWise(value) {
+		result, err = runChunks(ctx, program, env, target)
+	} else if err = ctx.Err(); err == nil {
+		result, err = expr.Run(program, env)
+	}
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	node := program.Node()
-	v := &Visitor{}
-	ast.Walk(&node, v)
-	idx := d.GetFieldNumber(v.Identifiers[len(v.Identifiers)-1])
+	idx := d.GetFieldNumber(target)
 	resultData, ok := result.([]any)
 	if !ok {
 		new_data := make(Data, d.Data.getNumberOfRows())
@@ -451,9 +1034,15 @@ func (d *DataFrame) Transform(value string) error {
 		resultData = new_data
 	}
 	if idx < 0 {
-		d.AddColumn(v.Identifiers[len(v.Identifiers)-1], resultData)
+		d.AddColumn(target, resultData)
+		idx = len(d.Schema.Fields) - 1
 	} else {
 		d.Data.replaceColumn(idx, resultData)
+		delete(d.indexes, d.Schema.Fields[idx].FieldName)
+	}
+	if scale, ok := decimalColumnScale(resultData); ok {
+		d.Schema.Fields[idx].FieldType = DecimalType
+		d.Schema.Fields[idx].Scale = scale
 	}
 
 	return nil
@@ -462,26 +1051,59 @@ func (d *DataFrame) Transform(value string) error {
 // Where filters the DataFrame based on a condition applied to a specific field.
 // Returns a new DataFrame containing only the rows that satisfy the condition.
 func (d *DataFrame) Where(value string) (*DataFrame, error) {
+	return d.WhereContext(context.Background(), value)
+}
+
+// WhereContext is Where that stops with ctx.Err() when ctx is done.
+func (d *DataFrame) WhereContext(ctx context.Context, value string) (*DataFrame, error) {
+	ctx, span := startSpan(ctx, "Where", d, attribute.String("condition", value))
+	entry := startHistory("Where", []string{value}, d)
+	df, err := d.where(ctx, value)
+	if err == nil {
+		entry.Columns = sameColumns(df.Schema.Fields, 0)
+		d.recordHistory(df, entry, nil)
+	}
+	endSpan(span, df, err)
+	return df, err
+}
+
+func (d *DataFrame) where(ctx context.Context, value string) (*DataFrame, error) {
 	d.addInitialFunctions()
 	// Define the environment for the expression
 	num_fields := len(d.Schema.Fields)
 	num_rows := d.GetNumberOfRows()
 	rows := make(map[int]bool, 0)
+	functions := d.functionEnv()
 	env := map[string]any{
-		"functions": d.functions,
+		"functions": functions,
+	}
+	if num_rows == 0 {
+		return d.takeRows(nil), nil
 	}
 	// Compile the expression
 	r := d.Data.getRow(0)
 	for x := 0; x < num_fields; x++ {
 		env[d.GetFieldNameByIndex(x)] = *r[x]
 	}
-	program, err := expr.Compile(value, expr.Env(env))
+	program, err := compileExpression(value, env)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	for i := 0; i < num_rows; i++ {
+	// Only evaluate the rows an index narrows the condition down to
+	candidates, indexed := d.whereCandidates(value)
+	if indexed {
+		num_rows = len(candidates)
+	}
+	for c := 0; c < num_rows; c++ {
+		if err := canceled(ctx, c); err != nil {
+			return nil, err
+		}
+		i := c
+		if indexed {
+			i = candidates[c]
+		}
 		env := map[string]interface{}{
-			"functions": d.functions,
+			"functions": functions,
 		}
 		r = d.Data.getRow(i)
 		for x := 0; x < num_fields; x++ {
@@ -531,7 +1153,8 @@ func (d *DataFrame) Count() int {
 	return d.Data.getNumberOfRows()
 }
 
-// Distinct returns a list of unique values in the specified field.
+// Distinct returns a list of unique values in the specified field, in the
+// order they first appear.
 func (d *DataFrame) Distinct(fieldname string) []any {
 	x := d.Schema.GetField(fieldname)
 	if x < 0 {
@@ -545,14 +1168,166 @@ func (d *DataFrame) Distinct(fieldname string) []any {
 	if len(field) == 0 {
 		return nil
 	}
-	// Iterate over the field and add unique values to the indexer
+	// Iterate over the field and keep the values not seen before
+	keys := make([]any, 0)
 	for _, v := range field {
-		indexer[v] = true
+		if !indexer[v] {
+			indexer[v] = true
+			keys = append(keys, v)
+		}
 	}
+	return keys
+}
 
-	keys := make([]any, 0)
-	for k := range indexer {
-		keys = append(keys, k)
+// rowKey builds a string key from the values of a row at the given column
+// positions, used to compare rows by value.
+func rowKey(row Row, indices []int) string {
+	keyValues := make([]string, len(indices))
+	for i, x := range indices {
+		keyValues[i] = valueKey(*row[x])
+	}
+	return strings.Join(keyValues, "\x1f")
+}
+
+// DistinctRows returns a new DataFrame with the unique combinations of the
+// specified fields, or of all fields when none are given, in the order they
+// first appear.
+func (d *DataFrame) DistinctRows(fields ...string) (*DataFrame, error) {
+	if len(fields) == 0 {
+		fields = d.GetFieldNames()
+	}
+	projected, err := d.project(fields...)
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]int, len(fields))
+	for i := range indices {
+		indices[i] = i
+	}
+	seen := make(map[string]bool)
+	newData := make([]*Data, len(fields))
+	for i := range newData {
+		newData[i] = &Data{}
+	}
+	used := int64(0)
+	num_rows := projected.GetNumberOfRows()
+	for i := 0; i < num_rows; i++ {
+		row := projected.getRow(i)
+		key := rowKey(row, indices)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		// over the memory budget the rows are made distinct by partitions
+		if used += int64(len(key)) + distinctEntryBytes; overBudget(used) {
+			s, err := newSpill("distinct")
+			if err != nil {
+				return nil, err
+			}
+			defer s.close()
+			keyOf := func(row int) string { return rowKey(projected.getRow(row), indices) }
+			visit := func(struct{}, int) (struct{}, error) { return struct{}{}, nil }
+			firsts, _, err := firstRows(context.Background(), s, partitionsFor(estimateTotal(used, i+1, num_rows)), num_rows, keyOf, visit)
+			if err != nil {
+				return nil, err
+			}
+			return projected.takeRows(firsts), nil
+		}
+		for j, value := range row {
+			(*newData[j]) = append((*newData[j]), *value)
+		}
+	}
+	return &DataFrame{
+		Schema: projected.Schema,
+		Data: &InternalDataStructure{
+			Data:    newData,
+			Rows:    len(*newData[0]),
+			Columns: len(newData),
+		},
+	}, nil
+}
+
+// ValueCounts returns a new DataFrame with the unique values of the
+// specified field and a count column with the number of rows holding each
+// value, sorted by descending count.
+func (d *DataFrame) ValueCounts(fieldname string) (*DataFrame, error) {
+	x := d.Schema.GetField(fieldname)
+	if x < 0 {
+		return nil, fmt.Errorf("field %s not found", fieldname)
+	}
+	// values are counted by their valueKey, like the spilled counts
+	counts := make(map[string]int)
+	keys := make([]string, 0)
+	firsts := make(map[string]any)
+	column := d.Data.getColumn(x)
+	used := int64(0)
+	for i, v := range column {
+		key := valueKey(v)
+		if _, found := counts[key]; !found {
+			keys = append(keys, key)
+			firsts[key] = v
+			// over the memory budget the values are counted by partitions
+			if used += distinctEntryBytes + int64(len(key)); overBudget(used) {
+				return d.valueCountsSpilled(x, estimateTotal(used, i+1, len(column)))
+			}
+		}
+		counts[key]++
+	}
+	// A stable sort keeps values with the same count in order of appearance
+	slices.SortStableFunc(keys, func(a, b string) int {
+		return counts[b] - counts[a]
+	})
+	values := make(Data, len(keys))
+	countData := make(Data, len(keys))
+	for i, key := range keys {
+		values[i], countData[i] = firsts[key], counts[key]
+	}
+	return d.valueCountsFrame(x, values, countData), nil
+}
+
+// valueCountsSpilled is ValueCounts for columns whose values do not fit the
+// memory budget, they are counted one partition at a time.
+func (d *DataFrame) valueCountsSpilled(x int, estimate int64) (*DataFrame, error) {
+	s, err := newSpill("distinct")
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	column := d.Data.getColumn(x)
+	keyOf := func(row int) string { return valueKey(column[row]) }
+	count := func(n int, _ int) (int, error) { return n + 1, nil }
+	firsts, counts, err := firstRows(context.Background(), s, partitionsFor(estimate), len(column), keyOf, count)
+	if err != nil {
+		return nil, err
+	}
+	order := make([]int, len(firsts))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return counts[b] - counts[a] })
+	values := make(Data, len(order))
+	countData := make(Data, len(order))
+	for i, j := range order {
+		values[i], countData[i] = column[firsts[j]], counts[j]
+	}
+	return d.valueCountsFrame(x, values, countData), nil
+}
+
+// valueCountsFrame returns the result of ValueCounts.
+func (d *DataFrame) valueCountsFrame(x int, values, countData Data) *DataFrame {
+	field := d.Schema.Fields[x]
+	field.FieldPosition = 0
+	return &DataFrame{
+		Schema: Schema{
+			Fields: []Field{
+				field,
+				{FieldName: "count", FieldPosition: 1, FieldType: "int"},
+			},
+		},
+		Data: &InternalDataStructure{
+			Data:    []*Data{&values, &countData},
+			Rows:    len(values),
+			Columns: 2,
+		},
 	}
-	return keys
 }
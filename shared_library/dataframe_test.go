@@ -0,0 +1,75 @@
+package sharedlibrary
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDistinct(t *testing.T) {
+	d := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "zone"},
+		[]string{"2", "a"}, []string{"1", "b"}, []string{"2", "a"}, []string{"", "b"}, []string{"1", "c"}, []string{"", "b"})
+
+	if got, want := d.Distinct("Vendor_id"), []any{2, 1, nil}; !slices.Equal(got, want) {
+		t.Errorf("Distinct = %v, want %v", got, want)
+	}
+	if got := d.Distinct("missing"); got != nil {
+		t.Errorf("Distinct of a missing column = %v, want nil", got)
+	}
+
+	df, err := d.DistinctRows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, df), []string{"Vendor_id,zone", "2,a", "1,b", "<nil>,b", "1,c"}; !slices.Equal(got, want) {
+		t.Errorf("DistinctRows() = %v, want %v", got, want)
+	}
+	df, err = d.DistinctRows("zone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, df), []string{"zone", "a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("DistinctRows(zone) = %v, want %v", got, want)
+	}
+	if _, err := d.DistinctRows("missing"); err == nil {
+		t.Error("DistinctRows of a missing column did not fail")
+	}
+}
+
+func TestValueCounts(t *testing.T) {
+	d := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id"},
+		[]string{"2"}, []string{"1"}, []string{""}, []string{"1"}, []string{"2"}, []string{"1"}, []string{"3"})
+
+	df, err := d.ValueCounts("Vendor_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// values with the same count keep the order they first appear in
+	if got, want := rows(t, df), []string{"Vendor_id,count", "1,3", "2,2", "<nil>,1", "3,1"}; !slices.Equal(got, want) {
+		t.Errorf("ValueCounts = %v, want %v", got, want)
+	}
+	if got, want := df.GetFieldTypes(), []string{"int", "int"}; !slices.Equal(got, want) {
+		t.Errorf("types = %v, want %v", got, want)
+	}
+	if _, err := d.ValueCounts("missing"); err == nil {
+		t.Error("ValueCounts of a missing column did not fail")
+	}
+}
+
+func TestValueCountsKeys(t *testing.T) {
+	t.Cleanup(func() { SetMemoryBudget(0) })
+	// equal decimals of different scales are one value, and values that
+	// can not be map keys, like the lists of a transform, are counted too
+	column := Data{NewDecimal(15, 1), NewDecimal(150, 2), []any{1, 2}, []any{1, 2}, NewDecimal(2, 0)}
+	d := NewDataFrameWithArgs([]Field{{FieldName: "v", FieldType: "any"}}, []*Data{&column})
+	want := []string{"v,count", "1.5,2", "[1 2],2", "2,1"}
+	for _, budget := range []int64{0, 1} {
+		SetMemoryBudget(budget)
+		df, err := d.ValueCounts("v")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rows(t, df); !slices.Equal(got, want) {
+			t.Errorf("ValueCounts with a budget of %d = %v, want %v", budget, got, want)
+		}
+	}
+}
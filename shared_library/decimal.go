@@ -0,0 +1,364 @@
+package sharedlibrary
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm/runtime"
+)
+
+// decimalDivisionScale is the number of extra digits kept after the point
+// when dividing two decimals.
+const decimalDivisionScale = 4
+
+// Decimal is a fixed-point number stored as an unscaled integer and a scale,
+// so that 12.50 is {Unscaled: 1250, Scale: 2}. It is used for monetary
+// columns where float arithmetic would accumulate rounding errors.
+type Decimal struct {
+	Unscaled int64
+	Scale    int32
+}
+
+func init() {
+	// Decimals travel inside Data ([]any), so gob has to know the type.
+	gob.Register(Decimal{})
+}
+
+// NewDecimal creates a Decimal from an unscaled value and a scale.
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	return Decimal{Unscaled: unscaled, Scale: scale}
+}
+
+// ParseDecimal parses a plain decimal string such as "-12.50".
+// The scale of the result is the number of digits after the point.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Decimal{}, errors.New("empty decimal value")
+	}
+	digits := s
+	sign := ""
+	if digits[0] == '-' || digits[0] == '+' {
+		sign = digits[:1]
+		digits = digits[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal value %q", s)
+	}
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal value %q", s)
+		}
+	}
+	unscaled, err := strconv.ParseInt(sign+intPart+fracPart, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("decimal value %q out of range", s)
+	}
+	return Decimal{Unscaled: unscaled, Scale: int32(len(fracPart))}, nil
+}
+
+// DecimalFromFloat converts a float to the shortest Decimal that
+// represents it exactly when printed.
+func DecimalFromFloat(f float64) (Decimal, error) {
+	return ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// ToDecimal converts integers, floats, strings and Decimals to a Decimal.
+func ToDecimal(value any) (Decimal, error) {
+	switch v := value.(type) {
+	case Decimal:
+		return v, nil
+	case int:
+		return Decimal{Unscaled: int64(v)}, nil
+	case int8:
+		return Decimal{Unscaled: int64(v)}, nil
+	case int16:
+		return Decimal{Unscaled: int64(v)}, nil
+	case int32:
+		return Decimal{Unscaled: int64(v)}, nil
+	case int64:
+		return Decimal{Unscaled: v}, nil
+	case uint8:
+		return Decimal{Unscaled: int64(v)}, nil
+	case uint16:
+		return Decimal{Unscaled: int64(v)}, nil
+	case uint32:
+		return Decimal{Unscaled: int64(v)}, nil
+	case float32:
+		return DecimalFromFloat(float64(v))
+	case float64:
+		return DecimalFromFloat(v)
+	case string:
+		return ParseDecimal(v)
+	}
+	return Decimal{}, fmt.Errorf("cannot convert %T to decimal", value)
+}
+
+// String formats the decimal with exactly Scale digits after the point.
+func (d Decimal) String() string {
+	if d.Scale <= 0 {
+		return strconv.FormatInt(d.Unscaled, 10)
+	}
+	s := new(big.Int).Abs(big.NewInt(d.Unscaled)).String()
+	if len(s) <= int(d.Scale) {
+		s = strings.Repeat("0", int(d.Scale)-len(s)+1) + s
+	}
+	point := len(s) - int(d.Scale)
+	s = s[:point] + "." + s[point:]
+	if d.Unscaled < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// Float64 returns the nearest float to the decimal.
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(big.NewInt(d.Unscaled), pow10(d.Scale)).Float64()
+	return f
+}
+
+// Rescale returns the decimal with the given scale. Digits that no longer
+// fit are rounded half away from zero.
+func (d Decimal) Rescale(scale int32) (Decimal, error) {
+	if scale == d.Scale {
+		return d, nil
+	}
+	v := big.NewInt(d.Unscaled)
+	if scale > d.Scale {
+		v.Mul(v, pow10(scale-d.Scale))
+	} else {
+		v = roundDiv(v, pow10(d.Scale-scale))
+	}
+	return fromBig(v, scale)
+}
+
+// Add returns d + other.
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	a, b, err := alignDecimals(d, other)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return fromBig(new(big.Int).Add(big.NewInt(a.Unscaled), big.NewInt(b.Unscaled)), a.Scale)
+}
+
+// Sub returns d - other.
+func (d Decimal) Sub(other Decimal) (Decimal, error) {
+	return d.Add(other.Neg())
+}
+
+// Mul returns d * other. The scale of the result is the sum of the scales.
+func (d Decimal) Mul(other Decimal) (Decimal, error) {
+	v := new(big.Int).Mul(big.NewInt(d.Unscaled), big.NewInt(other.Unscaled))
+	return fromBig(v, d.Scale+other.Scale)
+}
+
+// Div returns d / other rounded to the larger of the two scales plus
+// decimalDivisionScale digits.
+func (d Decimal) Div(other Decimal) (Decimal, error) {
+	if other.Unscaled == 0 {
+		return Decimal{}, errors.New("decimal division by zero")
+	}
+	scale := max(d.Scale, other.Scale) + decimalDivisionScale
+	// d/other = (d.Unscaled * 10^(scale - d.Scale + other.Scale)) / other.Unscaled / 10^scale
+	num := new(big.Int).Mul(big.NewInt(d.Unscaled), pow10(scale-d.Scale+other.Scale))
+	return fromBig(roundDiv(num, big.NewInt(other.Unscaled)), scale)
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{Unscaled: -d.Unscaled, Scale: d.Scale}
+}
+
+// Cmp compares two decimals and returns -1, 0 or +1.
+func (d Decimal) Cmp(other Decimal) int {
+	a := new(big.Int).Mul(big.NewInt(d.Unscaled), pow10(max(d.Scale, other.Scale)-d.Scale))
+	b := new(big.Int).Mul(big.NewInt(other.Unscaled), pow10(max(d.Scale, other.Scale)-other.Scale))
+	return a.Cmp(b)
+}
+
+func alignDecimals(a, b Decimal) (Decimal, Decimal, error) {
+	scale := max(a.Scale, b.Scale)
+	a, err := a.Rescale(scale)
+	if err != nil {
+		return a, b, err
+	}
+	b, err = b.Rescale(scale)
+	return a, b, err
+}
+
+func fromBig(v *big.Int, scale int32) (Decimal, error) {
+	if !v.IsInt64() {
+		return Decimal{}, errors.New("decimal overflow")
+	}
+	return Decimal{Unscaled: v.Int64(), Scale: scale}, nil
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundDiv divides a by b rounding half away from zero.
+func roundDiv(a, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(new(big.Int).Abs(b)) >= 0 {
+		if a.Sign()*b.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// decimalOperands reports whether a binary operation involves a decimal and,
+// if so, converts both operands. Operations with nil are left to the
+// callers, they are not decimal operations.
+func decimalOperands(a, b any) (Decimal, Decimal, bool, error) {
+	if !isDecimal(a, b) || a == nil || b == nil {
+		return Decimal{}, Decimal{}, false, nil
+	}
+	if _, ok := a.(string); ok {
+		return Decimal{}, Decimal{}, true, fmt.Errorf("invalid operation between string and decimal")
+	}
+	if _, ok := b.(string); ok {
+		return Decimal{}, Decimal{}, true, fmt.Errorf("invalid operation between decimal and string")
+	}
+	x, err := ToDecimal(a)
+	if err != nil {
+		return x, Decimal{}, true, err
+	}
+	y, err := ToDecimal(b)
+	return x, y, true, err
+}
+
+// isDecimal reports whether an operand of a binary operation is a Decimal,
+// only then the operation is overloaded.
+func isDecimal(a, b any) bool {
+	_, aok := a.(Decimal)
+	_, bok := b.(Decimal)
+	return aok || bok
+}
+
+func decimalArithmetic(op func(x, y Decimal) (Decimal, error), fallback func(a, b any) any) func(params ...any) (any, error) {
+	return func(params ...any) (any, error) {
+		if !isDecimal(params[0], params[1]) {
+			return fallback(params[0], params[1]), nil
+		}
+		// a missing value, an empty CSV field of a decimal column, makes the
+		// result missing
+		if params[0] == nil || params[1] == nil {
+			return nil, nil
+		}
+		x, y, _, err := decimalOperands(params[0], params[1])
+		if err != nil {
+			return nil, err
+		}
+		return op(x, y)
+	}
+}
+
+// decimalComparison returns a comparison of decimals, missing is its result
+// when a decimal is compared with a missing value.
+func decimalComparison(test func(c int) bool, fallback func(a, b any) bool, missing bool) func(params ...any) (any, error) {
+	return func(params ...any) (any, error) {
+		if !isDecimal(params[0], params[1]) {
+			return fallback(params[0], params[1]), nil
+		}
+		if params[0] == nil || params[1] == nil {
+			return missing, nil
+		}
+		x, y, _, err := decimalOperands(params[0], params[1])
+		if err != nil {
+			return nil, err
+		}
+		return test(x.Cmp(y)), nil
+	}
+}
+
+// compareValues compares two values of a column and returns -1, 0 or +1.
+// Decimals are compared exactly, other values with the expr runtime.
+func compareValues(a, b any) (c int, err error) {
+	if x, y, ok, derr := decimalOperands(a, b); ok {
+		if derr != nil {
+			return 0, derr
+		}
+		return x.Cmp(y), nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot compare %v (%T) and %v (%T)", a, a, b, b)
+		}
+	}()
+	switch {
+	case runtime.Less(a, b):
+		return -1, nil
+	case runtime.More(a, b):
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// decimalOptions returns the expr options that make arithmetic and
+// comparisons exact when one of the operands is a Decimal, and that add the
+// decimal(value[, scale]) conversion function. Operations without decimals
+// fall back to the regular expr runtime. Arithmetic of a decimal with nil is
+// nil and orderings of a decimal with nil are false, so that the missing
+// values of decimal columns do not fail them.
+func decimalOptions() []expr.Option {
+	arithmetic := new(func(any, any) any)
+	comparison := new(func(any, any) bool)
+	return []expr.Option{
+		expr.Function("decimal", func(params ...any) (any, error) {
+			d, err := ToDecimal(params[0])
+			if err != nil || len(params) == 1 {
+				return d, err
+			}
+			return d.Rescale(int32(params[1].(int)))
+		}, new(func(any) Decimal), new(func(any, int) Decimal)),
+		expr.Function("decimalAdd", decimalArithmetic(Decimal.Add, runtime.Add), arithmetic),
+		expr.Function("decimalSub", decimalArithmetic(Decimal.Sub, runtime.Subtract), arithmetic),
+		expr.Function("decimalMul", decimalArithmetic(Decimal.Mul, runtime.Multiply), arithmetic),
+		expr.Function("decimalDiv", decimalArithmetic(Decimal.Div, func(a, b any) any { return runtime.Divide(a, b) }), arithmetic),
+		expr.Function("decimalEqual", decimalComparison(func(c int) bool { return c == 0 }, runtime.Equal, false), comparison),
+		expr.Function("decimalNotEqual", decimalComparison(func(c int) bool { return c != 0 }, func(a, b any) bool { return !runtime.Equal(a, b) }, true), comparison),
+		expr.Function("decimalLess", decimalComparison(func(c int) bool { return c < 0 }, runtime.Less, false), comparison),
+		expr.Function("decimalLessOrEqual", decimalComparison(func(c int) bool { return c <= 0 }, runtime.LessOrEqual, false), comparison),
+		expr.Function("decimalMore", decimalComparison(func(c int) bool { return c > 0 }, runtime.More, false), comparison),
+		expr.Function("decimalMoreOrEqual", decimalComparison(func(c int) bool { return c >= 0 }, runtime.MoreOrEqual, false), comparison),
+		expr.Operator("+", "decimalAdd"),
+		expr.Operator("-", "decimalSub"),
+		expr.Operator("*", "decimalMul"),
+		expr.Operator("/", "decimalDiv"),
+		expr.Operator("==", "decimalEqual"),
+		expr.Operator("!=", "decimalNotEqual"),
+		expr.Operator("<", "decimalLess"),
+		expr.Operator("<=", "decimalLessOrEqual"),
+		expr.Operator(">", "decimalMore"),
+		expr.Operator(">=", "decimalMoreOrEqual"),
+	}
+}
+
+// decimalColumnScale reports whether every non-nil value in data is a
+// Decimal, and the largest scale among them.
+func decimalColumnScale(data Data) (int32, bool) {
+	found := false
+	var scale int32
+	for _, v := range data {
+		if v == nil {
+			continue
+		}
+		dec, ok := v.(Decimal)
+		if !ok {
+			return 0, false
+		}
+		found = true
+		scale = max(scale, dec.Scale)
+	}
+	return scale, found
+}
@@ -0,0 +1,318 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestMissingValuesInExpressions(t *testing.T) {
+	d := newTestFrame(t, "Fare_amount:decimal(10,2)", []string{"id", "Fare_amount"}, []string{"1", "12.50"}, []string{"2", ""}, []string{"3", "3.00"})
+
+	// a decimal compared with a missing value is neither more nor less
+	for cond, ids := range map[string][]string{
+		"Fare_amount > decimal(5)":  {"1"},
+		"Fare_amount <= decimal(5)": {"3"},
+		"Fare_amount == nil":        {"2"},
+		"Fare_amount != nil":        {"1", "3"},
+	} {
+		df, err := d.Where(cond)
+		if err != nil {
+			t.Fatalf("Where(%s): %v", cond, err)
+		}
+		if got := columnStrings(t, df, "id"); !slices.Equal(got, ids) {
+			t.Errorf("Where(%s) = ids %v, want %v", cond, got, ids)
+		}
+	}
+
+	if err := d.Transform("map(Fare_amount, # * decimal(2))"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := columnStrings(t, d, "Fare_amount"), []string{"25.00", "<nil>", "6.00"}; !slices.Equal(got, want) {
+		t.Errorf("doubled = %v, want %v", got, want)
+	}
+	if err := d.Transform("reduce(Fare_amount, #acc + #, decimal(0))"); err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, d, "Fare_amount"); got[0] != "<nil>" {
+		t.Errorf("sum with a missing value = %v, want <nil>", got[0])
+	}
+}
+
+func TestExpressionsWithoutDecimals(t *testing.T) {
+	d := newTestFrame(t, "households:int,income:float", []string{"households", "income", "zone"},
+		[]string{"1", "1.5", "a"}, []string{"2", "2", "b"}, []string{"3", "4", "c"})
+
+	// the operators of expr, not the decimal ones, with their result types
+	for _, tt := range []struct{ statement, column, want string }{
+		{"reduce(households, #acc + #, 0)", "households", "6"},
+		{"map(households, # / 2)", "households", "0.5"},
+		{"map(income, # * 2 - 1)", "income", "2"},
+		{"map(zone, # + '!')", "zone", "a!"},
+	} {
+		df, err := d.Project("households", "income", "zone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := df.Transform(tt.statement); err != nil {
+			t.Fatalf("%s: %v", tt.statement, err)
+		}
+		x := df.GetFieldNumber(tt.column)
+		v, _ := df.GetPositionValue(x, 0)
+		if _, isDecimal := v.(Decimal); isDecimal || fmt.Sprint(v) != tt.want {
+			t.Errorf("%s = %v (%T), want %s", tt.statement, v, v, tt.want)
+		}
+	}
+	for cond, want := range map[string][]string{
+		"households == 2.0":     {"b"},
+		"income > households":   {"a", "c"},
+		"zone >= 'b'":           {"b", "c"},
+		"households * 2 != 4.0": {"a", "c"},
+	} {
+		df, err := d.Where(cond)
+		if err != nil {
+			t.Fatalf("Where(%s): %v", cond, err)
+		}
+		if got := columnStrings(t, df, "zone"); !slices.Equal(got, want) {
+			t.Errorf("Where(%s) = %v, want %v", cond, got, want)
+		}
+	}
+
+	// missing values of other columns fail arithmetic and orderings as they
+	// always did
+	m := newTestFrame(t, "households:int", []string{"households"}, []string{"1"}, []string{""})
+	for _, statement := range []string{"reduce(households, #acc + #, 0)", "map(households, # * 2)"} {
+		if err := m.Transform(statement); err == nil {
+			t.Errorf("%s with a missing value succeeded", statement)
+		}
+	}
+	if _, err := m.Where("households > 0"); err == nil {
+		t.Error("ordering a missing value succeeded")
+	}
+	if df, err := m.Where("households == nil"); err != nil || df.GetNumberOfRows() != 1 {
+		t.Errorf("Where(households == nil) = %v, %v", df, err)
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Decimal
+	}{
+		{"12.50", NewDecimal(1250, 2)},
+		{"-0.05", NewDecimal(-5, 2)},
+		{"+7", NewDecimal(7, 0)},
+		{" .5 ", NewDecimal(5, 1)},
+		{"3.", NewDecimal(3, 0)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "-", ".", "1.2.3", "1e5", "abc", "99999999999999999999"} {
+		if got, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	for d, want := range map[Decimal]string{
+		NewDecimal(1250, 2):  "12.50",
+		NewDecimal(-5, 2):    "-0.05",
+		NewDecimal(5, 3):     "0.005",
+		NewDecimal(42, 0):    "42",
+		NewDecimal(-100, 1):  "-10.0",
+		NewDecimal(0, 2):     "0.00",
+		NewDecimal(123, -1):  "123",
+		NewDecimal(-999, 3):  "-0.999",
+		NewDecimal(1000, 3):  "1.000",
+		NewDecimal(-1000, 3): "-1.000",
+	} {
+		if got := d.String(); got != want {
+			t.Errorf("%#v.String() = %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, b := NewDecimal(1250, 2), NewDecimal(3, 1) // 12.50 and 0.3
+	tests := []struct {
+		name string
+		op   func(Decimal, Decimal) (Decimal, error)
+		want string
+	}{
+		{"Add", Decimal.Add, "12.80"},
+		{"Sub", Decimal.Sub, "12.20"},
+		{"Mul", Decimal.Mul, "3.750"},
+		{"Div", Decimal.Div, "41.666667"},
+	}
+	for _, tt := range tests {
+		got, err := tt.op(a, b)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s(%v, %v) = %v, %v, want %s", tt.name, a, b, got, err, tt.want)
+		}
+	}
+	if _, err := a.Div(NewDecimal(0, 2)); err == nil {
+		t.Error("division by zero did not fail")
+	}
+	if _, err := NewDecimal(math.MaxInt64, 0).Add(NewDecimal(1, 0)); err == nil {
+		t.Error("overflow did not fail")
+	}
+	if c := NewDecimal(10, 1).Cmp(NewDecimal(100, 2)); c != 0 {
+		t.Errorf("1.0 compared to 1.00 = %d, want 0", c)
+	}
+	if c := NewDecimal(-1, 0).Cmp(NewDecimal(5, 2)); c != -1 {
+		t.Errorf("-1 compared to 0.05 = %d, want -1", c)
+	}
+}
+
+func TestDecimalRescale(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int32
+		want  string
+	}{
+		{"2.345", 2, "2.35"},
+		{"-2.345", 2, "-2.35"},
+		{"2.344", 2, "2.34"},
+		{"0.5", 0, "1"},
+		{"-0.5", 0, "-1"},
+		{"1.5", 3, "1.500"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := d.Rescale(tt.scale)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s.Rescale(%d) = %v, %v, want %s", tt.in, tt.scale, got, err, tt.want)
+		}
+	}
+}
+
+func TestToDecimal(t *testing.T) {
+	for _, tt := range []struct {
+		in   any
+		want string
+	}{
+		{7, "7"},
+		{int64(-3), "-3"},
+		{uint8(200), "200"},
+		{0.1, "0.1"},
+		{float32(2.5), "2.5"},
+		{"19.99", "19.99"},
+		{NewDecimal(5, 1), "0.5"},
+	} {
+		got, err := ToDecimal(tt.in)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("ToDecimal(%#v) = %v, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ToDecimal(true); err == nil {
+		t.Error("ToDecimal(true) did not fail")
+	}
+}
+
+func TestParseFieldType(t *testing.T) {
+	f, err := ParseFieldType(" Decimal(10, 2) ")
+	if err != nil || f.FieldType != DecimalType || f.Precision != 10 || f.Scale != 2 {
+		t.Errorf("ParseFieldType(decimal(10, 2)) = %+v, %v", f, err)
+	}
+	if got := f.TypeSpec(); got != "decimal(10,2)" {
+		t.Errorf("TypeSpec() = %s, want decimal(10,2)", got)
+	}
+	for _, in := range []string{"decimal(10)", "decimal(2,3)", "decimal(19,2)", "decimal(a,2)", "decimal(10,-1)", "money"} {
+		if f, err := ParseFieldType(in); err == nil {
+			t.Errorf("ParseFieldType(%s) = %+v, want an error", in, f)
+		}
+	}
+	fields, err := ParseSchemaSpec("Fare_amount:decimal(10,2),Vendor_id:int")
+	if err != nil || len(fields) != 2 || fields[0].FieldName != "Fare_amount" || fields[0].Scale != 2 || fields[1].FieldType != "int" {
+		t.Errorf("ParseSchemaSpec = %+v, %v", fields, err)
+	}
+}
+
+func TestCastColumnDecimal(t *testing.T) {
+	d := newTestFrame(t, "", []string{"amount"}, []string{"12.5"}, []string{"-0.125"}, []string{""}, []string{"7"})
+	if err := d.CastColumn("amount", "decimal(6,2)"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := columnStrings(t, d, "amount"), []string{"12.50", "-0.13", "<nil>", "7.00"}; !slices.Equal(got, want) {
+		t.Errorf("cast to decimal(6,2) = %v, want %v", got, want)
+	}
+	if f := d.Schema.Fields[0]; f.FieldType != DecimalType || f.Precision != 6 || f.Scale != 2 {
+		t.Errorf("field = %+v, want decimal(6,2)", f)
+	}
+	if err := d.CastColumn("amount", "float"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := columnStrings(t, d, "amount"), []string{"12.5", "-0.13", "<nil>", "7"}; !slices.Equal(got, want) {
+		t.Errorf("cast back to float = %v, want %v", got, want)
+	}
+
+	d = newTestFrame(t, "", []string{"amount"}, []string{"1234.5"})
+	if err := d.CastColumn("amount", "decimal(5,2)"); err == nil {
+		t.Error("1234.50 fits decimal(5,2)")
+	}
+	d = newTestFrame(t, "", []string{"amount"}, []string{"12,50"})
+	if err := d.CastColumn("amount", "decimal(5,2)"); err == nil {
+		t.Error("12,50 was cast to decimal")
+	}
+}
+
+func TestDecimalExpressions(t *testing.T) {
+	records := make([][]string, 0)
+	for range 10 {
+		records = append(records, []string{"0.10"}, []string{"0.20"})
+	}
+	// The same sum over floats is 3.0000000000000013.
+	d := newTestFrame(t, "Mta_tax:decimal(4,2)", []string{"Mta_tax"}, records...)
+	if err := d.Transform("reduce(Mta_tax, #acc + #, 0)"); err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, d, "Mta_tax")[0]; got != "3.00" {
+		t.Errorf("sum = %s, want 3.00", got)
+	}
+
+	d = newTestFrame(t, "Fare_amount:decimal(10,2)", []string{"Fare_amount"}, []string{"12.50"}, []string{"3.25"})
+	if err := d.Transform("map(Fare_amount, # * 1.1 + decimal(1, 2))"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := columnStrings(t, d, "Fare_amount"), []string{"14.750", "4.575"}; !slices.Equal(got, want) {
+		t.Errorf("Fare_amount * 1.1 + 1 = %v, want %v", got, want)
+	}
+	if f := d.Schema.Fields[0]; f.FieldType != DecimalType || f.Scale != 3 {
+		t.Errorf("field = %+v, want a decimal of scale 3", f)
+	}
+	df, err := d.Where(`Fare_amount >= 14.75 && Fare_amount != "x"`)
+	if err == nil {
+		t.Errorf("comparing a decimal and a string gave %d rows", df.GetNumberOfRows())
+	}
+	df, err = d.Where("Fare_amount >= 14.75")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := df.GetNumberOfRows(); got != 1 {
+		t.Errorf("Fare_amount >= 14.75 kept %d rows, want 1", got)
+	}
+}
+
+func TestParquetDecimals(t *testing.T) {
+	f := Field{FieldName: "Total_amount", FieldType: DecimalType, Precision: 10, Scale: 2}
+	got, err := parquetDecimals([]any{int32(1250), int64(-5), "\x04\xd2", "\xfb\x2e", nil}, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{NewDecimal(1250, 2), NewDecimal(-5, 2), NewDecimal(1234, 2), NewDecimal(-1234, 2), nil}
+	if !slices.Equal(got, want) {
+		t.Errorf("parquetDecimals = %v, want %v", got, want)
+	}
+	if _, err := parquetDecimals([]any{1.5}, f); err == nil {
+		t.Error("a float storage was accepted")
+	}
+}
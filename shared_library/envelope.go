@@ -0,0 +1,66 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// FrameEnvelope is a DataFrame as it is written to frame streams from
+// version 2 of the wire format on. Besides the exported fields it carries
+// what gob would drop: the names of the functions, whether the rows are
+// indexed and the kinds of the column indexes. Functions travel by name and
+// indexes are built again by the reader.
+type FrameEnvelope struct {
+	Schema    Schema
+	Data      DataStructure
+	Metadata  Metadata
+	Functions []string
+	RowIndex  bool
+	Indexes   map[string][]IndexKind
+}
+
+// NewFrameEnvelope returns the envelope of a DataFrame.
+func NewFrameEnvelope(d *DataFrame) *FrameEnvelope {
+	e := &FrameEnvelope{
+		Schema:    d.Schema,
+		Data:      d.Data,
+		Metadata:  d.Metadata,
+		Functions: d.functionNames(),
+		RowIndex:  d.row != nil,
+	}
+	for _, name := range slices.Sorted(maps.Keys(d.indexes)) {
+		if kinds := d.GetIndexKinds(name); len(kinds) > 0 {
+			if e.Indexes == nil {
+				e.Indexes = make(map[string][]IndexKind)
+			}
+			e.Indexes[name] = kinds
+		}
+	}
+	return e
+}
+
+// DataFrame returns the DataFrame of the envelope with its functions bound
+// to the functions registered under their names and its indexes built.
+func (e *FrameEnvelope) DataFrame() (*DataFrame, error) {
+	d := &DataFrame{Schema: e.Schema, Data: e.Data, Metadata: e.Metadata}
+	if d.Data == nil {
+		d.Data = &InternalDataStructure{Data: make([]*Data, 0)}
+	}
+	if len(e.Functions) > 0 {
+		d.bindFunctions(e.Functions)
+	}
+	if e.RowIndex && d.GetNumberOfRows() > 0 {
+		if err := d.IndexRows(); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(e.Indexes)) {
+		for _, kind := range e.Indexes[name] {
+			if err := d.CreateIndex(name, kind); err != nil {
+				return nil, fmt.Errorf("index on %s: %w", name, err)
+			}
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,83 @@
+package sharedlibrary
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFrameEnvelope(t *testing.T) {
+	d := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "zone"}, []string{"1", "a"}, []string{"2", "b"}, []string{"1", "c"})
+	d.Metadata.Source = "trips.csv"
+	d, err := d.Where("Vendor_id == 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	double := func(v int) int { return 2 * v }
+	RegisterFunction("double", double)
+	d.AddFunction("double", double)
+	// a function the reader has not registered keeps its name
+	d.AddFunction("unregistered", strings.ToUpper)
+	if err := d.IndexRows(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateIndex("zone", HashIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateIndex("Vendor_id", SortedIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := WriteFrame(&b, d); err != nil {
+		t.Fatal(err)
+	}
+	df, err := ReadFrame(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if df.Metadata.Source != "trips.csv" || len(df.Metadata.History) != len(d.Metadata.History) || df.Metadata.History[len(df.Metadata.History)-1].Operation != "Where" {
+		t.Errorf("metadata %+v, want the source and history of %+v", df.Metadata, d.Metadata)
+	}
+	if got := df.functionNames(); !slices.Equal(got, d.functionNames()) {
+		t.Errorf("functions %v, want %v", got, d.functionNames())
+	}
+	if df.functions["double"] == nil || df.functions["unregistered"] != nil {
+		t.Errorf("double bound: %v, unregistered bound: %v", df.functions["double"] != nil, df.functions["unregistered"] != nil)
+	}
+	if df.row == nil || *df.GetRowByIndex(1)[1] != "c" {
+		t.Error("rows are not indexed after the pipe")
+	}
+	if got := df.GetIndexKinds("zone"); !slices.Equal(got, []IndexKind{HashIndex}) {
+		t.Errorf("indexes on zone %v, want [hash]", got)
+	}
+	if got := df.GetIndexKinds("Vendor_id"); !slices.Equal(got, []IndexKind{SortedIndex}) {
+		t.Errorf("indexes on Vendor_id %v, want [sorted]", got)
+	}
+
+	// the bound function can be called by the next operator
+	filtered, err := df.Where("functions.double(Vendor_id) == 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, filtered, "zone"); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("zones where double(Vendor_id) == 2 = %v, want [a c]", got)
+	}
+}
+
+func TestFrameEnvelopeEmpty(t *testing.T) {
+	// a frame without rows keeps its columns and is not indexed
+	d := newTestFrame(t, "", []string{"zone"})
+	df, err := NewFrameEnvelope(d).DataFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.GetNumberOfRows() != 0 || !slices.Equal(df.GetFieldNames(), []string{"zone"}) || df.row != nil {
+		t.Errorf("empty frame came back as %v with %d rows", df.GetFieldNames(), df.GetNumberOfRows())
+	}
+	if df, err := (&FrameEnvelope{}).DataFrame(); err != nil || df.Data == nil {
+		t.Errorf("envelope without data = %v, %v", df, err)
+	}
+}
@@ -0,0 +1,189 @@
+package sharedlibrary
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// FollowOptions are how FollowCSV batches the records it reads. A batch is
+// emitted when it has BatchRows records or Interval after its first record
+// arrived, whichever comes first.
+type FollowOptions struct {
+	BatchRows int
+	Interval  time.Duration
+	// Poll is how often a file at its end is checked for new lines.
+	Poll time.Duration
+	// CSV is how the records are parsed.
+	CSV CSVOptions
+}
+
+// followReader reads a file like tail -F: at the end of the file it waits
+// for more data, and when the file is truncated or replaced by a new file
+// at the same path it continues with that file after its header line.
+type followReader struct {
+	ctx    context.Context
+	path   string
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+	poll   time.Duration
+}
+
+func openFollowReader(ctx context.Context, path string, poll time.Duration) (*followReader, error) {
+	f := &followReader{ctx: ctx, path: path, poll: poll}
+	if err := f.open(false); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at the path, skipping its header when it is not the
+// first file that is read. A file whose header is not complete yet is not
+// opened.
+func (f *followReader) open(skipHeader bool) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	offset := int64(0)
+	if skipHeader {
+		header, err := bufio.NewReader(file).ReadBytes('\n')
+		if err == nil {
+			offset = int64(len(header))
+			_, err = file.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file, f.info, f.offset = file, info, offset
+	return nil
+}
+
+// reopened reports whether the path now names another file or the file was
+// truncated, and opens it again when it does.
+func (f *followReader) reopened() bool {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// rotated and not created again yet
+		return false
+	}
+	if os.SameFile(info, f.info) && info.Size() >= f.offset {
+		return false
+	}
+	return f.open(true) == nil
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		f.offset += int64(n)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+		if err := f.ctx.Err(); err != nil {
+			return 0, err
+		}
+		if !f.reopened() {
+			time.Sleep(f.poll)
+		}
+	}
+}
+
+// FollowCSV reads a CSV file as it grows and calls emit with a DataFrame for
+// every batch of new records, read as ReadCSV reads them. The file is followed until
+// emit returns an error or ctx is done, the records read by then are
+// emitted first. Path "-" reads stdin to its end.
+func FollowCSV(ctx context.Context, path string, opts FollowOptions, emit func(*DataFrame) error) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := openFollowReader(ctx, path, opts.Poll)
+		if err != nil {
+			return err
+		}
+		defer f.file.Close()
+		r = f
+	}
+
+	reader, err := newCSVRecords(r, path, nil, opts.CSV)
+	if err != nil {
+		return err
+	}
+	header := reader.header
+	records := make(chan []any)
+	failed := make(chan error, 1)
+	go func() {
+		defer close(records)
+		for {
+			record, err := reader.next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	batch := make([][]any, 0, opts.BatchRows)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		d, err := newCSVFrame(path, header, batch, opts.CSV)
+		if err != nil {
+			return err
+		}
+		batch = make([][]any, 0, opts.BatchRows)
+		return emit(d)
+	}
+	timer := time.NewTimer(opts.Interval)
+	defer timer.Stop()
+	for {
+		select {
+		case record, open := <-records:
+			if !open {
+				select {
+				case err := <-failed:
+					return errors.Join(flush(), err)
+				default:
+					return flush()
+				}
+			}
+			if len(batch) == 0 {
+				timer.Reset(opts.Interval)
+			}
+			batch = append(batch, record)
+			if len(batch) >= opts.BatchRows {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-timer.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return errors.Join(flush(), ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sharedlibrary
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFollowCSV(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.csv")
+	os.WriteFile(path, []byte("id,event\n1,start\n2,run\n3,run\n"), 0o644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan []string)
+	done := make(chan error, 1)
+	opts := FollowOptions{BatchRows: 2, Interval: 50 * time.Millisecond, Poll: 5 * time.Millisecond}
+	go func() {
+		done <- FollowCSV(ctx, path, opts, func(d *DataFrame) error {
+			if d.Metadata.Source != path {
+				t.Errorf("source = %s, want %s", d.Metadata.Source, path)
+			}
+			batches <- columnStrings(t, d, "id")
+			return nil
+		})
+	}()
+	next := func(want ...string) {
+		t.Helper()
+		select {
+		case got := <-batches:
+			if !slices.Equal(got, want) {
+				t.Errorf("batch = %v, want %v", got, want)
+			}
+		case err := <-done:
+			t.Fatalf("FollowCSV returned %v, want batch %v", err, want)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no batch %v", want)
+		}
+	}
+
+	// a full batch right away, the rest when the interval is over
+	next("1", "2")
+	next("3")
+	appendFile(t, path, "4,run\n5,run\n6,st")
+	next("4", "5")
+	appendFile(t, path, "op\n")
+	next("6")
+
+	// the file is replaced by a new one, its header is skipped
+	tmp := filepath.Join(dir, "new.csv")
+	os.WriteFile(tmp, []byte("id,event\n7,start\n"), 0o644)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	next("7")
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestFollowCSVEmitError(t *testing.T) {
+	path := writeTestFile(t, "log.csv", "id\n1\n2\n3\n")
+	stop := errors.New("stop")
+	n := 0
+	err := FollowCSV(context.Background(), path, FollowOptions{BatchRows: 1, Interval: time.Second, Poll: time.Millisecond}, func(d *DataFrame) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("err = %v after %d batches, want stop after 1", err, n)
+	}
+	if err := FollowCSV(context.Background(), filepath.Join(t.TempDir(), "missing.csv"), FollowOptions{BatchRows: 1, Interval: time.Second}, nil); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("err = %v, want no such file", err)
+	}
+}
@@ -1,15 +1,20 @@
 package sharedlibrary
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
 	"text/tabwriter"
 
 	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
 	"github.com/xitongsys/parquet-go/reader"
 	"github.com/xitongsys/parquet-go/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // transpose transposes a slice of Data, converting rows to columns and vice versa.
@@ -139,41 +144,131 @@ func CreateDataFrameFromCSV(r *csv.Reader) DataFrame {
 	return *d
 }
 
+// NewDataFrameFromRecords returns a DataFrame of string columns named by
+// header with the given CSV records as its rows, which may be none.
+func NewDataFrameFromRecords(header []string, records [][]string) *DataFrame {
+	fields := make([]Field, len(header))
+	data := make([]*Data, len(header))
+	for i, name := range header {
+		fields[i] = Field{FieldName: name, FieldPosition: i, FieldType: "string"}
+		column := make(Data, len(records))
+		for j, record := range records {
+			column[j] = record[i]
+		}
+		data[i] = &column
+	}
+	return NewDataFrameWithArgs(fields, data)
+}
+
 func CreateDataFrameFromParquet(r *reader.ParquetReader) DataFrame {
+	d, err := ReadParquetContext(context.Background(), r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, f := range d.Schema.Fields {
+		fmt.Println(f.FieldName, f.FieldType)
+	}
+	return *d
+}
 
-	rootPath := r.SchemaHandler.SchemaElements[0].Name
+// ReadParquetContext reads all columns of a Parquet file and stops with
+// ctx.Err() when ctx is done, checked between columns.
+func ReadParquetContext(ctx context.Context, r *reader.ParquetReader) (d *DataFrame, err error) {
+	_, span := tracer.Start(ctx, "ReadParquet", trace.WithAttributes(attribute.Int64("rows", r.GetNumRows())))
+	defer func() { endSpan(span, d, err) }()
+	Fields := parquetFields(r)
+
+	data := make([]*Data, 0)
+
+	for i := range Fields {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		value, err := readParquetColumn(r, &Fields[i])
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, &value)
+
+	}
+	return NewDataFrameWithArgs(Fields, data), nil
+}
 
+// parquetFields returns the fields of a Parquet file as they are stored,
+// DECIMAL columns get their precision and scale.
+func parquetFields(r *reader.ParquetReader) []Field {
 	var Fields []Field
 
 	for i, v := range r.SchemaHandler.SchemaElements[1:] {
-		Fields = append(Fields, Field{
+		field := Field{
 			FieldName:     v.Name,
 			FieldPosition: i,
 			FieldType:     v.GetType().String(),
-		})
+		}
+		if v.GetConvertedType() == parquet.ConvertedType_DECIMAL || v.IsSetLogicalType() && v.GetLogicalType().IsSetDECIMAL() {
+			field.FieldType = DecimalType
+			field.Precision = v.GetPrecision()
+			field.Scale = v.GetScale()
+		}
+		Fields = append(Fields, field)
 	}
+	return Fields
+}
 
-	data := make([]*Data, 0)
-
+// readParquetColumn reads all values of a column. INT96 timestamps are
+// converted to time.Time and DECIMAL values to Decimal, the FieldType of
+// the field is updated accordingly.
+func readParquetColumn(r *reader.ParquetReader, field *Field) (Data, error) {
+	rootPath := r.SchemaHandler.SchemaElements[0].Name
 	len_rows := r.GetNumRows()
-	for _, v := range Fields {
-		value, _, _, err := r.ReadColumnByPath(common.ReformPathStr(rootPath+"."+v.FieldName), len_rows)
+	value, _, _, err := r.ReadColumnByPath(common.ReformPathStr(rootPath+"."+field.FieldName), len_rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if field.FieldType == "INT96" {
+		new_time_array := make([]any, len_rows)
+		for i, v2 := range value {
+			new_time_array[i] = types.INT96ToTime(v2.(string))
+		}
+		field.FieldType = "time.Time"
+		value = new_time_array
+	}
+	if field.FieldType == DecimalType {
+		value, err = parquetDecimals(value, *field)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
+	}
+	return value, nil
+}
 
-		if v.FieldType == "INT96" {
-			new_time_array := make([]any, len_rows)
-			for i, v2 := range value {
-				new_time_array[i] = types.INT96ToTime(v2.(string))
+// parquetDecimals converts the raw values of a Parquet DECIMAL column, stored
+// as INT32, INT64 or big-endian byte arrays, to Decimal values.
+func parquetDecimals(values []any, f Field) ([]any, error) {
+	result := make([]any, len(values))
+	for i, v := range values {
+		switch raw := v.(type) {
+		case nil:
+			result[i] = nil
+		case int32:
+			result[i] = NewDecimal(int64(raw), f.Scale)
+		case int64:
+			result[i] = NewDecimal(raw, f.Scale)
+		case string:
+			// Two's complement big-endian unscaled value.
+			unscaled := new(big.Int).SetBytes([]byte(raw))
+			if len(raw) > 0 && raw[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(raw))))
+			}
+			dec, err := fromBig(unscaled, f.Scale)
+			if err != nil {
+				return nil, fmt.Errorf("column %s, row %d: %w", f.FieldName, i, err)
 			}
-			v.FieldType = "time.Time"
-			value = new_time_array
+			result[i] = dec
+		default:
+			return nil, fmt.Errorf("column %s, row %d: unexpected decimal storage %T", f.FieldName, i, v)
 		}
-		fmt.Println(v.FieldName, v.FieldType)
-		data = append(data, &value)
-
 	}
-	d := NewDataFrameWithArgs(Fields, data)
-	return *d
+	return result, nil
 }
@@ -4,6 +4,23 @@ go 1.23.2
 
 replace github.com/magpierre/operators/shared_library => ../shared_library
 
-require github.com/Knetic/govaluate v3.0.0+incompatible
+require (
+	github.com/Knetic/govaluate v3.0.0+incompatible
+	github.com/golang/snappy v0.0.3
+	github.com/klauspost/compress v1.13.1
+	github.com/pierrec/lz4/v4 v4.1.8
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.35.0
+	go.opentelemetry.io/otel/sdk v1.35.0
+	go.opentelemetry.io/otel/trace v1.35.0
+)
 
-require github.com/expr-lang/expr v1.17.2 // indirect
+require (
+	github.com/expr-lang/expr v1.17.2 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+)
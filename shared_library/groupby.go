@@ -0,0 +1,449 @@
+package sharedlibrary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/expr-lang/expr/vm/runtime"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Aggregation describes one aggregate column produced by GroupBy.
+// Function is one of count, sum, mean, min, max, first or last.
+// When Alias is empty the output column is named function_column.
+type Aggregation struct {
+	Column   string
+	Function string
+	Alias    string
+}
+
+// OutputName returns the name of the column produced by the aggregation.
+func (a Aggregation) OutputName() string {
+	if a.Alias != "" {
+		return a.Alias
+	}
+	return a.Function + "_" + a.Column
+}
+
+// outputField returns the field of the column computed by the aggregation
+// from the input field, which is nil for count(*). Counts are int, means are
+// float, but stay decimal for a decimal input, and min, max, sum, first and
+// last keep the type of the input.
+func (a Aggregation) outputField(input *Field) Field {
+	f := Field{FieldName: a.OutputName(), FieldType: "int"}
+	switch strings.ToLower(a.Function) {
+	case "count":
+		return f
+	case "mean", "avg":
+		f.FieldType = "float"
+		if input.FieldType == DecimalType {
+			f.FieldType, f.Scale = DecimalType, input.Scale+decimalDivisionScale
+		}
+		return f
+	case "sum":
+		f.FieldType, f.Scale = input.FieldType, input.Scale
+		return f
+	}
+	f.FieldType, f.Precision, f.Scale = input.FieldType, input.Precision, input.Scale
+	return f
+}
+
+// ParseAggregations parses a comma separated list of aggregations written
+// as function(column), optionally followed by "as alias", for example
+// "count(*), sum(Fare_amount) as fares".
+func ParseAggregations(spec string) ([]Aggregation, error) {
+	aggs := make([]Aggregation, 0)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		call, alias := item, ""
+		if i := strings.LastIndex(strings.ToLower(item), " as "); i >= 0 {
+			call, alias = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+4:])
+		}
+		open := strings.IndexByte(call, '(')
+		if open <= 0 || !strings.HasSuffix(call, ")") {
+			return nil, fmt.Errorf("aggregation %q is not function(column)", item)
+		}
+		agg := Aggregation{
+			Function: strings.ToLower(strings.TrimSpace(call[:open])),
+			Column:   strings.TrimSpace(call[open+1 : len(call)-1]),
+			Alias:    alias,
+		}
+		if agg.Column == "" {
+			return nil, fmt.Errorf("aggregation %q has no column", item)
+		}
+		if _, err := newAggregator(agg.Function); err != nil {
+			return nil, err
+		}
+		aggs = append(aggs, agg)
+	}
+	if len(aggs) == 0 {
+		return nil, errors.New("no aggregations given")
+	}
+	return aggs, nil
+}
+
+// aggregator accumulates the values of one group for one Aggregation.
+type aggregator interface {
+	add(value any) error
+	result() any
+}
+
+func newAggregator(function string) (aggregator, error) {
+	switch strings.ToLower(function) {
+	case "count":
+		return &countAggregator{}, nil
+	case "sum":
+		return &sumAggregator{}, nil
+	case "mean", "avg":
+		return &meanAggregator{}, nil
+	case "min":
+		return &extremeAggregator{keep: func(c int) bool { return c < 0 }}, nil
+	case "max":
+		return &extremeAggregator{keep: func(c int) bool { return c > 0 }}, nil
+	case "first":
+		return &firstAggregator{}, nil
+	case "last":
+		return &lastAggregator{}, nil
+	}
+	return nil, fmt.Errorf("unknown aggregate function %q", function)
+}
+
+type countAggregator struct {
+	n int
+}
+
+func (a *countAggregator) add(value any) error {
+	if value != nil {
+		a.n++
+	}
+	return nil
+}
+
+func (a *countAggregator) result() any {
+	return a.n
+}
+
+// sumAggregator sums integers as int, floats as float64 and everything else,
+// Decimals and numeric strings, exactly as Decimal.
+type sumAggregator struct {
+	kind    string
+	integer int
+	float   float64
+	decimal Decimal
+}
+
+func (a *sumAggregator) add(value any) error {
+	if value == nil {
+		return nil
+	}
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		if a.kind == "" {
+			a.kind = "int"
+		}
+		switch a.kind {
+		case "int":
+			a.integer += runtime.ToInt(v)
+			return nil
+		case "float":
+			a.float += runtime.ToFloat64(v)
+			return nil
+		}
+	case float32, float64:
+		switch a.kind {
+		case "", "int":
+			a.kind = "float"
+			a.float = float64(a.integer) + runtime.ToFloat64(v)
+			return nil
+		case "float":
+			a.float += runtime.ToFloat64(v)
+			return nil
+		}
+	}
+	dec, err := ToDecimal(value)
+	if err != nil {
+		return err
+	}
+	switch a.kind {
+	case "int":
+		a.decimal = NewDecimal(int64(a.integer), 0)
+	case "float":
+		if a.decimal, err = DecimalFromFloat(a.float); err != nil {
+			return err
+		}
+	}
+	a.kind = DecimalType
+	a.decimal, err = a.decimal.Add(dec)
+	return err
+}
+
+func (a *sumAggregator) result() any {
+	switch a.kind {
+	case "int":
+		return a.integer
+	case "float":
+		return a.float
+	case DecimalType:
+		return a.decimal
+	}
+	return nil
+}
+
+type meanAggregator struct {
+	sum sumAggregator
+	n   int
+}
+
+func (a *meanAggregator) add(value any) error {
+	if value == nil {
+		return nil
+	}
+	a.n++
+	return a.sum.add(value)
+}
+
+func (a *meanAggregator) result() any {
+	if a.n == 0 {
+		return nil
+	}
+	switch s := a.sum.result().(type) {
+	case int:
+		return float64(s) / float64(a.n)
+	case float64:
+		return s / float64(a.n)
+	case Decimal:
+		mean, err := s.Div(NewDecimal(int64(a.n), 0))
+		if err != nil {
+			return nil
+		}
+		return mean
+	}
+	return nil
+}
+
+// extremeAggregator keeps the value for which keep(compare(value, current))
+// is true, it backs both min and max.
+type extremeAggregator struct {
+	keep  func(c int) bool
+	value any
+}
+
+func (a *extremeAggregator) add(value any) error {
+	if value == nil {
+		return nil
+	}
+	if a.value == nil {
+		a.value = value
+		return nil
+	}
+	c, err := compareValues(value, a.value)
+	if err != nil {
+		return err
+	}
+	if a.keep(c) {
+		a.value = value
+	}
+	return nil
+}
+
+func (a *extremeAggregator) result() any {
+	return a.value
+}
+
+type firstAggregator struct {
+	value any
+	set   bool
+}
+
+func (a *firstAggregator) add(value any) error {
+	if !a.set {
+		a.value, a.set = value, true
+	}
+	return nil
+}
+
+func (a *firstAggregator) result() any {
+	return a.value
+}
+
+type lastAggregator struct {
+	value any
+}
+
+func (a *lastAggregator) add(value any) error {
+	a.value = value
+	return nil
+}
+
+func (a *lastAggregator) result() any {
+	return a.value
+}
+
+// GroupBy groups the rows of the DataFrame by the values of the key columns
+// and computes the aggregations for every group. The result contains the key
+// columns followed by one column per aggregation, with one row per group in
+// order of first appearance. Without keys the result has a single row.
+func (d *DataFrame) GroupBy(keys []string, aggs ...Aggregation) (*DataFrame, error) {
+	return d.GroupByContext(context.Background(), keys, aggs...)
+}
+
+// GroupByContext is GroupBy that stops with ctx.Err() when ctx is done.
+func (d *DataFrame) GroupByContext(ctx context.Context, keys []string, aggs ...Aggregation) (*DataFrame, error) {
+	ctx, span := startSpan(ctx, "GroupBy", d, attribute.StringSlice("keys", keys))
+	df, err := d.groupBy(ctx, keys, aggs...)
+	endSpan(span, df, err)
+	return df, err
+}
+
+// groupByFields checks the keys and aggregations of a group by of rows with
+// the schema s and returns the fields of its result.
+func groupByFields(s *Schema, keys []string, aggs []Aggregation) ([]Field, error) {
+	if len(keys) == 0 && len(aggs) == 0 {
+		return nil, errors.New("no keys or aggregations provided for group by")
+	}
+	fields := make([]Field, 0, len(keys)+len(aggs))
+	for _, key := range keys {
+		x := s.GetField(key)
+		if x < 0 {
+			return nil, fmt.Errorf("key '%s' not found in DataFrame", key)
+		}
+		f := s.Fields[x]
+		f.FieldPosition = len(fields)
+		fields = append(fields, f)
+	}
+	for _, agg := range aggs {
+		var input *Field
+		if x := s.GetField(agg.Column); x >= 0 {
+			input = &s.Fields[x]
+		} else if agg.Column != "*" || !strings.EqualFold(agg.Function, "count") {
+			return nil, fmt.Errorf("column '%s' not found in DataFrame", agg.Column)
+		}
+		if _, err := newAggregator(agg.Function); err != nil {
+			return nil, err
+		}
+		f := agg.outputField(input)
+		f.FieldPosition = len(fields)
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func (d *DataFrame) groupBy(ctx context.Context, keys []string, aggs ...Aggregation) (*DataFrame, error) {
+	fields, err := groupByFields(&d.Schema, keys, aggs)
+	if err != nil {
+		return nil, err
+	}
+	keyIndices := make([]int, len(keys))
+	for i, key := range keys {
+		keyIndices[i] = d.GetFieldNumber(key)
+	}
+	aggIndices := make([]int, len(aggs))
+	for i, agg := range aggs {
+		aggIndices[i] = d.GetFieldNumber(agg.Column)
+	}
+
+	type group struct {
+		row         int
+		aggregators []aggregator
+	}
+	newGroup := func(row int) *group {
+		g := &group{row: row, aggregators: make([]aggregator, len(aggs))}
+		for j, agg := range aggs {
+			g.aggregators[j], _ = newAggregator(agg.Function)
+		}
+		return g
+	}
+	add := func(g *group, row Row) error {
+		for j, x := range aggIndices {
+			value := any(1)
+			if x >= 0 {
+				value = *row[x]
+			}
+			if err := g.aggregators[j].add(value); err != nil {
+				return fmt.Errorf("%s(%s): %w", aggs[j].Function, aggs[j].Column, err)
+			}
+		}
+		return nil
+	}
+	groups := make(map[string]*group)
+	order := make([]*group, 0)
+	num_rows := d.GetNumberOfRows()
+	used := int64(0)
+	for i := 0; i < num_rows; i++ {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		row := d.getRow(i)
+		compoundKey := rowKey(row, keyIndices)
+		g, found := groups[compoundKey]
+		if !found {
+			// over the memory budget the rows are grouped by partitions
+			used += int64(len(compoundKey)) + groupEntryBytes + aggregatorBytes*int64(len(aggs))
+			if overBudget(used) {
+				groups = nil
+				s, err := newSpill("group by")
+				if err != nil {
+					return nil, err
+				}
+				defer s.close()
+				keyOf := func(row int) string { return rowKey(d.getRow(row), keyIndices) }
+				visit := func(g *group, row int) (*group, error) {
+					if g == nil {
+						g = newGroup(row)
+					}
+					return g, add(g, d.getRow(row))
+				}
+				if _, order, err = firstRows(ctx, s, partitionsFor(estimateTotal(used, i+1, num_rows)), num_rows, keyOf, visit); err != nil {
+					return nil, err
+				}
+				break
+			}
+			g = newGroup(i)
+			groups[compoundKey] = g
+			order = append(order, g)
+		}
+		if err := add(g, row); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without keys the whole DataFrame is one group, even when it is empty
+	if len(keys) == 0 && len(order) == 0 {
+		order = append(order, newGroup(0))
+	}
+
+	newData := make([]*Data, 0, len(fields))
+	for _, x := range keyIndices {
+		column := make(Data, len(order))
+		for i, g := range order {
+			column[i], _ = d.GetPositionValue(x, g.row)
+		}
+		newData = append(newData, &column)
+	}
+	for j, agg := range aggs {
+		column := make(Data, len(order))
+		f := fields[len(keys)+j]
+		for i, g := range order {
+			if column[i], err = promoteValue(g.aggregators[j].result(), f); err != nil {
+				return nil, fmt.Errorf("%s(%s): %w", agg.Function, agg.Column, err)
+			}
+		}
+		newData = append(newData, &column)
+	}
+
+	return &DataFrame{
+		Schema: Schema{
+			Fields: fields,
+		},
+		Data: &InternalDataStructure{
+			Data:    newData,
+			Rows:    len(order),
+			Columns: len(newData),
+		},
+	}, nil
+}
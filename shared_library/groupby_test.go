@@ -0,0 +1,120 @@
+package sharedlibrary
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseAggregations(t *testing.T) {
+	aggs, err := ParseAggregations("count(*), SUM(Fare_amount) as fares,mean( Mta_tax )")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Aggregation{
+		{Column: "*", Function: "count"},
+		{Column: "Fare_amount", Function: "sum", Alias: "fares"},
+		{Column: "Mta_tax", Function: "mean"},
+	}
+	if !slices.Equal(aggs, want) {
+		t.Errorf("ParseAggregations = %+v, want %+v", aggs, want)
+	}
+	if got := aggs[0].OutputName() + " " + aggs[1].OutputName(); got != "count_* fares" {
+		t.Errorf("output names = %s, want count_* fares", got)
+	}
+	for _, spec := range []string{"", "sum", "sum()", "median(x)", "sum(x) as y, (x)"} {
+		if aggs, err := ParseAggregations(spec); err == nil {
+			t.Errorf("ParseAggregations(%q) = %+v, want an error", spec, aggs)
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	d := newTestFrame(t, "Vendor_id:int,Fare_amount:decimal(6,2),distance:float,passengers:int",
+		[]string{"Vendor_id", "Fare_amount", "distance", "passengers", "zone"},
+		[]string{"2", "0.10", "1.5", "1", "a"},
+		[]string{"1", "0.20", "", "2", "b"},
+		[]string{"2", "0.20", "2.5", "3", "c"},
+		[]string{"1", "", "4", "", "d"},
+		[]string{"2", "0.10", "1", "2", "e"},
+	)
+	aggs, err := ParseAggregations("count(*), count(Fare_amount), sum(Fare_amount), sum(passengers), sum(distance), mean(Fare_amount), mean(passengers), min(zone), max(distance), first(Fare_amount), last(zone)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, err := d.GroupBy([]string{"Vendor_id"}, aggs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Vendor_id,count_*,count_Fare_amount,sum_Fare_amount,sum_passengers,sum_distance,mean_Fare_amount,mean_passengers,min_zone,max_distance,first_Fare_amount,last_zone",
+		"2,3,3,0.40,6,5,0.133333,2,a,2.5,0.10,e",
+		"1,2,1,0.20,2,4,0.200000,2,b,4,0.20,d",
+	}
+	if got := rows(t, df); !slices.Equal(got, want) {
+		t.Errorf("GroupBy =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	types := make([]string, len(df.Schema.Fields))
+	for i, f := range df.Schema.Fields {
+		types[i] = f.TypeSpec()
+	}
+	wantTypes := []string{"int", "int", "int", "decimal(0,2)", "int", "float", "decimal(0,6)", "float", "string", "float", "decimal(6,2)", "string"}
+	if !slices.Equal(types, wantTypes) {
+		t.Errorf("GroupBy types = %v, want %v", types, wantTypes)
+	}
+	if v := df.GetColumn("mean_passengers")[0]; v != 2.0 {
+		t.Errorf("mean_passengers = %#v, want float64 2", v)
+	}
+}
+
+func TestGroupByDecimalSum(t *testing.T) {
+	records := make([][]string, 0)
+	for range 1000 {
+		records = append(records, []string{"0.10"})
+	}
+	d := newTestFrame(t, "Mta_tax:decimal(4,2)", []string{"Mta_tax"}, records...)
+	df, err := d.GroupBy(nil, Aggregation{Column: "Mta_tax", Function: "sum"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, df, "sum_Mta_tax"); !slices.Equal(got, []string{"100.00"}) {
+		t.Errorf("sum = %v, want [100.00]", got)
+	}
+}
+
+func TestGroupByKeys(t *testing.T) {
+	d := newTestFrame(t, "Fare_amount:decimal(6,2)", []string{"zone", "Fare_amount", "n"},
+		[]string{"a", "1.50", "1"}, []string{"b", "2", "2"}, []string{"a", "1.5", "3"}, []string{"a", "2", "4"}, []string{"", "", "5"})
+	df, err := d.GroupBy([]string{"zone", "Fare_amount"}, Aggregation{Column: "n", Function: "count", Alias: "rows"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, df), []string{"zone,Fare_amount,rows", "a,1.50,2", "b,2.00,1", "a,2.00,1", ",<nil>,1"}; !slices.Equal(got, want) {
+		t.Errorf("GroupBy = %v, want %v", got, want)
+	}
+
+	empty := newTestFrame(t, "", []string{"n"})
+	df, err = empty.GroupBy(nil, Aggregation{Column: "*", Function: "count"}, Aggregation{Column: "n", Function: "sum"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, df), []string{"count_*,sum_n", "0,<nil>"}; !slices.Equal(got, want) {
+		t.Errorf("GroupBy of an empty frame = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		keys []string
+		aggs []Aggregation
+		want string
+	}{
+		{nil, nil, "no keys or aggregations"},
+		{[]string{"city"}, nil, "key 'city' not found"},
+		{nil, []Aggregation{{Column: "cost", Function: "sum"}}, "column 'cost' not found"},
+		{nil, []Aggregation{{Column: "*", Function: "sum"}}, "column '*' not found"},
+		{nil, []Aggregation{{Column: "zone", Function: "sum"}}, "sum(zone)"},
+	} {
+		if _, err := d.GroupBy(tt.keys, tt.aggs...); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("GroupBy(%v, %v): err = %v, want %q", tt.keys, tt.aggs, err, tt.want)
+		}
+	}
+}
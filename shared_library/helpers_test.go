@@ -0,0 +1,76 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestFrame returns a DataFrame of string columns named by header with
+// the records as its rows, cast with the schema spec when it is not empty.
+func newTestFrame(t *testing.T, spec string, header []string, records ...[]string) *DataFrame {
+	t.Helper()
+	d := NewDataFrameFromRecords(header, records)
+	if spec == "" {
+		return d
+	}
+	types, err := ParseSchemaSpec(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ApplySchema(types); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// columnStrings returns the values of a column formatted with fmt.Sprint,
+// <nil> for a missing value.
+func columnStrings(t *testing.T, d *DataFrame, name string) []string {
+	t.Helper()
+	x := d.GetFieldNumber(name)
+	if x < 0 {
+		t.Fatalf("no column %s in %v", name, d.GetFieldNames())
+	}
+	values := make([]string, d.GetNumberOfRows())
+	for i := range values {
+		v, err := d.GetPositionValue(x, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[i] = fmt.Sprint(v)
+	}
+	return values
+}
+
+// rows returns the rows of a DataFrame as comma separated values, after a
+// line with its column names.
+func rows(t *testing.T, d *DataFrame) []string {
+	t.Helper()
+	columns := make([][]string, 0)
+	for _, name := range d.GetFieldNames() {
+		columns = append(columns, columnStrings(t, d, name))
+	}
+	lines := []string{strings.Join(d.GetFieldNames(), ",")}
+	for i := 0; i < d.GetNumberOfRows(); i++ {
+		values := make([]string, len(columns))
+		for j := range columns {
+			values[j] = columns[j][i]
+		}
+		lines = append(lines, strings.Join(values, ","))
+	}
+	return lines
+}
+
+// writeTestFile writes a file in the temporary directory of the test and
+// returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
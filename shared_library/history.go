@@ -0,0 +1,220 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryEntry is an operation in the history of a DataFrame, see
+// Metadata.History.
+type HistoryEntry struct {
+	Operation string
+	Args      []string
+	// InputRows are the rows of the inputs, the DataFrame the operation was
+	// called on first.
+	InputRows []int
+	Time      time.Time
+	Duration  time.Duration
+	// Columns are the columns of the result in order, with the columns of
+	// the inputs they were computed from.
+	Columns []ColumnLineage
+	// Right is where the second input of Join and UnionAll comes from.
+	Right *Provenance
+}
+
+// ColumnLineage is a column of the result of an operation and the columns
+// of its inputs it was computed from.
+type ColumnLineage struct {
+	Column string
+	From   []ColumnRef
+	// Computed is set when the values were computed from the columns in
+	// From rather than taken from them.
+	Computed bool
+}
+
+// ColumnRef is a column of an input of an operation, input 0 is the
+// DataFrame the operation was called on and 1 the other one.
+type ColumnRef struct {
+	Input  int
+	Column string
+}
+
+// Provenance is the source and history of a DataFrame.
+type Provenance struct {
+	Source  string
+	History []HistoryEntry
+}
+
+// recordHistory appends an operation on d to the history of its result,
+// which starts with the history of d.
+func (d *DataFrame) recordHistory(result *DataFrame, entry HistoryEntry, other *DataFrame) {
+	entry.Duration = time.Since(entry.Time)
+	if other != nil {
+		entry.Right = &Provenance{Source: other.Metadata.Source, History: other.Metadata.History}
+	}
+	result.Metadata.Source = d.Metadata.Source
+	result.Metadata.History = append(slices.Clip(d.Metadata.History), entry)
+}
+
+// startHistory returns the entry of an operation on inputs starting now.
+func startHistory(operation string, args []string, inputs ...*DataFrame) HistoryEntry {
+	entry := HistoryEntry{Operation: operation, Args: args, Time: time.Now().UTC()}
+	for _, in := range inputs {
+		entry.InputRows = append(entry.InputRows, in.GetNumberOfRows())
+	}
+	return entry
+}
+
+// sameColumns is the lineage of columns taken unchanged from an input.
+func sameColumns(fields []Field, input int) []ColumnLineage {
+	columns := make([]ColumnLineage, len(fields))
+	for i, f := range fields {
+		columns[i] = ColumnLineage{Column: f.FieldName, From: []ColumnRef{{Input: input, Column: f.FieldName}}}
+	}
+	return columns
+}
+
+// untimed returns the history without the times of its operations.
+func untimed(history []HistoryEntry) []HistoryEntry {
+	if history == nil {
+		return nil
+	}
+	entries := slices.Clone(history)
+	for i := range entries {
+		entries[i].Time, entries[i].Duration = time.Time{}, 0
+		if r := entries[i].Right; r != nil {
+			entries[i].Right = &Provenance{Source: r.Source, History: untimed(r.History)}
+		}
+	}
+	return entries
+}
+
+// String returns the operation with its arguments, like Where(amount > 5).
+func (e HistoryEntry) String() string {
+	return fmt.Sprintf("%s(%s)", e.Operation, strings.Join(e.Args, ", "))
+}
+
+// derived returns the columns of the result that are not a column of the
+// same name of the first input taken as it was.
+func (e HistoryEntry) derived() []ColumnLineage {
+	columns := make([]ColumnLineage, 0)
+	for _, c := range e.Columns {
+		if c.Computed || len(c.From) != 1 || c.From[0] != (ColumnRef{Column: c.Column}) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+func (r ColumnRef) String() string {
+	if r.Input == 1 {
+		return "right." + r.Column
+	}
+	return r.Column
+}
+
+// String returns the column and where it comes from, like
+// total <- f(price, quantity) for a computed column.
+func (c ColumnLineage) String() string {
+	names := make([]string, len(c.From))
+	for i, r := range c.From {
+		names[i] = r.String()
+	}
+	from := strings.Join(names, ", ")
+	if c.Computed {
+		from = "f(" + from + ")"
+	}
+	return c.Column + " <- " + from
+}
+
+// WriteHistory writes the source and history of a DataFrame as text, with
+// the columns every operation computed from other columns.
+func WriteHistory(w io.Writer, m Metadata) error {
+	var b strings.Builder
+	writeProvenance(&b, Provenance{Source: m.Source, History: m.History}, "")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeProvenance(b *strings.Builder, p Provenance, indent string) {
+	source := p.Source
+	if source == "" {
+		source = "unknown source"
+	}
+	fmt.Fprintf(b, "%s%s\n", indent, source)
+	for i, e := range p.History {
+		rows := make([]string, len(e.InputRows))
+		for j, n := range e.InputRows {
+			rows[j] = strconv.Itoa(n)
+		}
+		fmt.Fprintf(b, "%s%d. %s\n", indent, i+1, e)
+		fmt.Fprintf(b, "%s   %s rows in, at %s, took %s\n", indent, strings.Join(rows, " + "), e.Time.Format(time.RFC3339), e.Duration.Round(time.Microsecond))
+		for _, c := range e.derived() {
+			fmt.Fprintf(b, "%s   %s\n", indent, c)
+		}
+		if e.Right != nil {
+			fmt.Fprintf(b, "%s   right input:\n", indent)
+			writeProvenance(b, *e.Right, indent+"     ")
+		}
+	}
+}
+
+// WriteHistoryDOT writes the source and history of a DataFrame as a
+// Graphviz graph, with a node for every source and operation.
+func WriteHistoryDOT(w io.Writer, m Metadata) error {
+	var b strings.Builder
+	b.WriteString("digraph lineage {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	nodes := 0
+	writeProvenanceDOT(&b, Provenance{Source: m.Source, History: m.History}, &nodes)
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeProvenanceDOT writes the nodes of a source and its history and
+// returns the node of the last operation.
+func writeProvenanceDOT(b *strings.Builder, p Provenance, nodes *int) string {
+	node := func() string {
+		*nodes++
+		return fmt.Sprintf("n%d", *nodes)
+	}
+	source := p.Source
+	if source == "" {
+		source = "unknown source"
+	}
+	last := node()
+	fmt.Fprintf(b, "\t%s [label=%s, shape=note];\n", last, strconv.Quote(source))
+	for _, e := range p.History {
+		label := []string{e.String()}
+		for _, c := range e.derived() {
+			label = append(label, c.String())
+		}
+		right := ""
+		if e.Right != nil {
+			right = writeProvenanceDOT(b, *e.Right, nodes)
+		}
+		n := node()
+		fmt.Fprintf(b, "\t%s [label=%s];\n", n, strconv.Quote(strings.Join(label, "\n")))
+		fmt.Fprintf(b, "\t%s -> %s [label=%s];\n", last, n, strconv.Quote(fmt.Sprintf("%d rows", firstOr(e.InputRows))))
+		if right != "" {
+			rows := 0
+			if len(e.InputRows) > 1 {
+				rows = e.InputRows[1]
+			}
+			fmt.Fprintf(b, "\t%s -> %s [label=%s, style=dashed];\n", right, n, strconv.Quote(fmt.Sprintf("right, %d rows", rows)))
+		}
+		last = n
+	}
+	return last
+}
+
+func firstOr(rows []int) int {
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0]
+}
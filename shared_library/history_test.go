@@ -0,0 +1,146 @@
+package sharedlibrary
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lineageFrame returns trips joined with vendors after a where, a
+// transform and a rename, with the times taken out of its history.
+func lineageFrame(t *testing.T) (*DataFrame, *DataFrame) {
+	t.Helper()
+	trips := newTestFrame(t, "", []string{"Vendor_id", "Fare", "Tip"}, []string{"1", "12", "2"}, []string{"2", "3", "0"}, []string{"1", "7", "1"})
+	trips.Metadata.Source = "trips.csv"
+	vendors := newTestFrame(t, "", []string{"Vendor_id", "name"}, []string{"1", "anna"}, []string{"2", "bob"})
+	vendors.Metadata.Source = "vendors.csv"
+
+	where, err := trips.Where("Fare != '3'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := where.Transform("map(Tip, Fare[#index] + Tip[#index])"); err != nil {
+		t.Fatal(err)
+	}
+	if err := where.RenameColumn("Tip", "Total"); err != nil {
+		t.Fatal(err)
+	}
+	joined, err := where.Join(vendors, []string{"Vendor_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, err := joined.Project("name", "Total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	df.Metadata.History = untimed(df.Metadata.History)
+	return trips, df
+}
+
+func TestHistory(t *testing.T) {
+	trips, df := lineageFrame(t)
+	if len(trips.Metadata.History) != 0 {
+		t.Errorf("the input got the history %v", trips.Metadata.History)
+	}
+
+	h := df.Metadata.History
+	got := make([]string, len(h))
+	for i, e := range h {
+		got[i] = e.String()
+	}
+	want := []string{"Where(Fare != '3')", "Transform(map(Tip, Fare[#index] + Tip[#index]))", "RenameColumn(Tip, Total)", "Join(Vendor_id)", "Project(name, Total)"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("history %v, want %v", got, want)
+	}
+	if df.Metadata.Source != "trips.csv" {
+		t.Errorf("source %q, want trips.csv", df.Metadata.Source)
+	}
+	if !slices.Equal(h[0].InputRows, []int{3}) || !slices.Equal(h[3].InputRows, []int{2, 2}) {
+		t.Errorf("input rows of Where %v and Join %v", h[0].InputRows, h[3].InputRows)
+	}
+	if r := h[3].Right; r == nil || r.Source != "vendors.csv" {
+		t.Errorf("right input of Join %+v, want vendors.csv", r)
+	}
+
+	// the lineage of the columns of every step
+	lineage := func(e HistoryEntry) []string {
+		columns := make([]string, len(e.Columns))
+		for i, c := range e.Columns {
+			columns[i] = c.String()
+		}
+		return columns
+	}
+	for i, want := range [][]string{
+		{"Vendor_id <- Vendor_id", "Fare <- Fare", "Tip <- Tip"},
+		{"Vendor_id <- Vendor_id", "Fare <- Fare", "Tip <- f(Tip, Fare)"},
+		{"Vendor_id <- Vendor_id", "Fare <- Fare", "Total <- Tip"},
+		{"Vendor_id <- Vendor_id", "Fare <- Fare", "Total <- Total", "Vendor_id <- right.Vendor_id", "name <- right.name"},
+		{"name <- name", "Total <- Total"},
+	} {
+		if got := lineage(h[i]); !slices.Equal(got, want) {
+			t.Errorf("columns of %s = %q, want %q", h[i], got, want)
+		}
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	_, df := lineageFrame(t)
+	var b strings.Builder
+	if err := WriteHistory(&b, df.Metadata); err != nil {
+		t.Fatal(err)
+	}
+	want := `trips.csv
+1. Where(Fare != '3')
+   3 rows in, at 0001-01-01T00:00:00Z, took 0s
+2. Transform(map(Tip, Fare[#index] + Tip[#index]))
+   2 rows in, at 0001-01-01T00:00:00Z, took 0s
+   Tip <- f(Tip, Fare)
+3. RenameColumn(Tip, Total)
+   2 rows in, at 0001-01-01T00:00:00Z, took 0s
+   Total <- Tip
+4. Join(Vendor_id)
+   2 + 2 rows in, at 0001-01-01T00:00:00Z, took 0s
+   Vendor_id <- right.Vendor_id
+   name <- right.name
+   right input:
+     vendors.csv
+5. Project(name, Total)
+   2 rows in, at 0001-01-01T00:00:00Z, took 0s
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteHistory =\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	if err := WriteHistoryDOT(&b, df.Metadata); err != nil {
+		t.Fatal(err)
+	}
+	want = `digraph lineage {
+	rankdir=LR;
+	node [shape=box];
+	n1 [label="trips.csv", shape=note];
+	n2 [label="Where(Fare != '3')"];
+	n1 -> n2 [label="3 rows"];
+	n3 [label="Transform(map(Tip, Fare[#index] + Tip[#index]))\nTip <- f(Tip, Fare)"];
+	n2 -> n3 [label="2 rows"];
+	n4 [label="RenameColumn(Tip, Total)\nTotal <- Tip"];
+	n3 -> n4 [label="2 rows"];
+	n5 [label="vendors.csv", shape=note];
+	n6 [label="Join(Vendor_id)\nVendor_id <- right.Vendor_id\nname <- right.name"];
+	n4 -> n6 [label="2 rows"];
+	n5 -> n6 [label="right, 2 rows", style=dashed];
+	n7 [label="Project(name, Total)"];
+	n6 -> n7 [label="2 rows"];
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteHistoryDOT =\n%s\nwant\n%s", got, want)
+	}
+
+	// a frame without a source or history
+	b.Reset()
+	WriteHistory(&b, Metadata{})
+	if got := b.String(); got != "unknown source\n" {
+		t.Errorf("empty history = %q", got)
+	}
+}
@@ -0,0 +1,235 @@
+package sharedlibrary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// prefixLength is how much of the start of a file identifies it. A file
+// whose first bytes changed since the last import was rotated.
+const prefixLength = 4096
+
+// ImportState is what an incremental import has read of every source file,
+// it is kept in a JSON state file between runs.
+type ImportState struct {
+	Sources map[string]*SourceState `json:"sources"`
+}
+
+// SourceState is how far a source file was read. Prefix is the hash of the
+// first PrefixLength bytes of the file, it tells whether the file at the
+// same path is still the one that was read.
+type SourceState struct {
+	Offset       int64    `json:"offset"`
+	Header       []string `json:"header"`
+	PrefixLength int64    `json:"prefixLength"`
+	Prefix       string   `json:"prefix"`
+}
+
+// LoadImportState reads a state file, a missing file is an empty state.
+func LoadImportState(path string) (*ImportState, error) {
+	state := &ImportState{Sources: make(map[string]*SourceState)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if state.Sources == nil {
+		state.Sources = make(map[string]*SourceState)
+	}
+	return state, nil
+}
+
+// Save writes the state file, under a temporary name first so that the
+// state of the last run stays intact when writing fails.
+func (s *ImportState) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// importChunk is the part of a file read by an import.
+type importChunk struct {
+	path       string
+	start, end int64
+	header     []string
+	rows       [][]any
+}
+
+// readChunk reads the complete lines of a file from offset on. A file read
+// from the start begins with its header, otherwise header is the header the
+// file had. A last line without a newline is still being written and is
+// left for the next import.
+func readChunk(path string, offset int64, header []string, opts CSVOptions) (*importChunk, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	c := &importChunk{path: path, start: offset, end: offset + int64(len(data)), header: header}
+	r, err := newCSVRecords(bytes.NewReader(data), path, header, opts)
+	if err == io.EOF {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.header = r.header
+	for {
+		record, err := r.next()
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.rows = append(c.rows, record)
+	}
+}
+
+// prefixHash returns the hash of the first n bytes of a file and false when
+// the file is shorter.
+func prefixHash(path string, n int64) (string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+	b := make([]byte, n)
+	if _, err := io.ReadFull(file, b); err == io.ErrUnexpectedEOF || err == io.EOF {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return HashBytes(b), true, nil
+}
+
+// rotated returns the file a source was renamed to when it was rotated: a
+// file in the same directory whose name starts with the name of the source
+// and that starts with the bytes that were read.
+func rotated(path string, state *SourceState, sources []string) (string, error) {
+	matches, err := filepath.Glob(path + "*")
+	if err != nil {
+		return "", err
+	}
+	for _, m := range matches {
+		if slices.Contains(sources, m) {
+			continue
+		}
+		if fi, err := os.Stat(m); err != nil || !fi.Mode().IsRegular() || fi.Size() < state.Offset {
+			continue
+		}
+		hash, found, err := prefixHash(m, state.PrefixLength)
+		if err != nil {
+			return "", err
+		}
+		if found && hash == state.Prefix {
+			return m, nil
+		}
+	}
+	return "", nil
+}
+
+// ImportNew reads the rows added to the source files since the state was
+// last updated and updates it. A source that was rotated since is read to
+// its end under its new name, then the new file is read from its start.
+// All sources must have the same header, their records are parsed as opts
+// say. The Source of the metadata of the frame names the byte ranges that
+// were read, as path:start-end.
+func (s *ImportState) ImportNew(sources []string, opts CSVOptions) (*DataFrame, error) {
+	chunks := make([]*importChunk, 0)
+	for _, path := range sources {
+		offset, header := int64(0), []string(nil)
+		if state, found := s.Sources[path]; found {
+			hash, found, err := prefixHash(path, state.PrefixLength)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			if found && hash == state.Prefix {
+				offset, header = state.Offset, state.Header
+			} else {
+				old, err := rotated(path, state, sources)
+				if err != nil {
+					return nil, err
+				}
+				if old != "" {
+					c, err := readChunk(old, state.Offset, state.Header, opts)
+					if err != nil {
+						return nil, err
+					}
+					chunks = append(chunks, c)
+					if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+						// the rotated file still starts with the same bytes,
+						// the next import continues from where it stopped
+						s.Sources[path] = &SourceState{Offset: c.end, Header: c.header, PrefixLength: state.PrefixLength, Prefix: state.Prefix}
+						continue
+					}
+				}
+			}
+		}
+		c, err := readChunk(path, offset, header, opts)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+
+		next := &SourceState{Offset: c.end, Header: c.header, PrefixLength: min(c.end, prefixLength)}
+		if next.Prefix, _, err = prefixHash(path, next.PrefixLength); err != nil {
+			return nil, err
+		}
+		s.Sources[path] = next
+	}
+
+	var header []string
+	read := make([]string, 0, len(chunks))
+	rows := make([][]any, 0)
+	for _, c := range chunks {
+		if c.header == nil {
+			continue
+		}
+		if header == nil {
+			header = c.header
+		} else if !slices.Equal(header, c.header) {
+			return nil, fmt.Errorf("%s: header %s differs from %s", c.path, strings.Join(c.header, ","), strings.Join(header, ","))
+		}
+		read = append(read, fmt.Sprintf("%s:%d-%d", c.path, c.start, c.end))
+		rows = append(rows, c.rows...)
+	}
+	if header == nil {
+		return nil, errors.New("no header found in the source files")
+	}
+
+	d, err := newCSVFrame(strings.Join(read, ", "), header, rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	d.Metadata.Rows = len(rows)
+	d.Metadata.Columns = len(header)
+	return d, nil
+}
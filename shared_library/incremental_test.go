@@ -0,0 +1,107 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportNew(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log.csv")
+	statePath := filepath.Join(dir, "state.json")
+	// importNew runs an import with the state file and returns the ids read
+	importNew := func() ([]string, string) {
+		t.Helper()
+		state, err := LoadImportState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := state.ImportNew([]string{log}, CSVOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := state.Save(statePath); err != nil {
+			t.Fatal(err)
+		}
+		return columnStrings(t, d, "id"), d.Metadata.Source
+	}
+
+	appendFile(t, log, "id,event\n1,start\n2,run\n")
+	ids, source := importNew()
+	if !slices.Equal(ids, []string{"1", "2"}) || source != log+":0-23" {
+		t.Errorf("first import = %v from %s", ids, source)
+	}
+
+	// a line without its newline is still being written
+	appendFile(t, log, "3,stop\n4,sta")
+	ids, source = importNew()
+	if !slices.Equal(ids, []string{"3"}) || source != log+":23-30" {
+		t.Errorf("second import = %v from %s", ids, source)
+	}
+	appendFile(t, log, "rt\n")
+	if ids, _ = importNew(); !slices.Equal(ids, []string{"4"}) {
+		t.Errorf("third import = %v, want [4]", ids)
+	}
+	if ids, _ = importNew(); len(ids) != 0 {
+		t.Errorf("import without new rows = %v", ids)
+	}
+
+	// the log is rotated after more rows were written to it
+	appendFile(t, log, "5,run\n")
+	if err := os.Rename(log, log+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, log, "id,event\n6,start\n")
+	ids, source = importNew()
+	if !slices.Equal(ids, []string{"5", "6"}) {
+		t.Errorf("import after a rotation = %v, want [5 6]", ids)
+	}
+	if want := fmt.Sprintf("%s.1:38-44, %s:0-17", log, log); source != want {
+		t.Errorf("source = %s, want %s", source, want)
+	}
+	if ids, _ = importNew(); len(ids) != 0 {
+		t.Errorf("import after a rotation without new rows = %v", ids)
+	}
+}
+
+func TestImportNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestFile(t, "a.csv", "id,event\n1,start\n")
+	b := writeTestFile(t, "b.csv", "id,kind\n2,run\n")
+	state, err := LoadImportState(filepath.Join(dir, "missing.json"))
+	if err != nil || len(state.Sources) != 0 {
+		t.Fatalf("state of a missing file = %+v, %v", state, err)
+	}
+	if _, err := state.ImportNew([]string{a, b}, CSVOptions{}); err == nil || !strings.Contains(err.Error(), "header id,kind differs from id,event") {
+		t.Errorf("err = %v, want the header of b to differ", err)
+	}
+	empty := writeTestFile(t, "empty.csv", "")
+	state, _ = LoadImportState(filepath.Join(dir, "missing.json"))
+	if _, err := state.ImportNew([]string{empty}, CSVOptions{}); err == nil || err.Error() != "no header found in the source files" {
+		t.Errorf("err = %v, want no header", err)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	os.WriteFile(broken, []byte("{"), 0o644)
+	if _, err := LoadImportState(broken); err == nil || !strings.HasPrefix(err.Error(), broken) {
+		t.Errorf("err = %v, want an error naming %s", err, broken)
+	}
+}
@@ -0,0 +1,266 @@
+package sharedlibrary
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+)
+
+// IndexKind selects how CreateIndex organises a column index.
+type IndexKind string
+
+const (
+	// HashIndex maps every value to its rows and serves equality lookups
+	// in Where and Join.
+	HashIndex IndexKind = "hash"
+	// SortedIndex keeps the values in order, like the leaves of a B-tree,
+	// and serves equality and range lookups in Where.
+	SortedIndex IndexKind = "sorted"
+)
+
+// indexEntry is a value of a sorted index and the row it comes from.
+type indexEntry struct {
+	value any
+	row   int
+}
+
+// columnIndex holds the indexes built for one column.
+type columnIndex struct {
+	hash   map[string][]int
+	sorted []indexEntry
+}
+
+// valueKey returns the string used to compare values in hash lookups, Join
+// and GroupBy. Decimals are normalised so that 1.50 and 1.5 share a key.
+func valueKey(v any) string {
+	if dec, ok := v.(Decimal); ok {
+		s := dec.String()
+		if strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// CreateIndex builds an index of the given kind on a column. The index is
+// used by Where for equality and range predicates and, for hash indexes, by
+// Join when the DataFrame is the right side. It is dropped when the column
+// is replaced or modified.
+func (d *DataFrame) CreateIndex(fieldname string, kind IndexKind) error {
+	x := d.Schema.GetField(fieldname)
+	if x < 0 {
+		return errors.New("field not found")
+	}
+	column := d.Data.getColumn(x)
+	idx := d.indexes[fieldname]
+	if idx == nil {
+		idx = &columnIndex{}
+	}
+	switch kind {
+	case HashIndex:
+		idx.hash = make(map[string][]int)
+		for i, v := range column {
+			key := valueKey(v)
+			idx.hash[key] = append(idx.hash[key], i)
+		}
+	case SortedIndex:
+		// nil values can not be compared and are left out
+		entries := make([]indexEntry, 0, len(column))
+		for i, v := range column {
+			if v != nil {
+				entries = append(entries, indexEntry{value: v, row: i})
+			}
+		}
+		var sortErr error
+		slices.SortStableFunc(entries, func(a, b indexEntry) int {
+			c, err := compareValues(a.value, b.value)
+			if err != nil && sortErr == nil {
+				sortErr = err
+			}
+			return c
+		})
+		if sortErr != nil {
+			return fmt.Errorf("column %s can not be sorted: %w", fieldname, sortErr)
+		}
+		idx.sorted = entries
+	default:
+		return fmt.Errorf("unknown index kind %q", kind)
+	}
+	if d.indexes == nil {
+		d.indexes = make(map[string]*columnIndex)
+	}
+	d.indexes[fieldname] = idx
+	return nil
+}
+
+// DropIndex removes all indexes on a column.
+func (d *DataFrame) DropIndex(fieldname string) {
+	delete(d.indexes, fieldname)
+}
+
+// GetIndexKinds returns the kinds of the indexes built on a column.
+func (d *DataFrame) GetIndexKinds(fieldname string) []IndexKind {
+	kinds := make([]IndexKind, 0)
+	if idx := d.indexes[fieldname]; idx != nil {
+		if idx.hash != nil {
+			kinds = append(kinds, HashIndex)
+		}
+		if idx.sorted != nil {
+			kinds = append(kinds, SortedIndex)
+		}
+	}
+	return kinds
+}
+
+// lookup returns the rows of the index whose value compares to c with the
+// operator op. It returns false when the index can not answer the lookup.
+func (idx *columnIndex) lookup(op string, c any) ([]int, bool) {
+	if op == "==" && idx.hash != nil {
+		return idx.hash[valueKey(c)], true
+	}
+	if idx.sorted == nil {
+		return nil, false
+	}
+	var searchErr error
+	// first returns the position of the first entry for which test is true
+	first := func(test func(c int) bool) int {
+		return sort.Search(len(idx.sorted), func(i int) bool {
+			cmp, err := compareValues(idx.sorted[i].value, c)
+			if err != nil {
+				searchErr = err
+			}
+			return test(cmp)
+		})
+	}
+	var entries []indexEntry
+	switch op {
+	case "==":
+		entries = idx.sorted[first(func(c int) bool { return c >= 0 }):first(func(c int) bool { return c > 0 })]
+	case ">":
+		entries = idx.sorted[first(func(c int) bool { return c > 0 }):]
+	case ">=":
+		entries = idx.sorted[first(func(c int) bool { return c >= 0 }):]
+	case "<":
+		entries = idx.sorted[:first(func(c int) bool { return c >= 0 })]
+	case "<=":
+		entries = idx.sorted[:first(func(c int) bool { return c > 0 })]
+	default:
+		return nil, false
+	}
+	if searchErr != nil {
+		return nil, false
+	}
+	rows := make([]int, len(entries))
+	for i, e := range entries {
+		rows[i] = e.row
+	}
+	slices.Sort(rows)
+	return rows, true
+}
+
+// constantValue returns the value of a literal in an expression.
+func constantValue(node ast.Node) (any, bool) {
+	switch n := node.(type) {
+	case *ast.IntegerNode:
+		return n.Value, true
+	case *ast.FloatNode:
+		return n.Value, true
+	case *ast.StringNode:
+		return n.Value, true
+	case *ast.BoolNode:
+		return n.Value, true
+	case *ast.UnaryNode:
+		if n.Operator != "-" {
+			return nil, false
+		}
+		switch v := n.Node.(type) {
+		case *ast.IntegerNode:
+			return -v.Value, true
+		case *ast.FloatNode:
+			return -v.Value, true
+		}
+	}
+	return nil, false
+}
+
+// flippedOperators turns "1 < x" into "x > 1".
+var flippedOperators = map[string]string{
+	"==": "==",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+// indexCandidates returns the rows that may satisfy a Where condition,
+// using the indexes of the DataFrame for comparisons between a column and a
+// literal combined with and/or. It returns false when no index applies.
+// The condition still has to be evaluated on the returned rows.
+func (d *DataFrame) indexCandidates(node ast.Node) ([]int, bool) {
+	if len(d.indexes) == 0 {
+		return nil, false
+	}
+	n, ok := node.(*ast.BinaryNode)
+	if !ok {
+		return nil, false
+	}
+	switch n.Operator {
+	case "&&", "and":
+		left, lok := d.indexCandidates(n.Left)
+		right, rok := d.indexCandidates(n.Right)
+		switch {
+		case lok && rok:
+			rows := make([]int, 0)
+			for _, r := range left {
+				if _, found := slices.BinarySearch(right, r); found {
+					rows = append(rows, r)
+				}
+			}
+			return rows, true
+		case lok:
+			return left, true
+		case rok:
+			return right, true
+		}
+	case "||", "or":
+		left, lok := d.indexCandidates(n.Left)
+		right, rok := d.indexCandidates(n.Right)
+		if lok && rok {
+			return slices.Compact(slices.Sorted(slices.Values(append(left, right...)))), true
+		}
+	case "==", "<", "<=", ">", ">=":
+		op := n.Operator
+		column, isColumn := n.Left.(*ast.IdentifierNode)
+		value, isConstant := constantValue(n.Right)
+		if !isColumn {
+			column, isColumn = n.Right.(*ast.IdentifierNode)
+			value, isConstant = constantValue(n.Left)
+			op = flippedOperators[op]
+		}
+		if !isColumn || !isConstant {
+			return nil, false
+		}
+		if idx := d.indexes[column.Value]; idx != nil {
+			return idx.lookup(op, value)
+		}
+	}
+	return nil, false
+}
+
+// whereCandidates parses a Where condition and returns the rows an index
+// narrows it down to.
+func (d *DataFrame) whereCandidates(value string) ([]int, bool) {
+	if len(d.indexes) == 0 {
+		return nil, false
+	}
+	tree, err := parseExpression(value)
+	if err != nil {
+		return nil, false
+	}
+	return d.indexCandidates(tree.Node)
+}
@@ -0,0 +1,134 @@
+package sharedlibrary
+
+import (
+	"slices"
+	"testing"
+)
+
+// vendorFrame returns a small taxi frame with a missing fare.
+func vendorFrame(t *testing.T) *DataFrame {
+	t.Helper()
+	return newTestFrame(t, "Vendor_id:int,Fare_amount:decimal(6,2)", []string{"Vendor_id", "Fare_amount", "zone"},
+		[]string{"2", "12.50", "a"},
+		[]string{"1", "3.25", "b"},
+		[]string{"1", "", "c"},
+		[]string{"3", "7.00", "a"},
+		[]string{"2", "1.5", "b"},
+		[]string{"-1", "9.99", "c"},
+	)
+}
+
+func TestCreateIndex(t *testing.T) {
+	d := vendorFrame(t)
+	if err := d.CreateIndex("Vendor_id", HashIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateIndex("Vendor_id", SortedIndex); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.GetIndexKinds("Vendor_id"), []IndexKind{HashIndex, SortedIndex}; !slices.Equal(got, want) {
+		t.Errorf("kinds = %v, want %v", got, want)
+	}
+	if err := d.CreateIndex("missing", HashIndex); err == nil {
+		t.Error("indexed a missing column")
+	}
+	if err := d.CreateIndex("zone", "btree"); err == nil {
+		t.Error("built an unknown index kind")
+	}
+
+	if err := d.RenameColumn("Vendor_id", "vendor"); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.GetIndexKinds("vendor"); len(got) != 2 {
+		t.Errorf("kinds after a rename = %v, want both", got)
+	}
+	if err := d.Transform("map(vendor, # + 1)"); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.GetIndexKinds("vendor"); len(got) != 0 {
+		t.Errorf("kinds after a transform = %v, want none", got)
+	}
+	d.CreateIndex("zone", HashIndex)
+	d.DropIndex("zone")
+	if got := d.GetIndexKinds("zone"); len(got) != 0 {
+		t.Errorf("kinds after DropIndex = %v, want none", got)
+	}
+}
+
+func TestIndexedWhere(t *testing.T) {
+	// hash and sorted are the rows each kind of index narrows the
+	// condition down to, nil when it does not apply.
+	conditions := []struct {
+		cond         string
+		hash, sorted []int
+	}{
+		{"Vendor_id == 1", []int{1, 2}, []int{1, 2}},
+		{"1 == Vendor_id", []int{1, 2}, []int{1, 2}},
+		{"Vendor_id > 1", nil, []int{0, 3, 4}},
+		{"Vendor_id >= -1 && Vendor_id < 2", nil, []int{1, 2, 5}},
+		{"2 <= Vendor_id", nil, []int{0, 3, 4}},
+		{"Vendor_id == 3 || Vendor_id == -1", []int{3, 5}, []int{3, 5}},
+		{"Vendor_id == 3 || zone == 'c'", nil, nil},
+		{"Vendor_id == 2 and zone == 'b'", []int{0, 4}, []int{0, 4}},
+		{"Fare_amount == 1.50", []int{4}, []int{4}},
+		// the missing fare can not be ordered, the sorted index leaves it out
+		{"Fare_amount != nil && Fare_amount >= 7", nil, []int{0, 3, 5}},
+		{"Fare_amount != nil && Fare_amount < 5 && Vendor_id == 1", []int{1, 2}, []int{1}},
+	}
+	for _, kind := range []IndexKind{HashIndex, SortedIndex} {
+		t.Run(string(kind), func(t *testing.T) {
+			d := vendorFrame(t)
+			for _, column := range []string{"Vendor_id", "Fare_amount"} {
+				if err := d.CreateIndex(column, kind); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, tt := range conditions {
+				want, err := vendorFrame(t).Where(tt.cond)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := d.Where(tt.cond)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(rows(t, got), rows(t, want)) {
+					t.Errorf("indexed Where(%s) = %v, want %v", tt.cond, rows(t, got), rows(t, want))
+				}
+				wantCandidates := tt.hash
+				if kind == SortedIndex {
+					wantCandidates = tt.sorted
+				}
+				candidates, indexed := d.whereCandidates(tt.cond)
+				if indexed != (wantCandidates != nil) || !slices.Equal(candidates, wantCandidates) {
+					t.Errorf("candidates of %s = %v, %v, want %v", tt.cond, candidates, indexed, wantCandidates)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexedJoin(t *testing.T) {
+	left := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "trip"},
+		[]string{"1", "t1"}, []string{"2", "t2"}, []string{"1", "t3"}, []string{"4", "t4"})
+	right := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "vendor"},
+		[]string{"1", "Creative"}, []string{"2", "VeriFone"}, []string{"2", "VeriFone2"})
+
+	want, err := left.Join(right, []string{"Vendor_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := right.CreateIndex("Vendor_id", HashIndex); err != nil {
+		t.Fatal(err)
+	}
+	got, err := left.Join(right, []string{"Vendor_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rows(t, got), rows(t, want)) {
+		t.Errorf("indexed Join = %v, want %v", rows(t, got), rows(t, want))
+	}
+	if n := got.GetNumberOfRows(); n != 4 {
+		t.Errorf("indexed Join has %d rows, want 4", n)
+	}
+}
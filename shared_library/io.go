@@ -0,0 +1,507 @@
+package sharedlibrary
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+func init() {
+	gob.Register(&DataFrame{})
+	gob.Register(&InternalDataStructure{})
+}
+
+// The frame streams between operators and in saved frames start with a
+// header that describes them, followed by the frames in the codec of the
+// header:
+//
+//	magic        4 bytes "OPFS"
+//	version      uint16, big endian
+//	codec        uint8, index in codecs
+//	compression  uint8, index in compressions
+//	length       uint32, big endian, of the descriptor
+//	descriptor   JSON, see WireHeader
+//
+// The header is never compressed, the frames after it are compressed as a
+// single stream of the compression of the header. From version 2 on every
+// frame is a FrameEnvelope, before it was a DataFrame. Streams written
+// before the header existed are read as version 0.
+const (
+	wireMagic = "OPFS"
+	// WireVersion is the version of the wire format this build writes and
+	// the newest it reads.
+	WireVersion = 2
+)
+
+// codecs and compressions are the names of the codec and compression
+// numbers of the header.
+var (
+	codecs       = []string{"gob"}
+	compressions = []string{"none", "zstd", "snappy", "lz4"}
+)
+
+// compression is the compression of the frame streams written by
+// NewFrameWriter.
+var compression atomic.Value
+
+// SetCompression sets the compression of the frame streams written from now
+// on: none, zstd, snappy or lz4. Empty is none. Readers find the
+// compression of a stream in its header.
+func SetCompression(name string) error {
+	if name == "" {
+		name = "none"
+	}
+	if !slices.Contains(compressions, name) {
+		return fmt.Errorf("unknown compression %q, use %v", name, compressions)
+	}
+	compression.Store(name)
+	return nil
+}
+
+// Compression returns the compression set with SetCompression.
+func Compression() string {
+	if name, ok := compression.Load().(string); ok {
+		return name
+	}
+	return "none"
+}
+
+// compressor is a compressing writer that can be flushed after every frame.
+type compressor interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+func newCompressor(w io.Writer, name string) (compressor, error) {
+	switch name {
+	case "zstd":
+		return zstd.NewWriter(w)
+	case "snappy":
+		return snappy.NewBufferedWriter(w), nil
+	case "lz4":
+		return &lz4Writer{Writer: lz4.NewWriter(w), w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", name)
+}
+
+// lz4Writer ends an lz4 frame at every flush, an lz4 reader only returns the
+// data of a frame at its end or once it fills the buffer read into.
+type lz4Writer struct {
+	*lz4.Writer
+	w io.Writer
+}
+
+func (l *lz4Writer) Flush() error {
+	if err := l.Writer.Close(); err != nil {
+		return err
+	}
+	l.Writer.Reset(l.w)
+	return nil
+}
+
+// lz4Reader reads the lz4 frames of an lz4Writer one after the other. The
+// data of a frame is returned before waiting for the next frame, which
+// only comes once the operator that reads the stream asked for more.
+type lz4Reader struct {
+	*lz4.Reader
+	r     *bufio.Reader
+	ended bool
+}
+
+func (l *lz4Reader) Read(p []byte) (int, error) {
+	for {
+		if l.ended {
+			if _, more := l.r.Peek(1); more != nil {
+				return 0, io.EOF
+			}
+			l.Reader.Reset(l.r)
+			l.ended = false
+		}
+		n, err := l.Reader.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		l.ended = true
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// newDecompressor returns a reader of the frames after the header, and a
+// function to release it.
+func newDecompressor(r *bufio.Reader, name string) (io.Reader, func(), error) {
+	switch name {
+	case "none":
+		return r, func() {}, nil
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return d, d.Close, nil
+	case "snappy":
+		return snappy.NewReader(r), func() {}, nil
+	case "lz4":
+		return &lz4Reader{Reader: lz4.NewReader(r), r: r}, func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown compression %q", name)
+}
+
+// WireField is a column of the schema in the header of a frame stream.
+type WireField struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Precision int32  `json:"precision,omitempty"`
+	Scale     int32  `json:"scale,omitempty"`
+}
+
+// WireHeader is the header of a frame stream. Fields is the schema of its
+// frames. TraceID and TraceParent carry the trace of the pipeline to the
+// next operator, see OperatorMetrics and StartTracing.
+type WireHeader struct {
+	Version     int         `json:"-"`
+	Codec       string      `json:"-"`
+	Compression string      `json:"-"`
+	Fields      []WireField `json:"schema"`
+	TraceID     string      `json:"trace_id,omitempty"`
+	TraceParent string      `json:"traceparent,omitempty"`
+}
+
+func wireFields(s *Schema) []WireField {
+	fields := make([]WireField, len(s.Fields))
+	for i, f := range s.Fields {
+		fields[i] = WireField{Name: f.FieldName, Type: f.FieldType, Precision: f.Precision, Scale: f.Scale}
+	}
+	return fields
+}
+
+// writeHeader writes the header for frames with a schema.
+func writeHeader(w io.Writer, h WireHeader) error {
+	descriptor, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 0, 12+len(descriptor))
+	b = append(b, wireMagic...)
+	b = binary.BigEndian.AppendUint16(b, WireVersion)
+	b = append(b, byte(slices.Index(codecs, h.Codec)), byte(slices.Index(compressions, h.Compression)))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(descriptor)))
+	_, err = w.Write(append(b, descriptor...))
+	return err
+}
+
+// readHeader reads the header of a stream and checks that this build can
+// read it. A stream without a header is version 0, unless it is text, the
+// mistake of piping a CSV file into an operator.
+func readHeader(r *bufio.Reader) (WireHeader, error) {
+	magic, err := r.Peek(len(wireMagic))
+	if err == io.EOF && len(magic) == 0 {
+		return WireHeader{}, io.EOF
+	}
+	if string(magic) != wireMagic {
+		if line, ok := textLine(r); ok {
+			return WireHeader{}, fmt.Errorf("text starting with %s, not a frame stream; CSV files are read with importer", strconv.Quote(line))
+		}
+		return WireHeader{Codec: "gob", Compression: "none"}, nil
+	}
+	fixed := make([]byte, 12)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return WireHeader{}, fmt.Errorf("truncated frame stream header: %w", err)
+	}
+	h := WireHeader{Version: int(binary.BigEndian.Uint16(fixed[4:]))}
+	if h.Version > WireVersion {
+		return h, fmt.Errorf("written in version %d of the frame stream format, this build reads up to version %d; upgrade the operators that read it", h.Version, WireVersion)
+	}
+	if c := int(fixed[6]); c < len(codecs) {
+		h.Codec = codecs[c]
+	} else {
+		return h, fmt.Errorf("codec %d of the frame stream is unknown to this build, it knows %v", c, codecs)
+	}
+	if c := int(fixed[7]); c < len(compressions) {
+		h.Compression = compressions[c]
+	} else {
+		return h, fmt.Errorf("compression %d of the frame stream is unknown to this build, it knows %v", c, compressions)
+	}
+	descriptor := make([]byte, binary.BigEndian.Uint32(fixed[8:]))
+	if _, err := io.ReadFull(r, descriptor); err != nil {
+		return h, fmt.Errorf("truncated frame stream header: %w", err)
+	}
+	if err := json.Unmarshal(descriptor, &h); err != nil {
+		return h, fmt.Errorf("frame stream header: %w", err)
+	}
+	return h, nil
+}
+
+// textLine returns the first line of the input when the input starts with
+// text rather than binary data.
+func textLine(r *bufio.Reader) (string, bool) {
+	b, _ := r.Peek(256)
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	} else if len(b) == 256 {
+		// a multi-byte character may be cut at the end, binary data stays
+		// invalid without its last bytes
+		for i := 1; i < utf8.UTFMax && !utf8.Valid(b); i++ {
+			b = b[:len(b)-1]
+		}
+	}
+	if len(b) == 0 || !utf8.Valid(b) {
+		return "", false
+	}
+	for _, c := range string(b) {
+		if c < ' ' && c != '\t' && c != '\r' {
+			return "", false
+		}
+	}
+	return string(bytes.TrimRight(b, "\r")), true
+}
+
+// FrameReader decodes a frame stream, a streaming operator writes one frame
+// for every batch of rows. A reader stopped before the end must be closed.
+type FrameReader struct {
+	r       *bufio.Reader
+	header  *WireHeader
+	err     error
+	decoder *gob.Decoder
+	release func()
+	frames  int
+}
+
+// NewFrameReader returns a FrameReader reading r. The header is read with
+// the first call of Header or Next.
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{r: bufio.NewReader(r)}
+}
+
+// Header returns the header of the stream, it is an error when this build
+// can not read the stream.
+func (f *FrameReader) Header() (WireHeader, error) {
+	if f.header == nil && f.err == nil {
+		h, err := readHeader(f.r)
+		var frames io.Reader
+		if err == nil {
+			frames, f.release, err = newDecompressor(f.r, h.Compression)
+		}
+		f.header, f.err = &h, err
+		if err == nil {
+			f.decoder = gob.NewDecoder(frames)
+		}
+	}
+	if f.header == nil {
+		return WireHeader{}, f.err
+	}
+	return *f.header, f.err
+}
+
+// Next returns the next frame of the stream, or io.EOF after the last one.
+func (f *FrameReader) Next() (*DataFrame, error) {
+	h, err := f.Header()
+	if err != nil {
+		return nil, err
+	}
+	b, err := f.decode(h.Version)
+	if err != nil {
+		if err == io.EOF {
+			f.Close()
+		}
+		if err != io.EOF && f.frames == 0 && h.Version == 0 {
+			return nil, fmt.Errorf("not a frame stream: %w", err)
+		}
+		return nil, err
+	}
+	if f.frames == 0 && h.Version > 0 && !slices.Equal(h.Fields, wireFields(&b.Schema)) {
+		return nil, errors.New("the schema of the frames differs from the schema in the stream header")
+	}
+	f.frames++
+	return b, nil
+}
+
+// Close releases the decompressor of the stream, which Next does at the end
+// of the stream. It does not close the reader the stream is read from.
+func (f *FrameReader) Close() error {
+	if f.release != nil {
+		f.release()
+		f.release = nil
+	}
+	return nil
+}
+
+// decode decodes a frame of a stream of a version.
+func (f *FrameReader) decode(version int) (*DataFrame, error) {
+	if version < 2 {
+		var b DataFrame
+		if err := f.decoder.Decode(&b); err != nil {
+			return nil, err
+		}
+		return &b, nil
+	}
+	var e FrameEnvelope
+	if err := f.decoder.Decode(&e); err != nil {
+		return nil, err
+	}
+	return e.DataFrame()
+}
+
+// ReadAll reads the stream to its end and concatenates its frames, they
+// must have the same columns.
+func (f *FrameReader) ReadAll() (*DataFrame, error) {
+	first, err := f.Next()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]*Data, first.GetNumberOfColumns())
+	for i := range columns {
+		column := first.Data.getColumn(i)
+		columns[i] = &column
+	}
+	rows, batches := first.GetNumberOfRows(), 1
+	for {
+		next, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := first.Schema.MatchByPosition(&next.Schema); err != nil {
+			return nil, fmt.Errorf("frame %d of the stream: %w", batches+1, err)
+		}
+		for i, column := range columns {
+			*column = append(*column, next.Data.getColumn(i)...)
+		}
+		rows += next.GetNumberOfRows()
+		batches++
+	}
+	if batches == 1 {
+		return first, nil
+	}
+	// the indexes of the first frame are built again for all the rows
+	e := NewFrameEnvelope(first)
+	e.Data = &InternalDataStructure{Data: columns, Rows: rows, Columns: len(columns)}
+	return e.DataFrame()
+}
+
+// FrameWriter encodes a frame stream. The header is written with the first
+// frame, with its schema; set TraceID, TraceParent and Compression before.
+// A compressed stream must be closed.
+type FrameWriter struct {
+	w           io.Writer
+	encoder     *gob.Encoder
+	compressor  compressor
+	TraceID     string
+	TraceParent string
+	Compression string
+}
+
+// NewFrameWriter returns a FrameWriter writing to w with the compression
+// set with SetCompression.
+func NewFrameWriter(w io.Writer) *FrameWriter {
+	return &FrameWriter{w: w, Compression: Compression()}
+}
+
+// Write encodes a frame, the types of a stream are only sent once. A
+// compressed frame is flushed so that the next operator can read it.
+func (f *FrameWriter) Write(d *DataFrame) error {
+	if f.encoder == nil {
+		if f.Compression == "" {
+			f.Compression = "none"
+		}
+		if !slices.Contains(compressions, f.Compression) {
+			return fmt.Errorf("unknown compression %q, use %v", f.Compression, compressions)
+		}
+		h := WireHeader{Codec: "gob", Compression: f.Compression, Fields: wireFields(&d.Schema), TraceID: f.TraceID, TraceParent: f.TraceParent}
+		if err := writeHeader(f.w, h); err != nil {
+			return err
+		}
+		frames := f.w
+		if f.Compression != "none" {
+			c, err := newCompressor(f.w, f.Compression)
+			if err != nil {
+				return err
+			}
+			f.compressor, frames = c, c
+		}
+		f.encoder = gob.NewEncoder(frames)
+	}
+	if err := f.encoder.Encode(NewFrameEnvelope(d)); err != nil {
+		return err
+	}
+	if f.compressor != nil {
+		return f.compressor.Flush()
+	}
+	return nil
+}
+
+// Close ends a compressed stream, it does not close the writer of the
+// stream.
+func (f *FrameWriter) Close() error {
+	if f.compressor == nil {
+		return nil
+	}
+	c := f.compressor
+	f.compressor = nil
+	return c.Close()
+}
+
+// ReadFrame decodes a frame stream, the format the operators use on their
+// pipes. A stream is read to its end and its frames are concatenated, they
+// must have the same columns.
+func ReadFrame(r io.Reader) (*DataFrame, error) {
+	frames := NewFrameReader(r)
+	defer frames.Close()
+	return frames.ReadAll()
+}
+
+// ReadFrameFile decodes a frame stream from a file, "-" reads stdin.
+func ReadFrameFile(path string) (*DataFrame, error) {
+	if path == "-" {
+		return ReadFrame(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadFrame(file)
+}
+
+// WriteFrame encodes a DataFrame as a frame stream.
+func WriteFrame(w io.Writer, d *DataFrame) error {
+	f := NewFrameWriter(w)
+	if err := f.Write(d); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WriteFrameFile encodes a DataFrame to a file, "-" writes stdout.
+func WriteFrameFile(path string, d *DataFrame) error {
+	if path == "-" {
+		return WriteFrame(os.Stdout, d)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteFrame(file, d); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,231 @@
+package sharedlibrary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// headerBytes returns the bytes of a header for frames of d.
+func headerBytes(t *testing.T, d *DataFrame) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := writeHeader(&b, WireHeader{Codec: "gob", Compression: "none", Fields: wireFields(&d.Schema)}); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestWireHeader(t *testing.T) {
+	d := newTestFrame(t, "Fare_amount:decimal(6,2)", []string{"Vendor_id", "Fare_amount"}, []string{"1", "12.50"})
+	var b bytes.Buffer
+	frames := NewFrameWriter(&b)
+	frames.TraceID = "run-1"
+	if err := frames.Write(d); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String()[:4]; got != "OPFS" {
+		t.Errorf("stream starts with %q, want the magic OPFS", got)
+	}
+
+	h, err := NewFrameReader(bytes.NewReader(b.Bytes())).Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WireField{{Name: "Vendor_id", Type: "string"}, {Name: "Fare_amount", Type: DecimalType, Precision: 6, Scale: 2}}
+	if h.Version != WireVersion || h.Codec != "gob" || h.Compression != "none" || h.TraceID != "run-1" || !slices.Equal(h.Fields, want) {
+		t.Errorf("header %+v", h)
+	}
+}
+
+func TestWireOlderVersions(t *testing.T) {
+	d := newTestFrame(t, "", []string{"name"}, []string{"anna"}, []string{"bob"})
+
+	// version 0 streams have no header, version 1 streams have frames that
+	// are DataFrames instead of FrameEnvelopes
+	var v0 bytes.Buffer
+	if err := gob.NewEncoder(&v0).Encode(d); err != nil {
+		t.Fatal(err)
+	}
+	v1 := headerBytes(t, d)
+	binary.BigEndian.PutUint16(v1[4:], 1)
+	v1 = append(v1, v0.Bytes()...)
+
+	for version, stream := range [][]byte{v0.Bytes(), v1} {
+		frames := NewFrameReader(bytes.NewReader(stream))
+		df, err := frames.ReadAll()
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		if h, _ := frames.Header(); h.Version != version {
+			t.Errorf("version %d stream read as version %d", version, h.Version)
+		}
+		if got := columnStrings(t, df, "name"); !slices.Equal(got, []string{"anna", "bob"}) {
+			t.Errorf("version %d: name = %v", version, got)
+		}
+	}
+}
+
+func TestWireHeaderErrors(t *testing.T) {
+	d := newTestFrame(t, "", []string{"name"}, []string{"anna"})
+	header := headerBytes(t, d)
+	with := func(offset int, value byte) []byte {
+		b := slices.Clone(header)
+		b[offset] = value
+		return b
+	}
+	var other bytes.Buffer
+	if err := gob.NewEncoder(&other).Encode(NewFrameEnvelope(newTestFrame(t, "", []string{"zone"}, []string{"a"}))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		stream []byte
+		err    string
+	}{
+		{"csv", []byte("Vendor_id,Fare_amount\n1,12.50\n"), `text starting with "Vendor_id,Fare_amount", not a frame stream; CSV files are read with importer`},
+		{"long text", []byte("a" + strings.Repeat("é", 200)), `text starting with "aéé`},
+		{"newer version", with(5, 3), "written in version 3 of the frame stream format, this build reads up to version 2; upgrade the operators that read it"},
+		{"codec", with(6, 7), "codec 7 of the frame stream is unknown to this build, it knows [gob]"},
+		{"compression", with(7, 9), "compression 9 of the frame stream is unknown to this build, it knows [none zstd snappy lz4]"},
+		{"truncated", header[:8], "truncated frame stream header: unexpected EOF"},
+		{"binary", []byte{0x05, 0xff, 0x00, 0x01, 0x02, 0x03}, "not a frame stream: "},
+		// binary data is no text because its first bytes are printable
+		{"long binary", []byte("x\xff" + strings.Repeat("a", 300)), "not a frame stream: "},
+		{"schema", append(slices.Clone(header), other.Bytes()...), "the schema of the frames differs from the schema in the stream header"},
+	} {
+		_, err := NewFrameReader(bytes.NewReader(tt.stream)).Next()
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.err)
+		}
+	}
+
+	if _, err := NewFrameReader(bytes.NewReader(nil)).Next(); err != io.EOF {
+		t.Errorf("empty stream: got %v, want io.EOF", err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	records := make([][]string, 500)
+	for i := range records {
+		records[i] = []string{fmt.Sprint(i % 3), "Manhattan", "credit card"}
+	}
+	d := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "borough", "payment"}, records...)
+
+	sizes := make(map[string]int)
+	for _, name := range compressions {
+		var b bytes.Buffer
+		frames := NewFrameWriter(&b)
+		frames.Compression = name
+		for range 2 {
+			if err := frames.Write(d); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		if err := frames.Close(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		sizes[name] = b.Len()
+
+		// a reader stopped before the end releases its decompressor on Close
+		partial := NewFrameReader(bytes.NewReader(b.Bytes()))
+		if _, err := partial.Next(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := partial.Close(); err != nil || partial.release != nil {
+			t.Errorf("%s: Close = %v, the decompressor was not released", name, err)
+		}
+		if err := partial.Close(); err != nil {
+			t.Errorf("%s: second Close = %v", name, err)
+		}
+
+		// the reader finds the compression in the header
+		reader := NewFrameReader(&b)
+		df, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if h, _ := reader.Header(); h.Compression != name {
+			t.Errorf("%s stream has compression %s in its header", name, h.Compression)
+		}
+		if got := columnStrings(t, df, "Vendor_id"); len(got) != 1000 || got[4] != "1" || df.Schema.Fields[0].FieldType != "int" {
+			t.Errorf("%s: %d rows of %s, Vendor_id[4] = %s", name, len(got), df.Schema.Fields[0].FieldType, got[4])
+		}
+	}
+	for _, name := range compressions[1:] {
+		if sizes[name] >= sizes["none"] {
+			t.Errorf("%s stream of %d bytes is not smaller than %d bytes uncompressed", name, sizes[name], sizes["none"])
+		}
+	}
+}
+
+func TestCompressedStreaming(t *testing.T) {
+	for _, name := range compressions {
+		r, w := io.Pipe()
+		received := make(chan struct{})
+		go func() {
+			frames := NewFrameWriter(w)
+			frames.Compression = name
+			for i := range 3 {
+				if err := frames.Write(newTestFrame(t, "", []string{"batch"}, []string{fmt.Sprint(i)})); err != nil {
+					w.CloseWithError(err)
+					return
+				}
+				// the next batch is only written once this one arrived
+				<-received
+			}
+			w.CloseWithError(frames.Close())
+		}()
+
+		frames := NewFrameReader(r)
+		for i := range 3 {
+			df, err := frames.Next()
+			if err != nil {
+				t.Fatalf("%s, batch %d: %v", name, i, err)
+			}
+			if got := columnStrings(t, df, "batch"); got[0] != fmt.Sprint(i) {
+				t.Errorf("%s: batch %d is %v", name, i, got)
+			}
+			received <- struct{}{}
+		}
+		if _, err := frames.Next(); err != io.EOF {
+			t.Errorf("%s: after the last batch got %v, want io.EOF", name, err)
+		}
+	}
+}
+
+func TestSetCompression(t *testing.T) {
+	defer SetCompression("")
+	if err := SetCompression("gzip"); err == nil || err.Error() != `unknown compression "gzip", use [none zstd snappy lz4]` {
+		t.Errorf("SetCompression(gzip) = %v", err)
+	}
+	if err := SetCompression("zstd"); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewFrameWriter(io.Discard).Compression; got != "zstd" {
+		t.Errorf("NewFrameWriter after SetCompression(zstd) compresses with %s", got)
+	}
+	if err := SetCompression(""); err != nil || Compression() != "none" {
+		t.Errorf("SetCompression(\"\") = %v, compression %s", err, Compression())
+	}
+
+	// saved frames are compressed like the streams
+	SetCompression("snappy")
+	path := t.TempDir() + "/saved.gob"
+	if err := WriteFrameFile(path, newTestFrame(t, "", []string{"name"}, []string{"anna"})); err != nil {
+		t.Fatal(err)
+	}
+	df, err := ReadFrameFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, df, "name"); !slices.Equal(got, []string{"anna"}) {
+		t.Errorf("saved snappy frame read back as %v", got)
+	}
+}
@@ -0,0 +1,660 @@
+package sharedlibrary
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// LazyFrame records DataFrame operations as a logical plan instead of
+// executing them. Collect optimizes the plan, pushing Where conditions and
+// the columns that are actually used down into the CSV and Parquet readers,
+// and executes it.
+type LazyFrame struct {
+	plan planNode
+}
+
+// planNode is one step of a LazyFrame plan.
+type planNode interface {
+	// columns returns the names of the columns the step produces.
+	columns() []string
+	// collect executes the step and the steps it depends on.
+	collect(ctx context.Context) (*DataFrame, error)
+	// explain writes the step and its inputs, indented by depth.
+	explain(w *strings.Builder, depth int)
+}
+
+// Lazy starts a LazyFrame plan from the DataFrame.
+func (d *DataFrame) Lazy() *LazyFrame {
+	return &LazyFrame{plan: &frameNode{df: d}}
+}
+
+// ScanCSV starts a LazyFrame plan that reads a CSV file with a header row.
+// All columns are read as strings. The reader is consumed by Collect, so
+// the plan can be collected once.
+func ScanCSV(r *csv.Reader) *LazyFrame {
+	header, err := r.Read()
+	return &LazyFrame{plan: &csvScanNode{r: r, header: header, err: err}}
+}
+
+// ScanParquet starts a LazyFrame plan that reads a Parquet file. The
+// reader is consumed by Collect, so the plan can be collected once.
+func ScanParquet(r *reader.ParquetReader) *LazyFrame {
+	fields := parquetFields(r)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.FieldName
+	}
+	return &LazyFrame{plan: &parquetScanNode{r: r, header: header}}
+}
+
+// Project records DataFrame.Project.
+func (l *LazyFrame) Project(fields ...string) *LazyFrame {
+	return &LazyFrame{plan: &projectNode{input: l.plan, fields: fields}}
+}
+
+// Where records DataFrame.Where.
+func (l *LazyFrame) Where(value string) *LazyFrame {
+	return &LazyFrame{plan: &whereNode{input: l.plan, cond: value}}
+}
+
+// Transform records DataFrame.Transform.
+func (l *LazyFrame) Transform(value string) *LazyFrame {
+	return &LazyFrame{plan: &transformNode{input: l.plan, statement: value}}
+}
+
+// Join records DataFrame.Join with other as the right side.
+func (l *LazyFrame) Join(other *LazyFrame, keys []string) *LazyFrame {
+	return &LazyFrame{plan: &joinNode{left: l.plan, right: other.plan, keys: keys}}
+}
+
+// GroupBy records DataFrame.GroupBy.
+func (l *LazyFrame) GroupBy(keys []string, aggs ...Aggregation) *LazyFrame {
+	return &LazyFrame{plan: &groupByNode{input: l.plan, keys: keys, aggs: aggs}}
+}
+
+// Collect optimizes and executes the plan.
+func (l *LazyFrame) Collect() (*DataFrame, error) {
+	return l.CollectContext(context.Background())
+}
+
+// CollectContext is Collect that stops with ctx.Err() when ctx is done.
+func (l *LazyFrame) CollectContext(ctx context.Context) (*DataFrame, error) {
+	return optimizePlan(l.plan).collect(ctx)
+}
+
+// Explain returns the optimized plan as indented text, one step per line
+// with the inputs of a step below it.
+func (l *LazyFrame) Explain() string {
+	w := &strings.Builder{}
+	optimizePlan(l.plan).explain(w, 0)
+	return w.String()
+}
+
+// subset reports whether every name is in set.
+func subset(names, set []string) bool {
+	for _, n := range names {
+		if !slices.Contains(set, n) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepColumns returns the columns that are in required, in the order of
+// columns. A nil required keeps all columns. At least one column is kept so
+// that the number of rows is not lost.
+func keepColumns(columns, required []string) []string {
+	if required == nil {
+		return nil
+	}
+	kept := make([]string, 0)
+	for _, c := range columns {
+		if slices.Contains(required, c) && !slices.Contains(kept, c) {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 && len(columns) > 0 {
+		kept = append(kept, columns[0])
+	}
+	return kept
+}
+
+func writeStep(w *strings.Builder, depth int, format string, args ...any) {
+	w.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(w, format, args...)
+	w.WriteString("\n")
+}
+
+// frameNode reads an existing DataFrame.
+type frameNode struct {
+	df     *DataFrame
+	fields []string
+}
+
+func (n *frameNode) columns() []string {
+	if n.fields != nil {
+		return n.fields
+	}
+	return n.df.GetFieldNames()
+}
+
+func (n *frameNode) collect(ctx context.Context) (*DataFrame, error) {
+	// Steps like Transform modify their input, work on a copy that shares
+	// the column data and keeps the indexes of the remaining columns.
+	df, err := n.df.project(n.columns()...)
+	if err != nil {
+		return nil, err
+	}
+	df.indexes = maps.Clone(n.df.indexes)
+	maps.DeleteFunc(df.indexes, func(name string, _ *columnIndex) bool {
+		return df.GetFieldNumber(name) < 0
+	})
+	return df, nil
+}
+
+func (n *frameNode) explain(w *strings.Builder, depth int) {
+	writeStep(w, depth, "Frame [%s] (%d rows)", strings.Join(n.columns(), ", "), n.df.GetNumberOfRows())
+}
+
+// csvScanNode reads a CSV file, keeping only the rows that pass the
+// filters and the columns in fields.
+type csvScanNode struct {
+	r       *csv.Reader
+	header  []string
+	err     error
+	fields  []string
+	filters []string
+}
+
+func (n *csvScanNode) columns() []string {
+	if n.fields != nil {
+		return n.fields
+	}
+	return n.header
+}
+
+// compileFilters compiles the pushed down conditions against an
+// environment with the given column names.
+func compileFilters(filters []string, names []string, example func(i int) any) ([]*vm.Program, error) {
+	env := map[string]any{"functions": map[string]interface{}{}}
+	for i, name := range names {
+		env[name] = example(i)
+	}
+	programs := make([]*vm.Program, len(filters))
+	for i, f := range filters {
+		program, err := compileExpression(f, env)
+		if err != nil {
+			return nil, err
+		}
+		programs[i] = program
+	}
+	return programs, nil
+}
+
+// passFilters evaluates the compiled filters for one row.
+func passFilters(programs []*vm.Program, env map[string]any) (bool, error) {
+	for _, program := range programs {
+		result, err := vm.Run(program, env)
+		if err != nil {
+			return false, err
+		}
+		pass, ok := result.(bool)
+		if !ok {
+			return false, errors.New("condition must return a boolean value")
+		}
+		if !pass {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (n *csvScanNode) collect(ctx context.Context) (*DataFrame, error) {
+	if n.err != nil {
+		return nil, n.err
+	}
+	programs, err := compileFilters(n.filters, n.header, func(int) any { return "" })
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]int, 0)
+	fields := make([]Field, 0)
+	for _, name := range n.columns() {
+		positions = append(positions, slices.Index(n.header, name))
+		fields = append(fields, Field{FieldName: name, FieldPosition: len(fields), FieldType: "string"})
+	}
+	data := make([]*Data, len(positions))
+	for i := range data {
+		data[i] = &Data{}
+	}
+	for row := 0; ; row++ {
+		if err := canceled(ctx, row); err != nil {
+			return nil, err
+		}
+		rec, err := n.r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(programs) > 0 {
+			env := map[string]any{"functions": map[string]interface{}{}}
+			for i, name := range n.header {
+				env[name] = rec[i]
+			}
+			pass, err := passFilters(programs, env)
+			if err != nil {
+				return nil, err
+			}
+			if !pass {
+				continue
+			}
+		}
+		for i, x := range positions {
+			*data[i] = append(*data[i], rec[x])
+		}
+	}
+	return NewDataFrameWithArgs(fields, data), nil
+}
+
+func (n *csvScanNode) explain(w *strings.Builder, depth int) {
+	writeStep(w, depth, "Scan CSV [%s]%s", strings.Join(n.columns(), ", "), explainFilters(n.filters))
+}
+
+func explainFilters(filters []string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	return " filter: " + strings.Join(filters, " AND ")
+}
+
+// parquetScanNode reads a Parquet file. The columns used by the filters are
+// read first, the other columns in fields are then read and kept only for
+// the rows that passed.
+type parquetScanNode struct {
+	r       *reader.ParquetReader
+	header  []string
+	fields  []string
+	filters []string
+}
+
+func (n *parquetScanNode) columns() []string {
+	if n.fields != nil {
+		return n.fields
+	}
+	return n.header
+}
+
+func (n *parquetScanNode) collect(ctx context.Context) (*DataFrame, error) {
+	allFields := parquetFields(n.r)
+	read := make(map[string]Data)
+	readColumn := func(name string) (Data, error) {
+		if column, found := read[name]; found {
+			return column, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		x := slices.Index(n.header, name)
+		column, err := readParquetColumn(n.r, &allFields[x])
+		if err != nil {
+			return nil, err
+		}
+		read[name] = column
+		return column, nil
+	}
+
+	// Columns used by the filters
+	filterColumns := make([]string, 0)
+	for _, f := range n.filters {
+		identifiers, _ := expressionIdentifiers(f)
+		filterColumns = append(filterColumns, keepColumns(n.header, identifiers)...)
+	}
+	for _, name := range filterColumns {
+		if _, err := readColumn(name); err != nil {
+			return nil, err
+		}
+	}
+	programs, err := compileFilters(n.filters, filterColumns, func(i int) any {
+		if column := read[filterColumns[i]]; len(column) > 0 {
+			return column[0]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]int, 0)
+	for i := 0; i < int(n.r.GetNumRows()); i++ {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		env := map[string]any{"functions": map[string]interface{}{}}
+		for _, name := range filterColumns {
+			env[name] = read[name][i]
+		}
+		pass, err := passFilters(programs, env)
+		if err != nil {
+			return nil, err
+		}
+		if pass {
+			rows = append(rows, i)
+		}
+	}
+
+	fields := make([]Field, 0)
+	data := make([]*Data, 0)
+	for _, name := range n.columns() {
+		column, err := readColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(n.filters) > 0 {
+			kept := make(Data, len(rows))
+			for i, r := range rows {
+				kept[i] = column[r]
+			}
+			column = kept
+		}
+		f := allFields[slices.Index(n.header, name)]
+		f.FieldPosition = len(fields)
+		fields = append(fields, f)
+		data = append(data, &column)
+	}
+	return NewDataFrameWithArgs(fields, data), nil
+}
+
+func (n *parquetScanNode) explain(w *strings.Builder, depth int) {
+	writeStep(w, depth, "Scan Parquet [%s]%s", strings.Join(n.columns(), ", "), explainFilters(n.filters))
+}
+
+type projectNode struct {
+	input  planNode
+	fields []string
+}
+
+func (n *projectNode) columns() []string {
+	return n.fields
+}
+
+func (n *projectNode) collect(ctx context.Context) (*DataFrame, error) {
+	df, err := n.input.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return df.ProjectContext(ctx, n.fields...)
+}
+
+func (n *projectNode) explain(w *strings.Builder, depth int) {
+	writeStep(w, depth, "Project [%s]", strings.Join(n.fields, ", "))
+	n.input.explain(w, depth+1)
+}
+
+type whereNode struct {
+	input planNode
+	cond  string
+}
+
+func (n *whereNode) columns() []string {
+	return n.input.columns()
+}
+
+func (n *whereNode) collect(ctx context.Context) (*DataFrame, error) {
+	df, err := n.input.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return df.WhereContext(ctx, n.cond)
+}
+
+func (n *whereNode) explain(w *strings.Builder, depth int) {
+	writeStep(w, depth, "Where %s", n.cond)
+	n.input.explain(w, depth+1)
+}
+
+type transformNode struct {
+	input     planNode
+	statement string
+}
+
+// target returns the column the statement writes, the last identifier as
+// in DataFrame.Transform.
+func (n *transformNode) target() string {
+	identifiers, err := expressionIdentifiers(n.statement)
+	if err != nil || len(identifiers) == 0 {
+		return ""
+	}
+	return identifiers[len(identifiers)-1]
+}
+
+func (n *transformNode) columns() []string {
+	columns := n.input.columns()
+	if target := n.target(); target != "" && !slices.Contains(columns, target) {
+		return append(slices.Clone(columns), target)
+	}
+	return columns
+}
+
+func (n *transformNode) collect(ctx context.Context) (*DataFrame, error) {
+	df, err := n.input.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := df.TransformContext(ctx, n.statement); err != nil {
+		return nil, err
+	}
+	return df, nil
+}
+
+func (n *transformNode) explain(w *strings.Builder, depth int) {
+	writeStep(w, depth, "Transform %s", n.statement)
+	n.input.explain(w, depth+1)
+}
+
+type joinNode struct {
+	left  planNode
+	right planNode
+	keys  []string
+}
+
+func (n *joinNode) columns() []string {
+	return append(slices.Clone(n.left.columns()), n.right.columns()...)
+}
+
+func (n *joinNode) collect(ctx context.Context) (*DataFrame, error) {
+	left, err := n.left.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return left.JoinContext(ctx, right, n.keys)
+}
+
+func (n *joinNode) explain(w *strings.Builder, depth int) {
+	writeStep(w, depth, "Join on [%s]", strings.Join(n.keys, ", "))
+	n.left.explain(w, depth+1)
+	n.right.explain(w, depth+1)
+}
+
+type groupByNode struct {
+	input planNode
+	keys  []string
+	aggs  []Aggregation
+}
+
+func (n *groupByNode) columns() []string {
+	columns := slices.Clone(n.keys)
+	for _, agg := range n.aggs {
+		columns = append(columns, agg.OutputName())
+	}
+	return columns
+}
+
+func (n *groupByNode) collect(ctx context.Context) (*DataFrame, error) {
+	df, err := n.input.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return df.GroupByContext(ctx, n.keys, n.aggs...)
+}
+
+func (n *groupByNode) explain(w *strings.Builder, depth int) {
+	aggs := make([]string, len(n.aggs))
+	for i, agg := range n.aggs {
+		aggs[i] = fmt.Sprintf("%s(%s) as %s", agg.Function, agg.Column, agg.OutputName())
+	}
+	writeStep(w, depth, "GroupBy [%s] aggregate [%s]", strings.Join(n.keys, ", "), strings.Join(aggs, ", "))
+	n.input.explain(w, depth+1)
+}
+
+// optimizePlan pushes Where conditions as close to the readers as possible
+// and then prunes the columns every step reads to those that are used.
+func optimizePlan(n planNode) planNode {
+	return pruneColumns(pushDownPredicates(n), nil)
+}
+
+// splitConjunction splits "a && b && c" into its conditions.
+func splitConjunction(cond string) []string {
+	tree, err := parser.Parse(cond)
+	if err != nil {
+		return []string{cond}
+	}
+	conds := make([]string, 0)
+	var split func(node ast.Node)
+	split = func(node ast.Node) {
+		if b, ok := node.(*ast.BinaryNode); ok && (b.Operator == "&&" || b.Operator == "and") {
+			split(b.Left)
+			split(b.Right)
+			return
+		}
+		conds = append(conds, node.String())
+	}
+	split(tree.Node)
+	return conds
+}
+
+func pushDownPredicates(n planNode) planNode {
+	switch t := n.(type) {
+	case *whereNode:
+		input := pushDownPredicates(t.input)
+		for _, cond := range splitConjunction(t.cond) {
+			input = pushPredicate(cond, input)
+		}
+		return input
+	case *projectNode:
+		return &projectNode{input: pushDownPredicates(t.input), fields: t.fields}
+	case *transformNode:
+		return &transformNode{input: pushDownPredicates(t.input), statement: t.statement}
+	case *joinNode:
+		return &joinNode{left: pushDownPredicates(t.left), right: pushDownPredicates(t.right), keys: t.keys}
+	case *groupByNode:
+		return &groupByNode{input: pushDownPredicates(t.input), keys: t.keys, aggs: t.aggs}
+	}
+	return n
+}
+
+// pushPredicate places a Where condition above n or, when that gives the
+// same result, inside it.
+func pushPredicate(cond string, n planNode) planNode {
+	identifiers, err := expressionIdentifiers(cond)
+	if err != nil {
+		return &whereNode{input: n, cond: cond}
+	}
+	switch t := n.(type) {
+	case *csvScanNode:
+		if t.err == nil && subset(identifiers, t.header) {
+			scan := *t
+			scan.filters = append(slices.Clone(t.filters), cond)
+			return &scan
+		}
+	case *parquetScanNode:
+		if subset(identifiers, t.header) {
+			scan := *t
+			scan.filters = append(slices.Clone(t.filters), cond)
+			return &scan
+		}
+	case *whereNode:
+		return &whereNode{input: pushPredicate(cond, t.input), cond: t.cond}
+	case *projectNode:
+		if subset(identifiers, t.fields) {
+			return &projectNode{input: pushPredicate(cond, t.input), fields: t.fields}
+		}
+	case *transformNode:
+		if RowWise(t.statement) && !slices.Contains(identifiers, t.target()) {
+			return &transformNode{input: pushPredicate(cond, t.input), statement: t.statement}
+		}
+	case *joinNode:
+		left, right := t.left.columns(), t.right.columns()
+		// Where sees the right column when both sides have the same name
+		if subset(identifiers, right) {
+			return &joinNode{left: t.left, right: pushPredicate(cond, t.right), keys: t.keys}
+		}
+		if subset(identifiers, left) && !slices.ContainsFunc(identifiers, func(s string) bool { return slices.Contains(right, s) }) {
+			return &joinNode{left: pushPredicate(cond, t.left), right: t.right, keys: t.keys}
+		}
+	case *groupByNode:
+		if subset(identifiers, t.keys) {
+			return &groupByNode{input: pushPredicate(cond, t.input), keys: t.keys, aggs: t.aggs}
+		}
+	}
+	return &whereNode{input: n, cond: cond}
+}
+
+// pruneColumns limits the columns n produces to required, nil meaning all
+// of them, and its inputs to the columns it uses.
+func pruneColumns(n planNode, required []string) planNode {
+	with := func(names []string, expression string) []string {
+		if names == nil {
+			return nil
+		}
+		identifiers, _ := expressionIdentifiers(expression)
+		return append(slices.Clone(names), identifiers...)
+	}
+	switch t := n.(type) {
+	case *frameNode:
+		return &frameNode{df: t.df, fields: keepColumns(t.df.GetFieldNames(), required)}
+	case *csvScanNode:
+		scan := *t
+		scan.fields = keepColumns(t.header, required)
+		return &scan
+	case *parquetScanNode:
+		scan := *t
+		scan.fields = keepColumns(t.header, required)
+		return &scan
+	case *projectNode:
+		return &projectNode{input: pruneColumns(t.input, t.fields), fields: t.fields}
+	case *whereNode:
+		return &whereNode{input: pruneColumns(t.input, with(required, t.cond)), cond: t.cond}
+	case *transformNode:
+		return &transformNode{input: pruneColumns(t.input, with(required, t.statement)), statement: t.statement}
+	case *joinNode:
+		var left, right []string
+		if required != nil {
+			left = append(keepColumns(t.left.columns(), append(slices.Clone(required), t.keys...)), t.keys...)
+			right = append(keepColumns(t.right.columns(), append(slices.Clone(required), t.keys...)), t.keys...)
+		}
+		return &joinNode{left: pruneColumns(t.left, left), right: pruneColumns(t.right, right), keys: t.keys}
+	case *groupByNode:
+		used := slices.Clone(t.keys)
+		for _, agg := range t.aggs {
+			used = append(used, agg.Column)
+		}
+		return &groupByNode{input: pruneColumns(t.input, used), keys: t.keys, aggs: t.aggs}
+	}
+	return n
+}
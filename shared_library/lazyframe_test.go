@@ -0,0 +1,113 @@
+package sharedlibrary
+
+import (
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const taxiCSV = `Vendor_id,Fare_amount,Mta_tax,zone
+1,12.50,0.50,a
+2,3.25,0.50,b
+1,7.00,0.00,b
+2,20.00,0.50,a
+1,1.10,0.50,c
+`
+
+func TestLazyScanCSV(t *testing.T) {
+	plan := ScanCSV(csv.NewReader(strings.NewReader(taxiCSV))).
+		Transform("map(Fare_amount, float(#))").
+		Where("Vendor_id == '1' && Fare_amount > 5").
+		Project("zone", "Fare_amount")
+
+	want := "Project [zone, Fare_amount]\n" +
+		"  Where Fare_amount > 5\n" +
+		"    Transform map(Fare_amount, float(#))\n" +
+		"      Scan CSV [Fare_amount, zone] filter: Vendor_id == \"1\"\n"
+	if got := plan.Explain(); got != want {
+		t.Errorf("Explain() =\n%s\nwant\n%s", got, want)
+	}
+	df, err := plan.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, df), []string{"zone,Fare_amount", "a,12.5", "b,7"}; !slices.Equal(got, want) {
+		t.Errorf("Collect() = %v, want %v", got, want)
+	}
+}
+
+func TestLazyMatchesEager(t *testing.T) {
+	trips := newTestFrame(t, "Vendor_id:int,Fare_amount:decimal(6,2)", []string{"Vendor_id", "Fare_amount", "zone"},
+		[]string{"1", "12.50", "a"}, []string{"2", "3.25", "b"}, []string{"1", "7.00", "b"}, []string{"2", "20.00", "a"}, []string{"3", "1.10", "c"})
+	vendors := newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "vendor"},
+		[]string{"1", "Creative"}, []string{"2", "VeriFone"})
+	aggs := []Aggregation{{Column: "Fare_amount", Function: "sum"}, {Column: "*", Function: "count", Alias: "trips"}}
+
+	plan := trips.Lazy().
+		Join(vendors.Lazy(), []string{"Vendor_id"}).
+		Where("vendor == 'Creative' || Fare_amount > 10").
+		GroupBy([]string{"vendor"}, aggs...).
+		Where("vendor != 'nobody'")
+	got, err := plan.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joined, err := trips.Join(vendors, []string{"Vendor_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := joined.Where("vendor == 'Creative' || Fare_amount > 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := filtered.GroupBy([]string{"vendor"}, aggs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rows(t, got), rows(t, want)) {
+		t.Errorf("Collect() = %v, want %v", rows(t, got), rows(t, want))
+	}
+
+	explain := plan.Explain()
+	for _, step := range []string{
+		"GroupBy [vendor] aggregate [sum(Fare_amount) as sum_Fare_amount, count(*) as trips]",
+		"  Where vendor == \"Creative\" || Fare_amount > 10",
+		"      Frame [Vendor_id, Fare_amount] (5 rows)",
+		"      Frame [Vendor_id, vendor] (2 rows)",
+	} {
+		if !strings.Contains(explain, step+"\n") {
+			t.Errorf("Explain() has no line %q:\n%s", step, explain)
+		}
+	}
+	// the condition on the key of the groups runs before the join
+	if !strings.Contains(explain, "Where vendor != \"nobody\"\n        Frame [Vendor_id, vendor]") {
+		t.Errorf("vendor != nobody is not pushed down to the vendors:\n%s", explain)
+	}
+	if got := trips.GetFieldNames(); len(got) != 3 {
+		t.Errorf("Collect changed its input to %v", got)
+	}
+}
+
+func TestLazyPredicateAboveTransform(t *testing.T) {
+	d := newTestFrame(t, "Fare_amount:float", []string{"Fare_amount", "zone"}, []string{"1", "a"}, []string{"30", "b"})
+	// the condition reads the column the transform writes, it stays above it
+	plan := d.Lazy().Transform("map(Fare_amount, # * 10)").Where("Fare_amount > 20")
+	want := "Where Fare_amount > 20\n  Transform map(Fare_amount, # * 10)\n    Frame [Fare_amount, zone] (2 rows)\n"
+	if got := plan.Explain(); got != want {
+		t.Errorf("Explain() =\n%s\nwant\n%s", got, want)
+	}
+	df, err := plan.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, df, "zone"); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("zones = %v, want [b]", got)
+	}
+	// a transform over the whole column is not row-wise
+	plan = d.Lazy().Transform("reduce(Fare_amount, #acc + #, 0)").Where("zone == 'b'")
+	if got := plan.Explain(); !strings.HasPrefix(got, "Where zone == \"b\"\n") {
+		t.Errorf("a condition was pushed below a column transform:\n%s", got)
+	}
+}
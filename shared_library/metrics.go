@@ -0,0 +1,210 @@
+package sharedlibrary
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// OperatorMetrics is the JSON record an operator run with -metrics writes,
+// one line per run. The records of the operators of a pipeline share a
+// trace id, Timeline puts them back in order.
+type OperatorMetrics struct {
+	TraceID  string `json:"trace_id"`
+	Operator string `json:"operator"`
+	// Step is the name of the step of a pipeline run in-process.
+	Step         string    `json:"step,omitempty"`
+	PID          int       `json:"pid"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	FramesIn     int       `json:"frames_in"`
+	FramesOut    int       `json:"frames_out"`
+	RowsIn       int       `json:"rows_in"`
+	RowsOut      int       `json:"rows_out"`
+	ColumnsIn    int       `json:"columns_in"`
+	ColumnsOut   int       `json:"columns_out"`
+	BytesRead    int64     `json:"bytes_read"`
+	BytesWritten int64     `json:"bytes_written"`
+	DecodeMS     float64   `json:"decode_ms"`
+	ComputeMS    float64   `json:"compute_ms"`
+	EncodeMS     float64   `json:"encode_ms"`
+	PeakMemory   int64     `json:"peak_memory_bytes"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// NewTraceID returns a random trace id of 32 hex digits.
+func NewTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// milliseconds converts a duration for the JSON record.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// runMetrics collects the metrics of an operator run. Decode and encode
+// time are measured around reading and writing frames, waiting on the pipes
+// included, the rest of the run is compute time.
+type runMetrics struct {
+	record         OperatorMetrics
+	decode, encode time.Duration
+	read, written  *countingIO
+}
+
+func newRunMetrics(operator string) *runMetrics {
+	return &runMetrics{record: OperatorMetrics{Operator: operator, PID: os.Getpid(), Start: time.Now()}}
+}
+
+func (m *runMetrics) decoded(d *DataFrame, took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.decode += took
+	m.record.FramesIn++
+	m.record.RowsIn += d.GetNumberOfRows()
+	m.record.ColumnsIn = d.GetNumberOfColumns()
+}
+
+func (m *runMetrics) encoded(d *DataFrame, took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.encode += took
+	m.record.FramesOut++
+	m.record.RowsOut += d.GetNumberOfRows()
+	m.record.ColumnsOut = d.GetNumberOfColumns()
+}
+
+// finish completes the record at the end of the run.
+func (m *runMetrics) finish(traceID string, err error) OperatorMetrics {
+	r := m.record
+	r.TraceID = traceID
+	r.End = time.Now()
+	if m.read != nil {
+		r.BytesRead = m.read.n
+	}
+	if m.written != nil {
+		r.BytesWritten = m.written.n
+	}
+	r.DecodeMS = milliseconds(m.decode)
+	r.EncodeMS = milliseconds(m.encode)
+	r.ComputeMS = milliseconds(max(r.End.Sub(r.Start)-m.decode-m.encode, 0))
+	r.PeakMemory = peakMemory()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// countingIO counts the bytes read from or written to the reader or
+// writer it wraps.
+type countingIO struct {
+	r io.Reader
+	w io.Writer
+	n int64
+}
+
+func (c *countingIO) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingIO) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteMetrics appends records as JSON lines to a destination: "-" or
+// "stderr" for stderr, otherwise a file that the operators of a pipeline
+// can share. Every record is a single write.
+func WriteMetrics(dest string, records ...OperatorMetrics) error {
+	var w io.Writer = os.Stderr
+	if dest != "-" && dest != "stderr" {
+		file, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadMetrics reads the JSON lines written by WriteMetrics, lines that are
+// no metrics record, like other messages on stderr, are skipped.
+func ReadMetrics(r io.Reader) ([]OperatorMetrics, error) {
+	records := make([]OperatorMetrics, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var m OperatorMetrics
+		if err := json.Unmarshal([]byte(line), &m); err != nil || m.Operator == "" {
+			continue
+		}
+		records = append(records, m)
+	}
+	return records, scanner.Err()
+}
+
+// Timeline writes the records of every trace as a table ordered by start,
+// with the start and end of every stage relative to the start of its trace.
+func Timeline(w io.Writer, records []OperatorMetrics) error {
+	traces := make(map[string][]OperatorMetrics)
+	ids := make([]string, 0)
+	for _, r := range records {
+		if _, found := traces[r.TraceID]; !found {
+			ids = append(ids, r.TraceID)
+		}
+		traces[r.TraceID] = append(traces[r.TraceID], r)
+	}
+	for _, id := range ids {
+		stages := traces[id]
+		slices.SortStableFunc(stages, func(a, b OperatorMetrics) int { return a.Start.Compare(b.Start) })
+		origin := stages[0].Start
+		fmt.Fprintf(w, "trace %s\n", id)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "stage\tpid\tstart\tend\trows in\trows out\tbytes in\tbytes out\tdecode\tcompute\tencode\tpeak memory\t")
+		for _, s := range stages {
+			name := s.Operator
+			if s.Step != "" {
+				name = s.Step + " (" + s.Operator + ")"
+			}
+			if s.Error != "" {
+				name += " failed"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%d\t%d\t%s\t%s\t%.1fms\t%.1fms\t%.1fms\t%s\t\n", name, s.PID,
+				s.Start.Sub(origin).Round(time.Millisecond), s.End.Sub(origin).Round(time.Millisecond),
+				s.RowsIn, s.RowsOut, formatBytes(s.BytesRead), formatBytes(s.BytesWritten),
+				s.DecodeMS, s.ComputeMS, s.EncodeMS, formatBytes(s.PeakMemory))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package sharedlibrary
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOperatorMetrics(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.gob")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := NewFrameWriter(file)
+	for _, names := range [][]string{{"anna", "bob"}, {"carl"}} {
+		records := make([][]string, len(names))
+		for i, name := range names {
+			records[i] = []string{name, "1"}
+		}
+		if err := frames.Write(newTestFrame(t, "", []string{"name", "n"}, records...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := errors.Join(frames.Close(), file.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first stage is given the trace id, the second takes it from its input
+	metrics := filepath.Join(dir, "metrics.jsonl")
+	out, err := runWithStdout(t, &upperOperator{}, "-input", input, "-col", "name", "-metrics", metrics, "-trace-id", "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage := filepath.Join(dir, "stage.gob")
+	if err := os.WriteFile(stage, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runWithStdout(t, &upperOperator{}, "-input", stage, "-format", "csv", "-col", "name", "-metrics", metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = os.Open(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := ReadMetrics(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d metrics records, want 2: %+v", len(records), records)
+	}
+	for i, r := range records {
+		if r.TraceID != "run-1" || r.Operator != "upper" || r.PID != os.Getpid() {
+			t.Errorf("record %d: trace %q, operator %q, pid %d", i, r.TraceID, r.Operator, r.PID)
+		}
+		if r.FramesIn != 2 || r.FramesOut != 2 || r.RowsIn != 3 || r.RowsOut != 3 || r.ColumnsIn != 2 || r.ColumnsOut != 2 {
+			t.Errorf("record %d: frames %d/%d, rows %d/%d, columns %d/%d, want 2/2, 3/3, 2/2",
+				i, r.FramesIn, r.FramesOut, r.RowsIn, r.RowsOut, r.ColumnsIn, r.ColumnsOut)
+		}
+		if r.BytesRead <= 0 || r.BytesWritten <= 0 || r.PeakMemory <= 0 || r.End.Before(r.Start) || r.Error != "" {
+			t.Errorf("record %d: %+v", i, r)
+		}
+	}
+	if got := records[0].BytesWritten; got != int64(len(out)) {
+		t.Errorf("bytes written = %d, want the %d bytes of the output", got, len(out))
+	}
+	if records[1].BytesRead != records[0].BytesWritten {
+		t.Errorf("second stage read %d bytes, first wrote %d", records[1].BytesRead, records[0].BytesWritten)
+	}
+
+	// a failed run still writes its record
+	if _, err := runWithStdout(t, &upperOperator{}, "-input", input, "-metrics", metrics); err == nil {
+		t.Fatal("upper without -col succeeded")
+	}
+	written, err := os.ReadFile(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ = ReadMetrics(bytes.NewReader(written))
+	if len(records) != 3 || records[2].Error != "upper needs -col" {
+		t.Errorf("failed run recorded %+v", records[len(records)-1])
+	}
+}
+
+func TestReadMetrics(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("2024/01/02 reading input\n")
+	b.WriteString(`{"trace_id":"t","operator":"where","rows_in":3}` + "\n")
+	b.WriteString("{not json\n")
+	b.WriteString(`{"something":"else"}` + "\n")
+	records, err := ReadMetrics(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Operator != "where" || records[0].RowsIn != 3 {
+		t.Errorf("ReadMetrics = %+v, want the where record only", records)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []OperatorMetrics{
+		{TraceID: "t1", Operator: "where", PID: 2, Start: start.Add(10 * time.Millisecond), End: start.Add(40 * time.Millisecond), RowsIn: 5, RowsOut: 2},
+		{TraceID: "t2", Operator: "dump", PID: 9, Start: start, End: start.Add(time.Second)},
+		{TraceID: "t1", Operator: "importer", PID: 1, Start: start, End: start.Add(30 * time.Millisecond), RowsOut: 5, BytesWritten: 2048},
+		{TraceID: "t1", Operator: "join", Step: "vendors", PID: 1, Start: start.Add(20 * time.Millisecond), End: start.Add(50 * time.Millisecond), Error: "boom"},
+	}
+	var b strings.Builder
+	if err := Timeline(&b, records); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("Timeline =\n%s", b.String())
+	}
+	if lines[0] != "trace t1" || lines[5] != "trace t2" {
+		t.Errorf("traces not in order of appearance:\n%s", b.String())
+	}
+	// the stages of a trace are ordered by start, relative to the first
+	for i, want := range []string{"importer  1  0s  30ms  0  5", "where  2  10ms  40ms  5  2", "vendors  (join)  failed  1  20ms  50ms"} {
+		if got := strings.Join(strings.Fields(lines[2+i]), "  "); !strings.HasPrefix(got, want) {
+			t.Errorf("stage %d = %q, want prefix %q", i, got, want)
+		}
+	}
+	if !strings.Contains(lines[2], "2.0KiB") {
+		t.Errorf("importer bytes out not formatted: %q", lines[2])
+	}
+}
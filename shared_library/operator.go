@@ -0,0 +1,618 @@
+package sharedlibrary
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operator is one command of the operators binary. The shared flags, input
+// and output are handled by RunOperator, an operator only declares its own
+// flags and turns its input into a DataFrame.
+type Operator interface {
+	// Name is the name of the subcommand and of the binary it runs as.
+	Name() string
+	// Description is a one line summary shown in the usage.
+	Description() string
+	// SetFlags declares the flags of the operator.
+	SetFlags(fs *flag.FlagSet)
+	// Run returns the DataFrame to write to stdout, or nil to write nothing.
+	Run(env *OperatorEnv) (*DataFrame, error)
+}
+
+// Unbuffered is implemented by operators that do more than compute their
+// output, like dump, they always run and are never taken from a checkpoint.
+type Unbuffered interface {
+	Unbuffered()
+}
+
+// Streamer is implemented by operators that can process a stream of frames
+// as it arrives, like the row-wise where, project and transform, or that
+// produce one, like importer -follow. RunStream calls emit for every frame
+// of the output. Operators that are no Streamer read their whole input, a
+// stream up to its end, before they run.
+type Streamer interface {
+	RunStream(env *OperatorEnv, emit func(*DataFrame) error) error
+}
+
+// Committer is implemented by operators with an effect that must only last
+// once their output is written, like the state of an incremental import.
+// RunOperator calls Commit after the whole output was written.
+type Committer interface {
+	Commit() error
+}
+
+// OperatorOptions are the flags shared by all operators.
+type OperatorOptions struct {
+	// Input is the file to read instead of stdin.
+	Input string
+	// OutputFormat is gob, csv or table.
+	OutputFormat string
+	// Debug dumps the output to stderr.
+	Debug bool
+	// MemoryLimit is a size such as 512MiB or 2G, empty for no limit.
+	MemoryLimit string
+	// MemoryBudget is the size of the hash tables and sort runs of Join,
+	// Sort, Distinct and GroupBy before they spill, empty for no budget.
+	MemoryBudget string
+	// Checkpoint is the directory of checkpoints, empty for none.
+	Checkpoint string
+	// Metrics is where to write the OperatorMetrics of the run, "-" for
+	// stderr, empty for nowhere.
+	Metrics string
+	// TraceID is the trace of the run, empty to take it from the input.
+	TraceID string
+	// Trace is the exporter of the OpenTelemetry spans of the run, stdout or
+	// otlp-file:path, empty for none.
+	Trace string
+	// Compression is the compression of the gob output, none, zstd, snappy
+	// or lz4, empty for none.
+	Compression string
+}
+
+// OperatorEnv is what an operator runs with.
+type OperatorEnv struct {
+	OperatorOptions
+	// Args are the arguments left after the flags.
+	Args  []string
+	Stdin io.Reader
+	// Stdout is the output, for operators that print text instead of
+	// returning a DataFrame.
+	Stdout  io.Writer
+	Stderr  io.Writer
+	ctx     context.Context
+	metrics *runMetrics
+	// span is the span of the operator, started once the trace it continues
+	// is known, see startSpan.
+	span    trace.Span
+	started time.Time
+}
+
+// Context returns the context of the run, done when the operator is
+// interrupted. Long operations take it to stop early and to trace their
+// spans in the span of the operator.
+func (e *OperatorEnv) Context() context.Context {
+	e.startSpan("")
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// startSpan starts the span of a traced operator unless it was started. It
+// continues the trace of traceparent, the trace of the input, or else the
+// trace in TRACEPARENT. The span starts with the run, but only once the
+// first frame of the input or the operator needs it, to know its parent.
+func (e *OperatorEnv) startSpan(traceparent string) {
+	if e.Trace == "" || e.metrics == nil || e.span != nil {
+		return
+	}
+	if traceparent == "" {
+		traceparent = os.Getenv(TraceParentEnv)
+	}
+	ctx := e.ctx
+	if traceparent != "" {
+		ctx = ContextWithTraceParent(ctx, traceparent)
+	}
+	e.ctx, e.span = tracer.Start(ctx, e.metrics.record.Operator, trace.WithTimestamp(e.started))
+	if e.TraceID == "" {
+		e.TraceID = e.span.SpanContext().TraceID().String()
+	}
+}
+
+// endSpan ends the span of the operator with the metrics of the run.
+func (e *OperatorEnv) endSpan(m OperatorMetrics, err error) {
+	e.startSpan("")
+	if e.span == nil {
+		return
+	}
+	e.span.SetAttributes(
+		attribute.Int("pid", m.PID),
+		attribute.Int("frames.in", m.FramesIn),
+		attribute.Int("frames.out", m.FramesOut),
+		attribute.Int("rows.in", m.RowsIn),
+		attribute.Int("rows.out", m.RowsOut),
+		attribute.Int64("bytes.read", m.BytesRead),
+		attribute.Int64("bytes.written", m.BytesWritten),
+		attribute.Float64("decode.ms", m.DecodeMS),
+		attribute.Float64("encode.ms", m.EncodeMS),
+		attribute.Int64("memory.peak", m.PeakMemory),
+	)
+	if err != nil {
+		e.span.RecordError(err)
+		e.span.SetStatus(codes.Error, err.Error())
+	}
+	e.span.End(trace.WithTimestamp(m.End))
+}
+
+// ReadFrame decodes the frame stream of the input, all its frames
+// concatenated.
+func (e *OperatorEnv) ReadFrame() (*DataFrame, error) {
+	start := time.Now()
+	frames := NewFrameReader(e.Stdin)
+	defer frames.Close()
+	df, err := frames.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	h, _ := frames.Header()
+	e.received(df, h, time.Since(start))
+	return df, nil
+}
+
+// received counts a frame of the input and takes the trace the stream
+// carries when the run has none.
+func (e *OperatorEnv) received(df *DataFrame, h WireHeader, took time.Duration) {
+	e.metrics.decoded(df, took)
+	if e.TraceID == "" {
+		e.TraceID = h.TraceID
+	}
+	e.startSpan(h.TraceParent)
+}
+
+// EachFrame calls fn for every frame of the input as it arrives. An empty
+// input is an error, like it is for ReadFrame.
+func (e *OperatorEnv) EachFrame(fn func(*DataFrame) error) error {
+	frames := NewFrameReader(e.Stdin)
+	defer frames.Close()
+	// an input this build can not read fails before the first frame arrives
+	h, err := frames.Header()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("input: %w", err)
+	}
+	for n := 0; ; n++ {
+		if e.ctx != nil && e.ctx.Err() != nil {
+			return e.ctx.Err()
+		}
+		start := time.Now()
+		df, err := frames.Next()
+		if err == io.EOF && n > 0 {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("input: %w", err)
+		}
+		e.received(df, h, time.Since(start))
+		if err := fn(df); err != nil {
+			return err
+		}
+	}
+}
+
+// Output formats of the operators.
+const (
+	GobFormat   = "gob"
+	CSVFormat   = "csv"
+	TableFormat = "table"
+)
+
+// ParseByteSize parses a size like 1048576, 512K, 64MB or 2GiB. The units are
+// powers of 1024.
+func ParseByteSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	t = strings.TrimSuffix(strings.TrimSuffix(t, "B"), "I")
+	shift := 0
+	if t != "" {
+		if i := strings.IndexByte("KMGT", t[len(t)-1]); i >= 0 {
+			shift = 10 * (i + 1)
+			t = t[:len(t)-1]
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n << shift, nil
+}
+
+// setSharedFlags declares the shared flags on a flag set. -file is an alias
+// of -input for the operators that had it, unless the operator declares it.
+func setSharedFlags(fs *flag.FlagSet, o *OperatorOptions) {
+	fs.StringVar(&o.Input, "input", "", "file to read instead of stdin")
+	if fs.Lookup("file") == nil {
+		fs.StringVar(&o.Input, "file", "", "alias of -input")
+	}
+	fs.StringVar(&o.OutputFormat, "format", GobFormat, "output format: gob, csv or table")
+	if fs.Lookup("debug") == nil {
+		fs.BoolVar(&o.Debug, "debug", false, "Dump output to stderr")
+	}
+	fs.StringVar(&o.MemoryLimit, "memory-limit", os.Getenv("OPERATORS_MEMORY_LIMIT"), "soft memory limit such as 512MiB, defaults to $OPERATORS_MEMORY_LIMIT")
+	fs.StringVar(&o.MemoryBudget, "memory-budget", os.Getenv("OPERATORS_MEMORY_BUDGET"), "memory the hash tables and sort runs of joins, sorts, distinct and grouping may use before they spill their keys to temporary files, e.g. 256MiB; input and output frames stay in memory; defaults to $OPERATORS_MEMORY_BUDGET")
+	fs.StringVar(&o.Checkpoint, "checkpoint", os.Getenv("OPERATORS_CHECKPOINT"), "directory of checkpoints, a run with the same input and flags reuses the saved output; defaults to $OPERATORS_CHECKPOINT")
+	fs.StringVar(&o.Metrics, "metrics", os.Getenv("OPERATORS_METRICS"), "write JSON metrics of the run to a file, - for stderr; defaults to $OPERATORS_METRICS")
+	fs.StringVar(&o.TraceID, "trace-id", os.Getenv("OPERATORS_TRACE_ID"), "trace id passed on to the next operators with the output, taken from the input when empty; defaults to $OPERATORS_TRACE_ID")
+	fs.StringVar(&o.Trace, "trace", os.Getenv("OPERATORS_TRACE"), "export OpenTelemetry spans to stdout (written to stderr) or otlp-file:path, the trace continues the one of the input or $TRACEPARENT; defaults to $OPERATORS_TRACE")
+	fs.StringVar(&o.Compression, "compression", os.Getenv("OPERATORS_COMPRESSION"), "compress the gob output and checkpoints with none, zstd, snappy or lz4, read input is decompressed automatically; defaults to $OPERATORS_COMPRESSION")
+}
+
+// operatorCheckpointKey returns the checkpoint key of a run: the operator,
+// its own flags and arguments, the input and the content of the files the
+// flags and arguments name. The input is read into memory to hash it,
+// unless it is a terminal.
+func operatorCheckpointKey(op Operator, fs *flag.FlagSet, own []string, env *OperatorEnv) (string, error) {
+	args := make([]string, 0)
+	inputs := make([]string, 0)
+	hashFiles := func(value string) error {
+		matches, _ := filepath.Glob(value)
+		for _, m := range matches {
+			if fi, err := os.Stat(m); err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			h, err := HashFile(m)
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, m+"="+h)
+		}
+		return nil
+	}
+	for _, name := range own {
+		value := fs.Lookup(name).Value.String()
+		args = append(args, "-"+name+"="+value)
+		if err := hashFiles(value); err != nil {
+			return "", err
+		}
+	}
+	for _, a := range env.Args {
+		args = append(args, a)
+		if err := hashFiles(a); err != nil {
+			return "", err
+		}
+	}
+	if file, ok := env.Stdin.(*os.File); ok {
+		if fi, err := file.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			return CheckpointKey(op.Name(), args, inputs...), nil
+		}
+	}
+	data, err := io.ReadAll(env.Stdin)
+	if err != nil {
+		return "", err
+	}
+	env.Stdin = bytes.NewReader(data)
+	inputs = append(inputs, "input="+inputHash(data))
+	return CheckpointKey(op.Name(), args, inputs...), nil
+}
+
+// inputHash hashes the input of an operator. A frame stream is hashed by
+// the frames it holds, the trace and compression of the stream and the
+// times in the history of its frames do not change the output of a run.
+func inputHash(data []byte) string {
+	r := NewFrameReader(bytes.NewReader(data))
+	defer r.Close()
+	h, err := r.Header()
+	if err != nil || h.Version < 2 {
+		// not a frame stream, like the CSV of the importer
+		return HashBytes(data)
+	}
+	sum := sha256.New()
+	encoder := gob.NewEncoder(sum)
+	for {
+		df, err := r.Next()
+		if err == io.EOF {
+			return hex.EncodeToString(sum.Sum(nil))
+		}
+		if err != nil {
+			return HashBytes(data)
+		}
+		e := NewFrameEnvelope(df)
+		e.Metadata.History = untimed(e.Metadata.History)
+		// gob writes maps in any order, JSON sorts their keys
+		meta, err := json.Marshal([]any{e.Metadata, e.Indexes})
+		if err != nil {
+			return HashBytes(data)
+		}
+		e.Metadata, e.Indexes = Metadata{}, nil
+		if err := encoder.Encode(e); err != nil {
+			return HashBytes(data)
+		}
+		sum.Write(meta)
+	}
+}
+
+// runCheckpointed runs the operator, with a checkpoint directory it returns
+// the saved output of a run with the same key instead and saves new output.
+func runCheckpointed(op Operator, fs *flag.FlagSet, own []string, env *OperatorEnv) (*DataFrame, error) {
+	if _, unbuffered := op.(Unbuffered); env.Checkpoint == "" || unbuffered {
+		return op.Run(env)
+	}
+	checkpoints := Checkpoints{Dir: env.Checkpoint}
+	key, err := operatorCheckpointKey(op, fs, own, env)
+	if err != nil {
+		return nil, err
+	}
+	df, found, err := checkpoints.Load(key)
+	if err != nil || found {
+		if found && env.Debug {
+			fmt.Fprintf(env.Stderr, "%s: output from checkpoint %s\n", op.Name(), key)
+		}
+		return df, err
+	}
+	if df, err = op.Run(env); err != nil || df == nil {
+		return df, err
+	}
+	return df, checkpoints.Save(key, df)
+}
+
+// errInterrupted is returned by RunOperator for an operator stopped by
+// SIGINT or SIGTERM.
+var errInterrupted = errors.New("interrupted")
+
+// RunOperator parses the arguments of an operator, runs it on its input and
+// writes the result to stdout.
+//
+// SIGINT and SIGTERM cancel the context of the run, a second signal kills
+// the process. When the reader of stdout goes away, like head after its
+// lines, the operator stops and RunOperator returns nil.
+func RunOperator(op Operator, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+	// a write to a closed pipe fails with EPIPE instead of killing the process
+	signal.Ignore(syscall.SIGPIPE)
+
+	err := runOperator(ctx, op, args)
+	switch {
+	case errors.Is(err, syscall.EPIPE):
+		return nil
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		return errInterrupted
+	}
+	return err
+}
+
+func runOperator(ctx context.Context, op Operator, args []string) (err error) {
+	fs := flag.NewFlagSet(op.Name(), flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s: %s\n", op.Name(), op.Description())
+		fs.PrintDefaults()
+	}
+	env := &OperatorEnv{Stdin: os.Stdin, Stderr: os.Stderr, ctx: ctx}
+	op.SetFlags(fs)
+	own := make([]string, 0)
+	fs.VisitAll(func(f *flag.Flag) { own = append(own, f.Name) })
+	setSharedFlags(fs, &env.OperatorOptions)
+	fs.Parse(args)
+	env.Args = fs.Args()
+
+	switch env.OutputFormat {
+	case GobFormat, CSVFormat, TableFormat:
+	default:
+		return fmt.Errorf("unknown output format %q", env.OutputFormat)
+	}
+	if err := SetCompression(env.Compression); err != nil {
+		return err
+	}
+	if env.MemoryLimit != "" {
+		limit, err := ParseByteSize(env.MemoryLimit)
+		if err != nil {
+			return err
+		}
+		debug.SetMemoryLimit(limit)
+	}
+	if env.MemoryBudget != "" {
+		budget, err := ParseByteSize(env.MemoryBudget)
+		if err != nil {
+			return err
+		}
+		SetMemoryBudget(budget)
+		defer func() {
+			for _, r := range Spills() {
+				fmt.Fprintf(env.Stderr, "%s: %s\n", op.Name(), r)
+			}
+		}()
+	}
+	if env.Input != "" && env.Input != "-" {
+		file, err := os.Open(env.Input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		env.Stdin = file
+	}
+
+	var stdout io.Writer = os.Stdout
+	if env.Trace != "" {
+		shutdown, err := StartTracing(env.Trace, env.TraceID)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// the spans are exported even when the run was interrupted
+			err = errors.Join(err, shutdown(context.WithoutCancel(ctx)))
+		}()
+	}
+	if env.Metrics != "" || env.Trace != "" {
+		m := newRunMetrics(op.Name())
+		m.read = &countingIO{r: env.Stdin}
+		m.written = &countingIO{w: stdout}
+		env.metrics, env.Stdin, stdout, env.started = m, m.read, m.written, m.record.Start
+		defer func() {
+			env.startSpan("")
+			if env.TraceID == "" {
+				env.TraceID = NewTraceID()
+			}
+			record := m.finish(env.TraceID, err)
+			env.endSpan(record, err)
+			if env.Metrics != "" {
+				err = errors.Join(err, WriteMetrics(env.Metrics, record))
+			}
+		}()
+	}
+	env.Stdout = stdout
+	out := newFrameOutput(stdout, env.OutputFormat)
+	defer func() {
+		// ends a compressed stream, before the metrics count its bytes
+		err = errors.Join(err, out.frames.Close())
+		if committer, ok := op.(Committer); ok && err == nil {
+			err = committer.Commit()
+		}
+	}()
+	emit := func(df *DataFrame) error {
+		if env.Debug {
+			WriteTable(env.Stderr, df)
+		}
+		// the operators after this one report to the same trace
+		env.startSpan("")
+		if env.TraceID == "" && env.metrics != nil {
+			env.TraceID = NewTraceID()
+		}
+		out.frames.TraceID = env.TraceID
+		if env.span != nil {
+			out.frames.TraceParent = TraceParent(env.ctx)
+		}
+		start := time.Now()
+		err := out.write(df)
+		env.metrics.encoded(df, time.Since(start))
+		return err
+	}
+	// a checkpoint needs the whole input to compute its key
+	if streamer, ok := op.(Streamer); ok && env.Checkpoint == "" {
+		return streamer.RunStream(env, emit)
+	}
+	df, err := runCheckpointed(op, fs, own, env)
+	if err != nil {
+		return err
+	}
+	if df == nil {
+		return nil
+	}
+	return emit(df)
+}
+
+// frameOutput writes the frames of a stream in an output format. Gob frames
+// share one encoder and CSV has a single header row.
+type frameOutput struct {
+	w       io.Writer
+	format  string
+	frames  *FrameWriter
+	written int
+}
+
+func newFrameOutput(w io.Writer, format string) *frameOutput {
+	return &frameOutput{w: w, format: format, frames: NewFrameWriter(w)}
+}
+
+func (o *frameOutput) write(d *DataFrame) error {
+	o.written++
+	switch {
+	case o.format == GobFormat:
+		return o.frames.Write(d)
+	case o.format == CSVFormat && o.written > 1:
+		return writeCSV(o.w, d, false)
+	}
+	return WriteFrameFormat(o.w, d, o.format)
+}
+
+// WriteFrameFormat writes a DataFrame as gob, csv or table.
+func WriteFrameFormat(w io.Writer, d *DataFrame, format string) error {
+	switch format {
+	case GobFormat:
+		return WriteFrame(w, d)
+	case CSVFormat:
+		return WriteCSV(w, d)
+	case TableFormat:
+		return WriteTable(w, d)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// WriteCSV writes a DataFrame as CSV with a header row, nil values are
+// written as empty fields.
+func WriteCSV(w io.Writer, d *DataFrame) error {
+	return writeCSV(w, d, true)
+}
+
+func writeCSV(w io.Writer, d *DataFrame, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		if err := cw.Write(d.GetFieldNames()); err != nil {
+			return err
+		}
+	}
+	record := make([]string, d.GetNumberOfColumns())
+	for i := 0; i < d.GetNumberOfRows(); i++ {
+		for j := range record {
+			v, err := d.GetPositionValue(j, i)
+			if err != nil {
+				return err
+			}
+			record[j] = ""
+			if v != nil {
+				record[j] = fmt.Sprintf("%v", v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTable writes a DataFrame in the layout of PrintDataframe.
+func WriteTable(w io.Writer, d *DataFrame) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 0, '.', tabwriter.Debug)
+	fmt.Fprintln(tw, "------- DUMP START --------")
+	fmt.Fprint(tw, "\t")
+	for _, v := range d.GetFieldNames() {
+		fmt.Fprintf(tw, "%s\t", v)
+	}
+	fmt.Fprintln(tw)
+	for i := 0; i < d.GetNumberOfRows(); i++ {
+		fmt.Fprint(tw, "\t")
+		for j := range d.GetNumberOfColumns() {
+			v, err := d.GetPositionValue(j, i)
+			if err != nil {
+				return fmt.Errorf("row %d, column %d: %w", i, j, err)
+			}
+			fmt.Fprintf(tw, " %v\t", v)
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintln(tw, "------- DUMP END --------")
+	return tw.Flush()
+}
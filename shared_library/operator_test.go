@@ -0,0 +1,159 @@
+package sharedlibrary
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"1048576": 1 << 20,
+		"512K":    512 << 10,
+		"64MB":    64 << 20,
+		"2GiB":    2 << 30,
+		" 1t ":    1 << 40,
+		"0":       0,
+	} {
+		if got, err := ParseByteSize(in); err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "MB", "-1K", "1.5G", "1X", "9999999999T"} {
+		if got, err := ParseByteSize(in); err == nil {
+			t.Errorf("ParseByteSize(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+// upperOperator is a test operator that upper-cases a column of its input
+// frame by frame and counts its runs on the whole input and its commits.
+type upperOperator struct {
+	column  string
+	runs    int
+	commits int
+}
+
+func (*upperOperator) Name() string        { return "upper" }
+func (*upperOperator) Description() string { return "upper-case a column" }
+
+func (o *upperOperator) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.column, "col", "", "column to upper-case")
+}
+
+func (o *upperOperator) Run(env *OperatorEnv) (*DataFrame, error) {
+	o.runs++
+	df, err := env.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	return o.apply(df)
+}
+
+func (o *upperOperator) RunStream(env *OperatorEnv, emit func(*DataFrame) error) error {
+	return env.EachFrame(func(df *DataFrame) error {
+		out, err := o.apply(df)
+		if err != nil {
+			return err
+		}
+		return emit(out)
+	})
+}
+
+func (o *upperOperator) Commit() error {
+	o.commits++
+	return nil
+}
+
+func (o *upperOperator) apply(df *DataFrame) (*DataFrame, error) {
+	if o.column == "" {
+		return nil, errors.New("upper needs -col")
+	}
+	return df, df.Transform("map(" + o.column + ", upper(#))")
+}
+
+// runWithStdout runs an operator with args and returns what it wrote to
+// stdout.
+func runWithStdout(t *testing.T, op Operator, args ...string) (string, error) {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+	runErr := runOperator(context.Background(), op, args)
+	written, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(written), runErr
+}
+
+func TestRunOperator(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.gob")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := NewFrameWriter(file)
+	for _, name := range []string{"anna", "bob"} {
+		if err := frames.Write(newTestFrame(t, "", []string{"name"}, []string{name})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := errors.Join(frames.Close(), file.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a stream of two frames gives one CSV with a single header
+	op := &upperOperator{}
+	got, err := runWithStdout(t, op, "-input", input, "-format", "csv", "-col", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "name\nANNA\nBOB\n"; got != want {
+		t.Errorf("csv output = %q, want %q", got, want)
+	}
+	if op.commits != 1 {
+		t.Errorf("upper committed %d times, want once after its output", op.commits)
+	}
+
+	got, err = runWithStdout(t, &upperOperator{}, "-file", input, "-col", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, err := NewFrameReader(strings.NewReader(got)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, df), []string{"name", "ANNA", "BOB"}; !slices.Equal(got, want) {
+		t.Errorf("gob output = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-input", input, "-format", "xml", "-col", "name"}, `unknown output format "xml"`},
+		{[]string{"-input", input}, "upper needs -col"},
+		{[]string{"-input", filepath.Join(dir, "missing.gob"), "-col", "name"}, "no such file"},
+		{[]string{"-input", input, "-col", "name", "-memory-limit", "lots"}, `invalid size "lots"`},
+	} {
+		op := &upperOperator{}
+		if _, err := runWithStdout(t, op, tt.args...); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("upper %v: err = %v, want %q", tt.args, err, tt.want)
+		}
+		if op.commits != 0 {
+			t.Errorf("upper %v committed after it failed", tt.args)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package sharedlibrary
+
+import "runtime"
+
+// peakMemory returns the memory the Go runtime obtained from the system, the
+// closest to a peak where the resident set is not available.
+func peakMemory() int64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.Sys)
+}
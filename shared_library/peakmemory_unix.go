@@ -0,0 +1,21 @@
+//go:build unix
+
+package sharedlibrary
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// peakMemory returns the peak resident memory of the process in bytes.
+func peakMemory() int64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	// Linux reports kilobytes, macOS bytes
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
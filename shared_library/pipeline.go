@@ -0,0 +1,566 @@
+package sharedlibrary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Pipeline is a DAG of operator steps. Every step has a name and reads the
+// outputs of the steps named in its inputs, so a pipeline can branch and
+// join where a shell pipe can only run in a line.
+//
+//	steps:
+//	  - name: trips
+//	    op: importer
+//	    file: trips.csv
+//	  - name: long
+//	    op: where
+//	    input: trips
+//	    cond: "Trip_distance > 10"
+//	  - name: out
+//	    op: export
+//	    input: long
+//	    file: long.gob
+//
+// With a checkpoint directory the output of every step is saved there, and
+// a run loads the output of the steps whose arguments and inputs have not
+// changed instead of running them, see Checkpoints.
+type Pipeline struct {
+	Checkpoint string         `yaml:"checkpoint,omitempty"`
+	Steps      []PipelineStep `yaml:"steps"`
+}
+
+// PipelineStep is one operator of a Pipeline. Op selects the operator, the
+// other fields are its arguments and carry the names of the operator flags.
+//
+//   - importer: file, schema, normalize
+//   - project: input, cols
+//   - rename: input, cols as old:new pairs, normalize to rename all columns
+//     to snake_case first
+//   - where: input, cond
+//   - transform: input, statement
+//   - join: input, right, keys
+//   - union: inputs, byName
+//   - distinct: input, cols, counts
+//   - export: input, file ("-" or empty writes stdout)
+type PipelineStep struct {
+	Name      string   `yaml:"name"`
+	Op        string   `yaml:"op"`
+	Input     string   `yaml:"input,omitempty"`
+	Right     string   `yaml:"right,omitempty"`
+	Inputs    []string `yaml:"inputs,omitempty"`
+	File      string   `yaml:"file,omitempty"`
+	Schema    string   `yaml:"schema,omitempty"`
+	Normalize bool     `yaml:"normalize,omitempty"`
+	Cols      []string `yaml:"cols,omitempty"`
+	Cond      string   `yaml:"cond,omitempty"`
+	Statement string   `yaml:"statement,omitempty"`
+	Keys      []string `yaml:"keys,omitempty"`
+	ByName    bool     `yaml:"byName,omitempty"`
+	Counts    bool     `yaml:"counts,omitempty"`
+}
+
+// StepReport is what running a step did. Checkpoint is empty when the
+// pipeline has no checkpoint directory, otherwise it is "saved" when the step
+// ran, "loaded" when its output was taken from a checkpoint and "skipped"
+// when no step needed its output.
+type StepReport struct {
+	Step       string
+	Op         string
+	RowsIn     int
+	RowsOut    int
+	Start      time.Time
+	Duration   time.Duration
+	Checkpoint string
+}
+
+// Metrics returns the report as the metrics record of an operator, all of
+// its time is compute time.
+func (r StepReport) Metrics(traceID string) OperatorMetrics {
+	return OperatorMetrics{
+		TraceID:   traceID,
+		Operator:  r.Op,
+		Step:      r.Step,
+		PID:       os.Getpid(),
+		Start:     r.Start,
+		End:       r.Start.Add(r.Duration),
+		RowsIn:    r.RowsIn,
+		RowsOut:   r.RowsOut,
+		ComputeMS: milliseconds(r.Duration),
+	}
+}
+
+// inputs returns the names of the steps the step reads, the main input first.
+func (s PipelineStep) inputs() []string {
+	switch s.Op {
+	case "importer":
+		return nil
+	case "union":
+		return s.Inputs
+	case "join":
+		return []string{s.Input, s.Right}
+	}
+	return []string{s.Input}
+}
+
+// validate checks that the step has the arguments its operator needs.
+func (s PipelineStep) validate() error {
+	missing := func(arg string) error {
+		return fmt.Errorf("step %s: %s needs %s", s.Name, s.Op, arg)
+	}
+	switch s.Op {
+	case "importer":
+		if s.File == "" {
+			return missing("file")
+		}
+		return nil
+	case "project":
+		if len(s.Cols) == 0 {
+			return missing("cols")
+		}
+	case "rename":
+		if len(s.Cols) == 0 && !s.Normalize {
+			return missing("cols or normalize")
+		}
+		for _, c := range s.Cols {
+			if old, name, found := strings.Cut(c, ":"); !found || old == "" || name == "" {
+				return fmt.Errorf("step %s: rename %q is not old:new", s.Name, c)
+			}
+		}
+	case "where":
+		if s.Cond == "" {
+			return missing("cond")
+		}
+	case "transform":
+		if s.Statement == "" {
+			return missing("statement")
+		}
+	case "join":
+		if s.Right == "" {
+			return missing("right")
+		}
+		if len(s.Keys) == 0 {
+			return missing("keys")
+		}
+	case "union":
+		if len(s.Inputs) < 2 {
+			return missing("at least two inputs")
+		}
+		return nil
+	case "distinct":
+		if s.Counts && len(s.Cols) != 1 {
+			return fmt.Errorf("step %s: counts needs exactly one column in cols", s.Name)
+		}
+	case "export":
+	default:
+		return fmt.Errorf("step %s: unknown op %q", s.Name, s.Op)
+	}
+	if s.Input == "" {
+		return missing("input")
+	}
+	return nil
+}
+
+// order validates the pipeline and returns the positions of its steps in an
+// order where every step comes after its inputs. Steps keep the order they
+// are written in when they do not depend on each other.
+func (p *Pipeline) order() ([]int, error) {
+	if len(p.Steps) == 0 {
+		return nil, errors.New("pipeline has no steps")
+	}
+	positions := make(map[string]int, len(p.Steps))
+	for i, s := range p.Steps {
+		if s.Name == "" {
+			return nil, fmt.Errorf("step %d has no name", i+1)
+		}
+		if _, found := positions[s.Name]; found {
+			return nil, fmt.Errorf("step name %s is used more than once", s.Name)
+		}
+		positions[s.Name] = i
+	}
+	for _, s := range p.Steps {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+		for _, in := range s.inputs() {
+			if _, found := positions[in]; !found {
+				return nil, fmt.Errorf("step %s: input %s is not the name of a step", s.Name, in)
+			}
+			if p.Steps[positions[in]].Op == "export" {
+				return nil, fmt.Errorf("step %s: input %s is an export and has no output", s.Name, in)
+			}
+		}
+	}
+
+	done := make(map[string]bool, len(p.Steps))
+	order := make([]int, 0, len(p.Steps))
+	for len(order) < len(p.Steps) {
+		progress := false
+		for i, s := range p.Steps {
+			if done[s.Name] {
+				continue
+			}
+			ready := true
+			for _, in := range s.inputs() {
+				ready = ready && done[in]
+			}
+			if ready {
+				done[s.Name] = true
+				order = append(order, i)
+				progress = true
+			}
+		}
+		if !progress {
+			waiting := make([]string, 0)
+			for _, s := range p.Steps {
+				if !done[s.Name] {
+					waiting = append(waiting, s.Name)
+				}
+			}
+			return nil, fmt.Errorf("steps %s depend on each other", strings.Join(waiting, ", "))
+		}
+	}
+	return order, nil
+}
+
+// checkpointKeys returns the checkpoint key of every step but the exports,
+// whose output is a file. The key of an importer covers the content of its
+// file, the key of other steps covers the keys of their inputs, so a change
+// reaches every step after it.
+func (p *Pipeline) checkpointKeys(order []int) (map[string]string, error) {
+	keys := make(map[string]string, len(order))
+	for _, i := range order {
+		s := p.Steps[i]
+		if s.Op == "export" {
+			continue
+		}
+		inputs := make([]string, 0, len(s.inputs()))
+		for _, in := range s.inputs() {
+			inputs = append(inputs, keys[in])
+		}
+		if s.Op == "importer" {
+			hash, err := HashFile(s.File)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: %w", s.Name, err)
+			}
+			inputs = append(inputs, hash)
+		}
+		// the names of the step and its inputs do not change its output
+		args := s
+		args.Name, args.Input, args.Right, args.Inputs, args.File = "", "", "", nil, ""
+		keys[s.Name] = CheckpointKey(s.Op, []string{fmt.Sprintf("%#v", args)}, inputs...)
+	}
+	return keys, nil
+}
+
+// Run executes the steps of the pipeline in-process and returns a report
+// for every step in the order they ran.
+func (p *Pipeline) Run() ([]StepReport, error) {
+	return p.RunContext(context.Background())
+}
+
+// RunContext is Run that stops with ctx.Err() when ctx is done, during a
+// step or between steps. The run is traced in a pipeline span with a span
+// for every step that runs.
+func (p *Pipeline) RunContext(ctx context.Context) (reports []StepReport, err error) {
+	ctx, span := tracer.Start(ctx, "pipeline", trace.WithAttributes(attribute.Int("steps", len(p.Steps))))
+	defer func() { endSpan(span, nil, err) }()
+	order, err := p.order()
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := Checkpoints{Dir: p.Checkpoint}
+	var keys map[string]string
+	// a step is needed when it is an export or a needed step reads it and
+	// has no checkpoint
+	needed := make(map[string]bool, len(p.Steps))
+	if p.Checkpoint == "" {
+		for _, s := range p.Steps {
+			needed[s.Name] = true
+		}
+	} else {
+		if keys, err = p.checkpointKeys(order); err != nil {
+			return nil, err
+		}
+		for k := len(order) - 1; k >= 0; k-- {
+			s := p.Steps[order[k]]
+			if s.Op == "export" {
+				needed[s.Name] = true
+			}
+			if needed[s.Name] && (s.Op == "export" || !checkpoints.Has(keys[s.Name])) {
+				for _, in := range s.inputs() {
+					needed[in] = true
+				}
+			}
+		}
+	}
+
+	frames := make(map[string]*DataFrame, len(p.Steps))
+	reports = make([]StepReport, 0, len(order))
+	for _, i := range order {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		s := p.Steps[i]
+		report := StepReport{Step: s.Name, Op: s.Op, Start: time.Now()}
+		if !needed[s.Name] {
+			report.Checkpoint = "skipped"
+			reports = append(reports, report)
+			continue
+		}
+		start := report.Start
+		if key, found := keys[s.Name]; found {
+			df, loaded, err := checkpoints.Load(key)
+			if err != nil {
+				return reports, fmt.Errorf("step %s: %w", s.Name, err)
+			}
+			if loaded {
+				report.Duration = time.Since(start)
+				report.RowsOut = df.GetNumberOfRows()
+				report.Checkpoint = "loaded"
+				frames[s.Name] = df
+				reports = append(reports, report)
+				continue
+			}
+		}
+		for _, in := range s.inputs() {
+			report.RowsIn += frames[in].GetNumberOfRows()
+		}
+		stepCtx, span := tracer.Start(ctx, "step "+s.Name, trace.WithAttributes(attribute.String("op", s.Op)))
+		df, err := s.run(stepCtx, frames)
+		endSpan(span, df, err)
+		if err != nil {
+			return reports, fmt.Errorf("step %s: %w", s.Name, err)
+		}
+		if key, found := keys[s.Name]; found {
+			if err := checkpoints.Save(key, df); err != nil {
+				return reports, fmt.Errorf("step %s: %w", s.Name, err)
+			}
+			report.Checkpoint = "saved"
+		}
+		report.Duration = time.Since(start)
+		report.RowsOut = df.GetNumberOfRows()
+		frames[s.Name] = df
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// run executes one step on the outputs of the steps before it.
+func (s PipelineStep) run(ctx context.Context, frames map[string]*DataFrame) (*DataFrame, error) {
+	input := frames[s.Input]
+	switch s.Op {
+	case "importer":
+		file, err := os.Open(s.File)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		d, err := ReadCSV(file, s.File, CSVOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if s.Normalize {
+			d.NormalizeColumnNames()
+		}
+		if s.Schema != "" {
+			types, err := ParseSchemaSpec(s.Schema)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.ApplySchema(types); err != nil {
+				return nil, err
+			}
+		}
+		d.IndexRows()
+		return d, nil
+	case "project":
+		return input.ProjectContext(ctx, s.Cols...)
+	case "where":
+		return input.WhereContext(ctx, s.Cond)
+	case "transform":
+		// Transform changes its DataFrame, other steps may read the input too
+		df, err := input.project(input.GetFieldNames()...)
+		if err != nil {
+			return nil, err
+		}
+		df.Metadata.Source, df.Metadata.History = input.Metadata.Source, input.Metadata.History
+		return df, df.TransformContext(ctx, s.Statement)
+	case "rename":
+		// renaming changes the DataFrame too
+		df, err := input.project(input.GetFieldNames()...)
+		if err != nil {
+			return nil, err
+		}
+		df.Metadata.Source, df.Metadata.History = input.Metadata.Source, input.Metadata.History
+		return df, df.RenameColumns(s.Normalize, s.Cols...)
+	case "join":
+		return input.JoinContext(ctx, frames[s.Right], s.Keys)
+	case "union":
+		result := frames[s.Inputs[0]]
+		var err error
+		for _, in := range s.Inputs[1:] {
+			if s.ByName {
+				result, err = result.UnionByName(frames[in], UnionOptions{AllowMissingColumns: true, PromoteTypes: true})
+			} else {
+				result, err = result.UnionAll(frames[in])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", in, err)
+			}
+		}
+		return result, nil
+	case "distinct":
+		if s.Counts {
+			return input.ValueCounts(s.Cols[0])
+		}
+		return input.DistinctRows(s.Cols...)
+	case "export":
+		file := s.File
+		if file == "" {
+			file = "-"
+		}
+		return input, WriteFrameFile(file, input)
+	}
+	return nil, fmt.Errorf("unknown op %q", s.Op)
+}
+
+// shellQuote quotes a flag value for sh, in double quotes when that is safe.
+func shellQuote(s string) string {
+	if !strings.ContainsAny(s, "\"$`\\!") {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Shell returns a sh script running the pipeline with the operator binaries
+// in bin. Steps that feed only the next step are connected with a pipe,
+// other outputs are written to name.gob for the steps that read them.
+func (p *Pipeline) Shell(bin string) (string, error) {
+	order, err := p.order()
+	if err != nil {
+		return "", err
+	}
+	consumers := make(map[string][]int)
+	for _, i := range order {
+		for _, in := range p.Steps[i].inputs() {
+			consumers[in] = append(consumers[in], i)
+		}
+	}
+	// piped reports whether the output of the step at pos goes to stdin of
+	// the next step.
+	piped := func(pos int) bool {
+		name := p.Steps[order[pos]].Name
+		c := consumers[name]
+		if len(c) != 1 || pos+1 >= len(order) || order[pos+1] != c[0] {
+			return false
+		}
+		next := p.Steps[c[0]]
+		switch next.Op {
+		case "export":
+			return false
+		case "join":
+			return next.Input == name && next.Right != name
+		case "union":
+			// stdin can only be read once
+			return len(slices.DeleteFunc(slices.Clone(next.Inputs), func(in string) bool { return in != name })) == 1
+		}
+		return true
+	}
+	// export returns the export step that is the only reader of a step.
+	export := func(name string) (PipelineStep, bool) {
+		c := consumers[name]
+		if len(c) == 1 && p.Steps[c[0]].Op == "export" {
+			return p.Steps[c[0]], true
+		}
+		return PipelineStep{}, false
+	}
+
+	bin = strings.TrimSuffix(bin, "/")
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n\n")
+	for pos, i := range order {
+		s := p.Steps[i]
+		pipedIn := pos > 0 && piped(pos-1)
+		if s.Op == "export" {
+			if _, direct := export(s.Input); direct {
+				continue
+			}
+			if s.File == "" || s.File == "-" {
+				fmt.Fprintf(&b, "cat %s.gob\n", s.Input)
+			} else {
+				fmt.Fprintf(&b, "cp %s.gob %s\n", s.Input, s.File)
+			}
+			continue
+		}
+
+		args := []string{bin + "/" + s.Op}
+		switch s.Op {
+		case "importer":
+			args = append(args, "--file", s.File)
+			if s.Normalize {
+				args = append(args, "--normalize-headers")
+			}
+			if s.Schema != "" {
+				args = append(args, "--schema", shellQuote(s.Schema))
+			}
+		case "project":
+			args = append(args, "--cols", shellQuote(strings.Join(s.Cols, ",")))
+		case "rename":
+			if s.Normalize {
+				args = append(args, "--normalize")
+			}
+			if len(s.Cols) > 0 {
+				args = append(args, "--cols", shellQuote(strings.Join(s.Cols, ",")))
+			}
+		case "where":
+			args = append(args, "-cond", shellQuote(s.Cond))
+		case "transform":
+			args = append(args, "--statement", shellQuote(s.Statement))
+		case "join":
+			args = append(args, "-right", s.Right+".gob", "-keys", shellQuote(strings.Join(s.Keys, ",")))
+		case "union":
+			if s.ByName {
+				args = append(args, "-by-name")
+			}
+			for _, in := range s.Inputs {
+				if pipedIn && in == p.Steps[order[pos-1]].Name {
+					args = append(args, "-")
+				} else {
+					args = append(args, in+".gob")
+				}
+			}
+		case "distinct":
+			if len(s.Cols) > 0 {
+				args = append(args, "-cols", shellQuote(strings.Join(s.Cols, ",")))
+			}
+			if s.Counts {
+				args = append(args, "-counts")
+			}
+		}
+		if s.Input != "" && s.Op != "union" && !pipedIn {
+			args = append(args, "<", s.Input+".gob")
+		}
+
+		switch e, direct := export(s.Name); {
+		case piped(pos):
+			args = append(args, "|")
+		case direct && (e.File == "" || e.File == "-"):
+		case direct:
+			args = append(args, ">", e.File)
+		default:
+			args = append(args, ">", s.Name+".gob")
+		}
+		b.WriteString(strings.Join(args, " "))
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,142 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const tripsCSV = `Vendor_id,Fare_amount,Trip_distance
+1,12.50,11
+2,3.25,1
+1,7.00,12
+3,20.00,25
+`
+
+const vendorsCSV = `Vendor_id,vendor
+1,Creative
+2,VeriFone
+`
+
+func TestPipelineRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "long.gob")
+	p := &Pipeline{Steps: []PipelineStep{
+		{Name: "out", Op: "export", Input: "named", File: out},
+		{Name: "trips", Op: "importer", File: writeTestFile(t, "trips.csv", tripsCSV), Schema: "Vendor_id:int,Trip_distance:int"},
+		{Name: "vendors", Op: "importer", File: writeTestFile(t, "vendors.csv", vendorsCSV), Schema: "Vendor_id:int"},
+		{Name: "long", Op: "where", Input: "trips", Cond: "Trip_distance > 10"},
+		{Name: "fares", Op: "transform", Input: "long", Statement: "map(Fare_amount, float(#))"},
+		{Name: "named", Op: "join", Input: "fares", Right: "vendors", Keys: []string{"Vendor_id"}},
+		{Name: "zones", Op: "distinct", Input: "long", Cols: []string{"Vendor_id"}, Counts: true},
+		// the transform of fares works on a copy of long
+		{Name: "unchanged", Op: "where", Input: "long", Cond: "Fare_amount == '12.50'"},
+	}}
+	reports, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(reports))
+	for i, r := range reports {
+		got[i] = fmt.Sprintf("%s %s %d %d", r.Step, r.Op, r.RowsIn, r.RowsOut)
+	}
+	want := []string{
+		"trips importer 0 4",
+		"vendors importer 0 2",
+		"long where 4 3",
+		"fares transform 3 3",
+		"named join 5 2",
+		"zones distinct 3 2",
+		"unchanged where 3 1",
+		"out export 2 2",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("reports =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	df, err := ReadFrameFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, df), []string{"Vendor_id,Fare_amount,Trip_distance,Vendor_id,vendor", "1,12.5,11,1,Creative", "1,7,12,1,Creative"}; !slices.Equal(got, want) {
+		t.Errorf("exported %v, want %v", got, want)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	importer := PipelineStep{Name: "trips", Op: "importer", File: "trips.csv"}
+	tests := []struct {
+		steps []PipelineStep
+		want  string
+	}{
+		{nil, "pipeline has no steps"},
+		{[]PipelineStep{{Op: "importer", File: "a.csv"}}, "step 1 has no name"},
+		{[]PipelineStep{importer, importer}, "step name trips is used more than once"},
+		{[]PipelineStep{{Name: "trips", Op: "importer"}}, "step trips: importer needs file"},
+		{[]PipelineStep{importer, {Name: "p", Op: "project", Input: "trips"}}, "step p: project needs cols"},
+		{[]PipelineStep{importer, {Name: "w", Op: "where", Cond: "true"}}, "step w: where needs input"},
+		{[]PipelineStep{importer, {Name: "j", Op: "join", Input: "trips", Right: "trips"}}, "step j: join needs keys"},
+		{[]PipelineStep{importer, {Name: "u", Op: "union", Inputs: []string{"trips"}}}, "step u: union needs at least two inputs"},
+		{[]PipelineStep{importer, {Name: "d", Op: "distinct", Input: "trips", Counts: true}}, "step d: counts needs exactly one column in cols"},
+		{[]PipelineStep{importer, {Name: "s", Op: "sort", Input: "trips"}}, `step s: unknown op "sort"`},
+		{[]PipelineStep{importer, {Name: "w", Op: "where", Input: "trip", Cond: "true"}}, "step w: input trip is not the name of a step"},
+		{[]PipelineStep{
+			importer,
+			{Name: "out", Op: "export", Input: "trips"},
+			{Name: "w", Op: "where", Input: "out", Cond: "true"},
+		}, "step w: input out is an export and has no output"},
+		{[]PipelineStep{
+			importer,
+			{Name: "a", Op: "where", Input: "b", Cond: "true"},
+			{Name: "b", Op: "where", Input: "a", Cond: "true"},
+		}, "steps a, b depend on each other"},
+	}
+	for _, tt := range tests {
+		p := &Pipeline{Steps: tt.steps}
+		if _, err := p.Run(); err == nil || err.Error() != tt.want {
+			t.Errorf("Run: err = %v, want %q", err, tt.want)
+		}
+		if _, err := p.Shell("bin"); err == nil || err.Error() != tt.want {
+			t.Errorf("Shell: err = %v, want %q", err, tt.want)
+		}
+	}
+
+	p := &Pipeline{Steps: []PipelineStep{
+		{Name: "trips", Op: "importer", File: writeTestFile(t, "trips.csv", tripsCSV)},
+		{Name: "w", Op: "where", Input: "trips", Cond: "Trip_distance + 1"},
+	}}
+	if _, err := p.Run(); err == nil || !strings.HasPrefix(err.Error(), "step w: ") {
+		t.Errorf("err = %v, want the error of step w", err)
+	}
+}
+
+func TestPipelineShell(t *testing.T) {
+	p := &Pipeline{Steps: []PipelineStep{
+		{Name: "trips", Op: "importer", File: "trips.csv", Schema: "Vendor_id:int", Normalize: true},
+		{Name: "vendors", Op: "importer", File: "vendors.csv"},
+		{Name: "long", Op: "where", Input: "trips", Cond: `Trip_distance > 10 && zone != "a"`},
+		{Name: "named", Op: "join", Input: "long", Right: "vendors", Keys: []string{"Vendor_id"}},
+		{Name: "both", Op: "union", Inputs: []string{"named", "named"}},
+		{Name: "slim", Op: "project", Input: "both", Cols: []string{"vendor", "Fare_amount"}},
+		{Name: "out", Op: "export", Input: "slim", File: "slim.gob"},
+		{Name: "print", Op: "export", Input: "long"},
+	}}
+	got, err := p.Shell("./bin/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `#!/bin/sh
+
+./bin/importer --file trips.csv --normalize-headers --schema "Vendor_id:int" > trips.gob
+./bin/importer --file vendors.csv > vendors.gob
+./bin/where -cond 'Trip_distance > 10 && zone != "a"' < trips.gob > long.gob
+./bin/join -right vendors.gob -keys "Vendor_id" < long.gob > named.gob
+./bin/union named.gob named.gob |
+./bin/project --cols "vendor,Fare_amount" > slim.gob
+cat long.gob
+`
+	if got != want {
+		t.Errorf("Shell() =\n%s\nwant\n%s", got, want)
+	}
+}
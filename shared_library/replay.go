@@ -0,0 +1,139 @@
+package sharedlibrary
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// ReplayOptions change what Replay reads and writes.
+type ReplayOptions struct {
+	// Source is imported instead of the source of the DataFrame, the
+	// sources of the right inputs of joins and unions are kept. Right inputs
+	// without a source, like the DataFrame itself in a union with itself,
+	// are imported from Source too.
+	Source string
+	// Output is the file the result is exported to, empty or "-" for stdout.
+	Output string
+}
+
+// Replay returns a pipeline that repeats the history of a DataFrame: an
+// importer step for every source with the headers and schema it was
+// imported with, a step for every operation and an export of the result.
+// Operations that no operator performs, like Sort or GroupBy, can not be
+// replayed.
+func Replay(m Metadata, opts ReplayOptions) (*Pipeline, error) {
+	r := &replayer{p: &Pipeline{}, names: make(map[string]bool)}
+	last, err := r.provenance(Provenance{Source: m.Source, History: m.History}, opts.Source, opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	output := opts.Output
+	if output == "" {
+		output = "-"
+	}
+	r.add(PipelineStep{Name: "out", Op: "export", Input: last, File: output})
+	return r.p, nil
+}
+
+type replayer struct {
+	p     *Pipeline
+	names map[string]bool
+}
+
+// add appends a step under a name no other step has and returns the name.
+func (r *replayer) add(s PipelineStep) string {
+	name := s.Name
+	for n := 2; r.names[name]; n++ {
+		name = fmt.Sprintf("%s%d", s.Name, n)
+	}
+	r.names[name] = true
+	s.Name = name
+	r.p.Steps = append(r.p.Steps, s)
+	return name
+}
+
+// sourceStep names the importer of a source after its file.
+func sourceStep(source string) string {
+	name := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	name = strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-' {
+			return c
+		}
+		return '_'
+	}, name)
+	if name == "" {
+		return "source"
+	}
+	return name
+}
+
+// provenance adds the steps that produce a DataFrame from its source, or
+// from fallback when none is recorded, and returns the name of the last
+// one. The right inputs come first, so that the steps from the source to
+// the result follow each other.
+func (r *replayer) provenance(p Provenance, source, fallback string) (string, error) {
+	if source == "" {
+		source = p.Source
+	}
+	if source == "" {
+		source = fallback
+	}
+	if source == "" {
+		return "", errors.New("no source is recorded, replay it with a source file")
+	}
+	if source == "stdin" || strings.Contains(source, ", ") {
+		return "", fmt.Errorf("source %q can not be imported again, replay it with a source file", source)
+	}
+	rights := make(map[int]string)
+	for i, e := range p.History {
+		if e.Right == nil {
+			continue
+		}
+		right, err := r.provenance(*e.Right, "", fallback)
+		if err != nil {
+			return "", fmt.Errorf("right input of %s: %w", e, err)
+		}
+		rights[i] = right
+	}
+
+	importer := PipelineStep{Name: sourceStep(source), Op: "importer", File: source}
+	history := p.History
+	if len(history) > 0 && history[0].Operation == "NormalizeColumnNames" {
+		importer.Normalize = true
+		history = history[1:]
+	}
+	schema := make([]string, 0)
+	for len(history) > 0 && history[0].Operation == "ApplySchema" {
+		schema = append(schema, history[0].Args...)
+		history = history[1:]
+	}
+	importer.Schema = strings.Join(schema, ",")
+	last := r.add(importer)
+	offset := len(p.History) - len(history)
+	for i, e := range history {
+		s := PipelineStep{Name: strings.ToLower(e.Operation), Input: last}
+		switch e.Operation {
+		case "Project":
+			s.Op, s.Cols = "project", e.Args
+		case "RenameColumn":
+			s.Name, s.Op, s.Cols = "rename", "rename", []string{strings.Join(e.Args, ":")}
+		case "NormalizeColumnNames":
+			s.Name, s.Op, s.Normalize = "normalize", "rename", true
+		case "Where":
+			s.Op, s.Cond = "where", strings.Join(e.Args, "")
+		case "Transform":
+			s.Op, s.Statement = "transform", strings.Join(e.Args, "")
+		case "Join":
+			s.Op, s.Right, s.Keys = "join", rights[offset+i], e.Args
+		case "UnionAll":
+			s.Name, s.Op, s.Input, s.Inputs = "union", "union", "", []string{last, rights[offset+i]}
+		default:
+			return "", fmt.Errorf("%s, operation %d of the history of %s, has no operator to replay it", e, offset+i+1, p.Source)
+		}
+		last = r.add(s)
+	}
+	return last, nil
+}
@@ -0,0 +1,182 @@
+package sharedlibrary
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// importTestCSV imports a CSV file the way singleApp does, without a source
+// in the metadata.
+func importTestCSV(t *testing.T, path string) *DataFrame {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d := CreateDataFrameFromCSV(csv.NewReader(file))
+	return &d
+}
+
+// checkReplay replays the history of d on source and checks that the
+// pipeline, run and as a shell script, gives the same rows.
+func checkReplay(t *testing.T, d *DataFrame, source string) *Pipeline {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out.gob")
+	p, err := Replay(d.Metadata, ReplayOptions{Source: source, Output: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := ReadFrameFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, replayed), rows(t, d); !slices.Equal(got, want) {
+		t.Errorf("replayed rows\n%q\nwant\n%q", got, want)
+	}
+	if _, err := p.Shell("bin"); err != nil {
+		t.Error(err)
+	}
+	return p
+}
+
+// TestReplaySingleApp replays the operations singleApp runs on the housing
+// and census files.
+func TestReplaySingleApp(t *testing.T) {
+	t.Run("housing", func(t *testing.T) {
+		source := writeTestFile(t, "housing.csv", `longitude,housing_median_age,total_rooms,households,median_income,median_house_value,ocean_proximity
+-122.23,41.0,880.0,126.0,8.3252,452600.0,NEAR BAY
+-122.22,42.0,7099.0,1138.0,8.3014,358500.0,NEAR BAY
+-122.24,52.0,1467.0,177.0,7.2574,352100.0,NEAR BAY
+-122.25,52.0,1274.0,219.0,5.6431,341300.0,INLAND
+-122.26,52.0,1627.0,259.0,3.8462,342200.0,NEAR BAY
+`)
+		d := importTestCSV(t, source)
+		x, err := d.Project("median_house_value", "total_rooms", "ocean_proximity", "median_income", "households", "housing_median_age")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, statement := range []string{
+			" map( housing_median_age, int( replace( # , '.0', '' ) ) )",
+			" map( households, int( replace( #,'.0', '' ) ) )",
+			" map( median_income, int( replace( replace( #, '.', '' ), '.0', '' ) ) )",
+			" map( total_rooms, int( replace( #, '.0', '' ) ) )",
+			" map( median_house_value, int( replace( #, '.0', '') ) )",
+		} {
+			if err := x.Transform(statement); err != nil {
+				t.Fatal(err)
+			}
+		}
+		y, err := x.Where(" housing_median_age >= 42 ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		z, err := y.Where(" ocean_proximity == 'NEAR BAY' ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, step := range []func() error{
+			func() error {
+				return z.Transform("let total_households = reduce( households, #acc + # , 0 ); total_households ")
+			},
+			func() error {
+				return z.Transform("let total_rooms2 	  = reduce( total_rooms, #acc + #, 0 ); total_rooms2 ")
+			},
+			func() error { return z.RenameColumn("total_rooms2", "summary_total_rooms") },
+			func() error { return z.RenameColumn("total_households", "summary_total_households") },
+			func() error {
+				return z.Transform("let avg_households   = max(summary_total_households) / len(summary_total_households); avg_households ")
+			},
+		} {
+			if err := step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		a, err := z.UnionAll(z)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a, err = a.UnionAll(a); err != nil {
+			t.Fatal(err)
+		}
+		if a, err = a.Where("median_income > 40000"); err != nil {
+			t.Fatal(err)
+		}
+		if a.GetNumberOfRows() != 8 {
+			t.Fatalf("singleApp result has %d rows, want 8", a.GetNumberOfRows())
+		}
+
+		p := checkReplay(t, a, source)
+		ops := make([]string, 0)
+		for _, s := range p.Steps {
+			ops = append(ops, s.Op)
+		}
+		if got := strings.Join(ops, " "); strings.Count(got, "rename") != 2*4 || strings.Count(got, "union") != 3 {
+			t.Errorf("replayed ops %s, want the renames of every input of the unions", got)
+		}
+	})
+
+	t.Run("census", func(t *testing.T) {
+		source := writeTestFile(t, "census.csv", `age,workclass,fnlwgt,education,education.num,marital.status,occupation,relationship,race,sex,capital.gain,capital.loss,hours.per.week,native.country,income
+90,?,77053,HS-grad,9,Widowed,?,Not-in-family,White,Female,0,4356,40,United-States,<=50K
+82,Private,132870,HS-grad,9,Widowed,Exec-managerial,Not-in-family,White,Female,0,4356,18,United-States,<=50K
+66,?,186061,Some-college,10,Widowed,?,Unmarried,Black,Female,0,4356,40,United-States,<=50K
+`)
+		d1 := importTestCSV(t, source)
+		for _, statement := range []string{
+			"map(relationship	, upper(#))",
+			"map(occupation	, lower(#))",
+			"map(race			, upper(#))",
+			"map(sex			, lower(#))",
+			"map(education		, lower(#))",
+		} {
+			if err := d1.Transform(statement); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d1.NormalizeColumnNames()
+		for _, statement := range []string{
+			"map(native_country, upper(#))",
+			"map(hours_per_week, int(#))",
+			"map(marital_status, upper(#))",
+			"map(capital_loss	, int(#))",
+			"map(capital_gain	, int(#))",
+			"map(education_num	, int(#))",
+			"map(fnlwgt		, int(#))",
+			"map(workclass		, lower(#))",
+			"map(age			, int(#))",
+		} {
+			if err := d1.Transform(statement); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		p := checkReplay(t, d1, source)
+		if i := slices.IndexFunc(p.Steps, func(s PipelineStep) bool { return s.Op == "rename" }); i < 0 || !p.Steps[i].Normalize {
+			t.Errorf("NormalizeColumnNames after Transform is not a rename step with normalize")
+		}
+	})
+}
+
+func TestReplayErrors(t *testing.T) {
+	d := newTestFrame(t, "", []string{"a", "b"}, []string{"1", "2"})
+	if _, err := Replay(d.Metadata, ReplayOptions{}); err == nil {
+		t.Error("a history without a source was replayed")
+	}
+	d.Metadata.Source = "stdin"
+	if _, err := Replay(d.Metadata, ReplayOptions{}); err == nil {
+		t.Error("a history read from stdin was replayed")
+	}
+	d.Metadata.Source = "a.csv"
+	d.Metadata.History = []HistoryEntry{{Operation: "Sort", Args: []string{"a"}}}
+	if _, err := Replay(d.Metadata, ReplayOptions{}); err == nil || !strings.Contains(err.Error(), "no operator") {
+		t.Errorf("Sort was replayed: %v", err)
+	}
+}
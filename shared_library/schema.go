@@ -1,5 +1,16 @@
 package sharedlibrary
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DecimalType is the FieldType of fixed-point columns holding Decimal values.
+// The precision and scale are kept in the Field.
+const DecimalType = "DECIMAL"
+
 type Schema struct {
 	Fields []Field
 }
@@ -8,6 +19,8 @@ type Field struct {
 	FieldName     string
 	FieldPosition int
 	FieldType     string
+	Precision     int32
+	Scale         int32
 	RowStats      RowStat
 }
 
@@ -32,3 +45,157 @@ func (s *Schema) GetType(fieldname string) *string {
 	}
 	return nil
 }
+
+// MatchByPosition checks that two schemas have the same number of fields
+// and the same names and types in the same order, as required by UnionAll.
+// Returns a *SchemaMismatchError listing every difference.
+func (s *Schema) MatchByPosition(other *Schema) error {
+	differences := make([]string, 0)
+	if len(s.Fields) != len(other.Fields) {
+		differences = append(differences, fmt.Sprintf("# %d columns differ from %d columns", len(s.Fields), len(other.Fields)))
+	}
+	for i := 0; i < max(len(s.Fields), len(other.Fields)); i++ {
+		switch {
+		case i >= len(other.Fields):
+			differences = append(differences, fmt.Sprintf("- column %d %s (%s): missing in other frame", i, s.Fields[i].FieldName, s.Fields[i].FieldType))
+		case i >= len(s.Fields):
+			differences = append(differences, fmt.Sprintf("+ column %d %s (%s): missing in this frame", i, other.Fields[i].FieldName, other.Fields[i].FieldType))
+		case s.Fields[i].FieldName != other.Fields[i].FieldName:
+			differences = append(differences, fmt.Sprintf("~ column %d: name %s differs from %s", i, s.Fields[i].FieldName, other.Fields[i].FieldName))
+		case s.Fields[i].FieldType != other.Fields[i].FieldType:
+			differences = append(differences, fmt.Sprintf("~ column %d %s: type %s differs from %s", i, s.Fields[i].FieldName, s.Fields[i].FieldType, other.Fields[i].FieldType))
+		}
+	}
+	if len(differences) > 0 {
+		return &SchemaMismatchError{Differences: differences}
+	}
+	return nil
+}
+
+// TypeSpec returns the type of a field as ParseFieldType reads it.
+func (f Field) TypeSpec() string {
+	if f.FieldType == DecimalType {
+		return fmt.Sprintf("decimal(%d,%d)", f.Precision, f.Scale)
+	}
+	return strings.ToLower(f.FieldType)
+}
+
+// ParseFieldType parses a type name as used in schema specifications:
+// string, int, float or decimal(precision,scale).
+// The returned Field only has its type information set.
+func ParseFieldType(t string) (Field, error) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	switch t {
+	case "string", "int", "float":
+		return Field{FieldType: t}, nil
+	}
+	args, ok := strings.CutPrefix(t, "decimal(")
+	if !ok || !strings.HasSuffix(args, ")") {
+		return Field{}, fmt.Errorf("unknown field type %q", t)
+	}
+	p, s, ok := strings.Cut(strings.TrimSuffix(args, ")"), ",")
+	if !ok {
+		return Field{}, fmt.Errorf("decimal type %q needs a precision and a scale", t)
+	}
+	precision, err := strconv.Atoi(strings.TrimSpace(p))
+	if err != nil {
+		return Field{}, fmt.Errorf("invalid precision in %q", t)
+	}
+	scale, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || scale < 0 || scale > precision || precision > 18 {
+		return Field{}, fmt.Errorf("invalid scale in %q, expected 0 <= scale <= precision <= 18", t)
+	}
+	return Field{FieldType: DecimalType, Precision: int32(precision), Scale: int32(scale)}, nil
+}
+
+// ParseSchemaSpec parses a comma or newline separated list of name:type
+// pairs such as "Fare_amount:decimal(10,2),Vendor_id:int".
+func ParseSchemaSpec(spec string) ([]Field, error) {
+	fields := make([]Field, 0)
+	depth, start := 0, 0
+	for i := 0; i <= len(spec); i++ {
+		if i < len(spec) {
+			switch spec[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',', '\n':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		entry := strings.TrimSpace(spec[start:i])
+		start = i + 1
+		if entry == "" {
+			continue
+		}
+		name, t, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("schema entry %q is not of the form name:type", entry)
+		}
+		f, err := ParseFieldType(t)
+		if err != nil {
+			return nil, err
+		}
+		f.FieldName = strings.TrimSpace(name)
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// NormalizeNames turns column names into snake_case identifiers that
+// expressions can use without col: camelCase is split, letters are lower
+// cased, runs of spaces, punctuation and underscores become one underscore
+// and a leading digit gets an underscore before it. Names left empty are
+// column_<position> and a repeated name gets _2, _3, ... appended.
+func NormalizeNames(names []string) []string {
+	normalized := make([]string, len(names))
+	used := make(map[string]bool, len(names))
+	for i, name := range names {
+		base := normalizeName(name)
+		if base == "" {
+			base = fmt.Sprintf("column_%d", i+1)
+		}
+		n := base
+		for k := 2; used[n]; k++ {
+			n = fmt.Sprintf("%s_%d", base, k)
+		}
+		used[n] = true
+		normalized[i] = n
+	}
+	return normalized
+}
+
+func normalizeName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	separate := false
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separate = true
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			// fareAmount, trip2Km and the end of an acronym as in HTTPStatus
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			separate = separate || unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower
+		}
+		if separate && b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		separate = false
+		b.WriteRune(unicode.ToLower(r))
+	}
+	s := b.String()
+	if s != "" && unicode.IsDigit([]rune(s)[0]) {
+		s = "_" + s
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package sharedlibrary
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeNames(t *testing.T) {
+	for _, tt := range []struct{ name, want string }{
+		{"native.country", "native_country"},
+		{"hours-per-week", "hours_per_week"},
+		{" Fare  Amount! ", "fare_amount"},
+		{"fareAmount", "fare_amount"},
+		{"HTTPStatus", "http_status"},
+		{"trip2Km", "trip2_km"},
+		{"snake__case_", "snake_case"},
+		{"2nd leg", "_2nd_leg"},
+		{"Zürich Öl", "zürich_öl"},
+	} {
+		if got := NormalizeNames([]string{tt.name}); !slices.Equal(got, []string{tt.want}) {
+			t.Errorf("NormalizeNames(%q) = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// names left empty get their position, repeated names a number
+	got := NormalizeNames([]string{"Zone", "zone", "", "?!", "zone_2", "ZONE"})
+	if want := []string{"zone", "zone_2", "column_3", "column_4", "zone_2_2", "zone_3"}; !slices.Equal(got, want) {
+		t.Errorf("NormalizeNames = %v, want %v", got, want)
+	}
+}
+
+func TestColumnReferences(t *testing.T) {
+	d := newTestFrame(t, "hours per week:int", []string{"native.country", "hours per week"},
+		[]string{"US", "40"}, []string{"DE", "35"}, []string{"US", "20"})
+
+	df, err := d.Where(`col("native.country") == "US" && col("hours per week") > 30`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, df, "hours per week"); !slices.Equal(got, []string{"40"}) {
+		t.Errorf("Where with col() = %v, want [40]", got)
+	}
+
+	// the column of col() is the target of a transform
+	if err := d.Transform(`map(col("hours per week"), # * 2)`); err != nil {
+		t.Fatal(err)
+	}
+	if got := columnStrings(t, d, "hours per week"); !slices.Equal(got, []string{"80", "70", "40"}) {
+		t.Errorf("Transform with col() = %v, want [80 70 40]", got)
+	}
+	e := d.Metadata.History[len(d.Metadata.History)-1]
+	if got := e.Columns[1].String(); got != "hours per week <- f(hours per week)" {
+		t.Errorf("lineage of the transform %q", got)
+	}
+
+	// only a quoted name is a column reference
+	if _, err := d.Where("col(country) == 'US'"); err == nil {
+		t.Error("col with an unquoted name succeeded")
+	}
+}
+
+func TestNormalizeColumnNames(t *testing.T) {
+	d := newTestFrame(t, "", []string{"native.country", "Hours per week"}, []string{"US", "40"})
+	if err := d.CreateIndex("native.country", HashIndex); err != nil {
+		t.Fatal(err)
+	}
+	d.NormalizeColumnNames()
+	if got := d.GetFieldNames(); !slices.Equal(got, []string{"native_country", "hours_per_week"}) {
+		t.Errorf("columns %v", got)
+	}
+	// indexes follow their column
+	if got := d.GetIndexKinds("native_country"); !slices.Equal(got, []IndexKind{HashIndex}) {
+		t.Errorf("indexes on native_country %v, want [hash]", got)
+	}
+	df, err := d.Where("native_country == 'US' && hours_per_week == '40'")
+	if err != nil || df.GetNumberOfRows() != 1 {
+		t.Errorf("Where on normalized names = %v, %v", df, err)
+	}
+	if got := d.Metadata.History[0].String(); got != "NormalizeColumnNames()" {
+		t.Errorf("history %s", got)
+	}
+}
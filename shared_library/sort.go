@@ -0,0 +1,224 @@
+package sharedlibrary
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// SortKey is a column to sort on and its direction.
+type SortKey struct {
+	Column     string
+	Descending bool
+}
+
+// takeRows returns a DataFrame with the given rows, in that order.
+func (d *DataFrame) takeRows(rows []int) *DataFrame {
+	num_fields := len(d.Schema.Fields)
+	data := make([]*Data, num_fields)
+	for j := range data {
+		column := d.Data.getColumn(j)
+		values := make(Data, len(rows))
+		for i, r := range rows {
+			values[i] = column[r]
+		}
+		data[j] = &values
+	}
+	return &DataFrame{
+		Schema: Schema{
+			Fields: slices.Clone(d.Schema.Fields),
+		},
+		Data: &InternalDataStructure{
+			Data:    data,
+			Rows:    len(rows),
+			Columns: num_fields,
+		},
+	}
+}
+
+// Sort returns the rows of the DataFrame ordered by the keys, the first key
+// first. The sort is stable and nil values come before all other values.
+func (d *DataFrame) Sort(keys ...SortKey) (*DataFrame, error) {
+	indices := make([]int, len(keys))
+	for i, key := range keys {
+		indices[i] = d.GetFieldNumber(key.Column)
+		if indices[i] < 0 {
+			return nil, fmt.Errorf("sort key '%s' not found in DataFrame", key.Column)
+		}
+	}
+	columns := make([]Data, len(keys))
+	for i, x := range indices {
+		columns[i] = d.Data.getColumn(x)
+	}
+	var sortErr error
+	compare := func(a, b int) int {
+		for i, column := range columns {
+			var c int
+			switch x, y := column[a], column[b]; {
+			case x == nil && y == nil:
+				c = 0
+			case x == nil:
+				c = -1
+			case y == nil:
+				c = 1
+			default:
+				var err error
+				if c, err = compareValues(x, y); err != nil && sortErr == nil {
+					sortErr = fmt.Errorf("column %s can not be sorted: %w", keys[i].Column, err)
+				}
+			}
+			if keys[i].Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	num_rows := d.GetNumberOfRows()
+	// over the memory budget the rows are sorted in runs merged from disk
+	if overBudget(int64(num_rows) * sortRowBytes) {
+		df, err := d.externalSort(compare)
+		if sortErr != nil {
+			return nil, sortErr
+		}
+		return df, err
+	}
+	rows := make([]int, num_rows)
+	for i := range rows {
+		rows[i] = i
+	}
+	slices.SortStableFunc(rows, compare)
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return d.takeRows(rows), nil
+}
+
+// sortRun is the next row of a sorted run of an external sort.
+type sortRun struct {
+	row    int
+	run    int
+	reader *bufio.Reader
+}
+
+// sortRuns is a heap of the next rows of the runs, ties go to the earlier
+// run to keep the sort stable.
+type sortRuns struct {
+	runs    []*sortRun
+	compare func(a, b int) int
+}
+
+func (h *sortRuns) Len() int { return len(h.runs) }
+func (h *sortRuns) Less(i, j int) bool {
+	if c := h.compare(h.runs[i].row, h.runs[j].row); c != 0 {
+		return c < 0
+	}
+	return h.runs[i].run < h.runs[j].run
+}
+func (h *sortRuns) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *sortRuns) Push(x any)    { h.runs = append(h.runs, x.(*sortRun)) }
+func (h *sortRuns) Pop() any {
+	last := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return last
+}
+
+// externalSort sorts runs of rows that fit the memory budget, writes them
+// to temporary files and merges them into the result.
+func (d *DataFrame) externalSort(compare func(a, b int) int) (*DataFrame, error) {
+	s, err := newSpill("sort")
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	num_rows := d.GetNumberOfRows()
+	// at most 256 runs are merged at once
+	runRows := int(max(MemoryBudget()/sortRowBytes, int64(num_rows/256+1)))
+	run := make([]int, 0, min(runRows, num_rows))
+	for start := 0; start < num_rows; start += runRows {
+		run = run[:0]
+		for i := start; i < min(start+runRows, num_rows); i++ {
+			run = append(run, i)
+		}
+		slices.SortStableFunc(run, compare)
+		f, err := s.create()
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range run {
+			if err := f.writeUvarint(uint64(row)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	h := &sortRuns{compare: compare}
+	next := func(r *sortRun) (bool, error) {
+		row, err := binary.ReadUvarint(r.reader)
+		if err == io.EOF {
+			return false, nil
+		}
+		r.row = int(row)
+		return err == nil, err
+	}
+	for i, f := range s.files {
+		reader, err := f.rewind()
+		if err != nil {
+			return nil, err
+		}
+		r := &sortRun{run: i, reader: reader}
+		if ok, err := next(r); err != nil {
+			return nil, err
+		} else if ok {
+			h.runs = append(h.runs, r)
+		}
+	}
+	heap.Init(h)
+	columns := make([]Data, d.GetNumberOfColumns())
+	data := make([]*Data, len(columns))
+	for j := range columns {
+		columns[j] = d.Data.getColumn(j)
+		values := make(Data, 0, num_rows)
+		data[j] = &values
+	}
+	for h.Len() > 0 {
+		r := h.runs[0]
+		for j, column := range columns {
+			*data[j] = append(*data[j], column[r.row])
+		}
+		ok, err := next(r)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return &DataFrame{
+		Schema: Schema{
+			Fields: slices.Clone(d.Schema.Fields),
+		},
+		Data: &InternalDataStructure{
+			Data:    data,
+			Rows:    num_rows,
+			Columns: len(data),
+		},
+	}, nil
+}
+
+// Head returns the first n rows of the DataFrame.
+func (d *DataFrame) Head(n int) *DataFrame {
+	n = max(0, min(n, d.GetNumberOfRows()))
+	rows := make([]int, n)
+	for i := range rows {
+		rows[i] = i
+	}
+	return d.takeRows(rows)
+}
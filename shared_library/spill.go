@@ -0,0 +1,286 @@
+package sharedlibrary
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// memoryBudget is how many bytes Join, Sort, DistinctRows, ValueCounts and
+// GroupBy may use for their hash tables and sort runs, 0 for no limit.
+var memoryBudget atomic.Int64
+
+// SetMemoryBudget sets the memory Join, Sort, DistinctRows, ValueCounts and
+// GroupBy may use for their hash tables and sort runs. Over the budget they
+// spill the keys and positions of their rows, by partition, to temporary
+// files and build their tables one partition at a time. The budget does not
+// bound their input and output, which stay in memory. 0 removes the budget.
+func SetMemoryBudget(bytes int64) {
+	memoryBudget.Store(bytes)
+}
+
+// MemoryBudget returns the budget set with SetMemoryBudget.
+func MemoryBudget() int64 {
+	return memoryBudget.Load()
+}
+
+// overBudget reports whether an estimate of the memory an operation uses
+// exceeds the budget.
+func overBudget(bytes int64) bool {
+	budget := MemoryBudget()
+	return budget > 0 && bytes > budget
+}
+
+// Estimates of the memory used per entry of the hash tables and sort runs,
+// besides their key.
+const (
+	joinEntryBytes     = 80
+	groupEntryBytes    = 96
+	aggregatorBytes    = 64
+	distinctEntryBytes = 48
+	sortRowBytes       = 8
+)
+
+// SpillReport is what an operation wrote to temporary files because it
+// would have exceeded the memory budget.
+type SpillReport struct {
+	Operation  string
+	Partitions int
+	Bytes      int64
+}
+
+func (r SpillReport) String() string {
+	return fmt.Sprintf("%s spilled %s to %d temporary files", r.Operation, formatBytes(r.Bytes), r.Partitions)
+}
+
+// formatBytes formats a size with the units of ParseByteSize.
+func formatBytes(n int64) string {
+	const units = "KMGT"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, unit := float64(n)/1024, 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, units[unit])
+}
+
+var spills struct {
+	sync.Mutex
+	reports []SpillReport
+}
+
+// Spills returns the reports of the operations that spilled since the last
+// call and forgets them.
+func Spills() []SpillReport {
+	spills.Lock()
+	defer spills.Unlock()
+	reports := spills.reports
+	spills.reports = nil
+	return reports
+}
+
+func reportSpill(r SpillReport) {
+	spills.Lock()
+	defer spills.Unlock()
+	spills.reports = append(spills.reports, r)
+}
+
+// partitionsFor returns how many partitions split an estimated size into
+// parts that fit the budget, with room to spare for skewed keys.
+func partitionsFor(estimate int64) int {
+	n := 2*estimate/max(MemoryBudget(), 1) + 1
+	return int(min(max(n, 2), 256))
+}
+
+// estimateTotal extrapolates the memory used after some of the rows to all
+// of them.
+func estimateTotal(used int64, done, rows int) int64 {
+	return used * int64(rows) / int64(max(done, 1))
+}
+
+// spillFile is a temporary file written and read sequentially.
+type spillFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	bytes  int64
+	buffer [binary.MaxVarintLen64]byte
+}
+
+func (f *spillFile) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(f.buffer[:], v)
+	f.bytes += int64(n)
+	_, err := f.writer.Write(f.buffer[:n])
+	return err
+}
+
+// rewind flushes the file and returns a reader from its start.
+func (f *spillFile) rewind() (*bufio.Reader, error) {
+	if err := f.writer.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(f.file), nil
+}
+
+// spill is a set of temporary files of one operation in a directory of its
+// own, removed by close.
+type spill struct {
+	operation string
+	dir       string
+	files     []*spillFile
+}
+
+func newSpill(operation string) (*spill, error) {
+	dir, err := os.MkdirTemp("", "operators-spill-")
+	if err != nil {
+		return nil, err
+	}
+	return &spill{operation: operation, dir: dir}, nil
+}
+
+func (s *spill) create() (*spillFile, error) {
+	file, err := os.Create(filepath.Join(s.dir, fmt.Sprintf("%d", len(s.files))))
+	if err != nil {
+		return nil, err
+	}
+	f := &spillFile{file: file, writer: bufio.NewWriterSize(file, 64*1024)}
+	s.files = append(s.files, f)
+	return f, nil
+}
+
+// close removes the files and reports what was spilled.
+func (s *spill) close() {
+	report := SpillReport{Operation: s.operation, Partitions: len(s.files)}
+	for _, f := range s.files {
+		report.Bytes += f.bytes
+		f.file.Close()
+	}
+	os.RemoveAll(s.dir)
+	reportSpill(report)
+}
+
+// keyPartitions splits rows by the hash of their key into files that hold
+// the key and the position of every row, in the order they were written.
+type keyPartitions struct {
+	files []*spillFile
+}
+
+func (s *spill) keyPartitions(n int) (*keyPartitions, error) {
+	p := &keyPartitions{files: make([]*spillFile, n)}
+	for i := range p.files {
+		f, err := s.create()
+		if err != nil {
+			return nil, err
+		}
+		p.files[i] = f
+	}
+	return p, nil
+}
+
+func (p *keyPartitions) write(key string, row int) error {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	f := p.files[h.Sum32()%uint32(len(p.files))]
+	if err := f.writeUvarint(uint64(len(key))); err != nil {
+		return err
+	}
+	f.bytes += int64(len(key))
+	if _, err := f.writer.WriteString(key); err != nil {
+		return err
+	}
+	return f.writeUvarint(uint64(row))
+}
+
+// read calls fn for every row of a partition in the order they were written.
+func (p *keyPartitions) read(partition int, fn func(key string, row int) error) error {
+	r, err := p.files[partition].rewind()
+	if err != nil {
+		return err
+	}
+	var key []byte
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key = slices.Grow(key[:0], int(n))[:n]
+		if _, err := io.ReadFull(r, key); err != nil {
+			return err
+		}
+		row, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if err := fn(string(key), int(row)); err != nil {
+			return err
+		}
+	}
+}
+
+// firstRows splits the rows by key into partitions and groups them one
+// partition at a time. It returns for every key the first row with the key
+// and the state visit accumulated over its rows, in the order of the first
+// rows.
+func firstRows[T any](ctx context.Context, s *spill, partitions, rows int, keyOf func(row int) string, visit func(state T, row int) (T, error)) ([]int, []T, error) {
+	p, err := s.keyPartitions(partitions)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < rows; i++ {
+		if err := canceled(ctx, i); err != nil {
+			return nil, nil, err
+		}
+		if err := p.write(keyOf(i), i); err != nil {
+			return nil, nil, err
+		}
+	}
+	type group struct {
+		first int
+		state T
+	}
+	groups := make([]group, 0)
+	for partition := range p.files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		positions := make(map[string]int)
+		err := p.read(partition, func(key string, row int) error {
+			g, found := positions[key]
+			if !found {
+				g = len(groups)
+				positions[key] = g
+				groups = append(groups, group{first: row})
+			}
+			var err error
+			groups[g].state, err = visit(groups[g].state, row)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	// the groups of the partitions are merged back in the order of the rows
+	slices.SortFunc(groups, func(a, b group) int { return a.first - b.first })
+	firsts := make([]int, len(groups))
+	states := make([]T, len(groups))
+	for i, g := range groups {
+		firsts[i], states[i] = g.first, g.state
+	}
+	return firsts, states, nil
+}
@@ -0,0 +1,95 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// spillFrames returns trips and vendors large enough to exceed a budget
+// of a few KiB, with repeated and missing keys.
+func spillFrames(t *testing.T) (*DataFrame, *DataFrame) {
+	t.Helper()
+	trips := make([][]string, 0)
+	for i := range 3000 {
+		vendor := fmt.Sprint(i * 7 % 101)
+		if i%97 == 0 {
+			vendor = ""
+		}
+		trips = append(trips, []string{fmt.Sprint(i), vendor, fmt.Sprintf("%d.%02d", i%50, i%100), fmt.Sprint("zone", i%13)})
+	}
+	vendors := make([][]string, 0)
+	for i := range 120 {
+		vendors = append(vendors, []string{fmt.Sprint(i % 90), fmt.Sprint("vendor", i)})
+	}
+	return newTestFrame(t, "id:int,Vendor_id:int,Fare_amount:decimal(6,2)", []string{"id", "Vendor_id", "Fare_amount", "zone"}, trips...),
+		newTestFrame(t, "Vendor_id:int", []string{"Vendor_id", "vendor"}, vendors...)
+}
+
+func TestSpillMatchesMemory(t *testing.T) {
+	trips, vendors := spillFrames(t)
+	operations := []struct {
+		name string
+		run  func() (*DataFrame, error)
+	}{
+		{"join", func() (*DataFrame, error) { return trips.Join(vendors, []string{"Vendor_id"}) }},
+		{"sort", func() (*DataFrame, error) {
+			return trips.Sort(SortKey{Column: "Vendor_id", Descending: true}, SortKey{Column: "Fare_amount"})
+		}},
+		{"distinct", func() (*DataFrame, error) { return trips.DistinctRows("Vendor_id", "zone") }},
+		{"value counts", func() (*DataFrame, error) { return trips.ValueCounts("Fare_amount") }},
+		{"group by", func() (*DataFrame, error) {
+			return trips.GroupBy([]string{"zone", "Vendor_id"},
+				Aggregation{Column: "*", Function: "count"},
+				Aggregation{Column: "Fare_amount", Function: "sum"},
+				Aggregation{Column: "id", Function: "max"},
+				Aggregation{Column: "id", Function: "first"})
+		}},
+	}
+	t.Cleanup(func() { SetMemoryBudget(0) })
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			SetMemoryBudget(0)
+			want, err := op.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			Spills()
+			SetMemoryBudget(4 << 10)
+			got, err := op.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			reports := Spills()
+			if len(reports) == 0 || reports[0].Partitions < 2 || reports[0].Bytes == 0 {
+				t.Errorf("spills = %v, want a report", reports)
+			}
+			if g, w := rows(t, got), rows(t, want); !slices.Equal(g, w) {
+				t.Errorf("%d rows spilled, %d in memory, first difference at %d", len(g), len(w), firstDifference(g, w))
+			}
+		})
+	}
+}
+
+func firstDifference(a, b []string) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return min(len(a), len(b))
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:        "512B",
+		1536:       "1.5KiB",
+		64 << 20:   "64.0MiB",
+		3 << 40:    "3.0TiB",
+		5000 << 40: "5000.0TiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,1116 @@
+package sharedlibrary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+// SQL runs a SELECT query on named DataFrames. The query is compiled onto
+// the DataFrame operations: JOIN onto Join, WHERE onto Where, computed
+// columns onto Transform, GROUP BY onto GroupBy, ORDER BY onto Sort and the
+// select list onto Project. Scalar expressions are expr expressions written
+// with SQL operators, so functions like upper(name) or round(x) are the expr
+// builtins.
+//
+//	SELECT [DISTINCT] item [AS alias], ...
+//	FROM table [alias]
+//	[[INNER] JOIN table [alias] ON a.x = b.y [AND ...] | USING (x, ...)] ...
+//	[WHERE condition]
+//	[GROUP BY column, ...]
+//	[ORDER BY item [ASC|DESC], ...]
+//	[LIMIT n]
+//
+// Items are *, columns, expressions or the aggregates COUNT(*), COUNT, SUM,
+// AVG, MIN, MAX, FIRST and LAST of an expression. Comparisons with NULL
+// are false and arithmetic with NULL is NULL.
+func SQL(query string, tables map[string]*DataFrame) (*DataFrame, error) {
+	return runSQL(query, tables, nil)
+}
+
+// SQL runs a SELECT query with the DataFrame as the table in FROM, whatever
+// its name. JOINs can join it with itself under that name.
+func (d *DataFrame) SQL(query string) (*DataFrame, error) {
+	return runSQL(query, nil, d)
+}
+
+// sqlToken is a token of a query. kind is 'i' for identifiers and keywords,
+// 'q' for quoted identifiers, 'n' for numbers, 's' for strings and 'o' for
+// operators and punctuation.
+type sqlToken struct {
+	kind byte
+	text string
+}
+
+// is reports whether the token is the keyword kw.
+func (t sqlToken) is(kw string) bool {
+	return t.kind == 'i' && strings.EqualFold(t.text, kw)
+}
+
+func (t sqlToken) isIdentifier() bool {
+	return t.kind == 'i' || t.kind == 'q'
+}
+
+func tokenizeSQL(query string) ([]sqlToken, error) {
+	tokens := make([]sqlToken, 0)
+	s := []rune(query)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(s) && s[i+1] == '-':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(s) && (unicode.IsLetter(s[j]) || unicode.IsDigit(s[j]) || s[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{'i', string(s[i:j])})
+			i = j
+		case unicode.IsDigit(c) || c == '.' && i+1 < len(s) && unicode.IsDigit(s[i+1]):
+			j := i
+			for j < len(s) && (unicode.IsDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{'n', string(s[i:j])})
+			i = j
+		case c == '\'' || c == '"' || c == '`':
+			// strings double the quote to escape it, so do quoted identifiers
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(s) {
+					return nil, fmt.Errorf("unterminated %c in query", c)
+				}
+				if s[j] == c {
+					if j+1 < len(s) && s[j+1] == c {
+						b.WriteRune(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteRune(s[j])
+				j++
+			}
+			kind := byte('q')
+			if c == '\'' {
+				kind = 's'
+			}
+			tokens = append(tokens, sqlToken{kind, b.String()})
+			i = j + 1
+		default:
+			if i+1 < len(s) {
+				if op := string(s[i : i+2]); slices.Contains([]string{"<=", ">=", "<>", "!=", "==", "||"}, op) {
+					tokens = append(tokens, sqlToken{'o', op})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("=<>+-*/%(),.;", c) {
+				return nil, fmt.Errorf("unexpected %q in query", c)
+			}
+			tokens = append(tokens, sqlToken{'o', string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// sqlText renders tokens back to SQL, it names unaliased select items.
+func sqlText(tokens []sqlToken) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 {
+			prev := tokens[i-1]
+			tight := t.kind == 'o' && (t.text == ")" || t.text == "," || t.text == ".") ||
+				prev.kind == 'o' && (prev.text == "(" || prev.text == ".") ||
+				prev.kind == 'i' && t.kind == 'o' && t.text == "(" ||
+				prev.kind == 'o' && prev.text == "-" && (i == 1 || tokens[i-2].kind == 'o' && tokens[i-2].text != ")")
+			if !tight {
+				b.WriteString(" ")
+			}
+		}
+		switch t.kind {
+		case 's':
+			b.WriteString("'" + strings.ReplaceAll(t.text, "'", "''") + "'")
+		case 'q':
+			b.WriteString(`"` + strings.ReplaceAll(t.text, `"`, `""`) + `"`)
+		default:
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+type sqlItem struct {
+	tokens []sqlToken
+	alias  string
+}
+
+type sqlTable struct {
+	name  string
+	alias string
+}
+
+type sqlJoin struct {
+	table sqlTable
+	on    []sqlToken
+	using []string
+}
+
+type sqlOrder struct {
+	tokens     []sqlToken
+	descending bool
+}
+
+type sqlQuery struct {
+	distinct bool
+	items    []sqlItem
+	from     sqlTable
+	joins    []sqlJoin
+	where    []sqlToken
+	groupBy  [][]sqlToken
+	orderBy  []sqlOrder
+	limit    int
+}
+
+// sqlClauses are the keywords that end an expression.
+var sqlClauses = []string{"FROM", "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS", "ON", "USING"}
+
+type sqlParser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (p *sqlParser) peek() sqlToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return sqlToken{}
+}
+
+// accept consumes the next token if it is one of the keywords or operators.
+func (p *sqlParser) accept(words ...string) bool {
+	t := p.peek()
+	for _, w := range words {
+		if t.is(w) || t.kind == 'o' && t.text == w {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *sqlParser) expect(words ...string) error {
+	if !p.accept(words...) {
+		return p.unexpected(strings.Join(words, " or "))
+	}
+	return nil
+}
+
+func (p *sqlParser) unexpected(want string) error {
+	if p.pos >= len(p.tokens) {
+		return fmt.Errorf("expected %s at the end of the query", want)
+	}
+	return fmt.Errorf("expected %s before %s", want, sqlText(p.tokens[p.pos:min(p.pos+4, len(p.tokens))]))
+}
+
+func (p *sqlParser) atClause() bool {
+	t := p.peek()
+	if t.kind == 'o' && t.text == ";" {
+		return true
+	}
+	for _, kw := range sqlClauses {
+		if t.is(kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// expression returns the tokens up to the next clause keyword or, with
+// comma, the next comma outside parentheses.
+func (p *sqlParser) expression(comma bool) ([]sqlToken, error) {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.tokens) {
+		t := p.peek()
+		if depth == 0 && (p.atClause() || comma && t.text == "," && t.kind == 'o') {
+			break
+		}
+		if t.kind == 'o' && t.text == "(" {
+			depth++
+		}
+		if t.kind == 'o' && t.text == ")" {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.unexpected("an expression")
+	}
+	return p.tokens[start:p.pos], nil
+}
+
+// list parses comma separated expressions.
+func (p *sqlParser) list() ([][]sqlToken, error) {
+	items := make([][]sqlToken, 0)
+	for {
+		e, err := p.expression(true)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+		if !p.accept(",") {
+			return items, nil
+		}
+	}
+}
+
+func (p *sqlParser) table() (sqlTable, error) {
+	t := p.peek()
+	if !t.isIdentifier() {
+		return sqlTable{}, p.unexpected("a table name")
+	}
+	p.pos++
+	table := sqlTable{name: t.text, alias: t.text}
+	p.accept("AS")
+	if a := p.peek(); a.isIdentifier() && !p.atClause() {
+		table.alias = a.text
+		p.pos++
+	}
+	return table, nil
+}
+
+func parseSQL(query string) (*sqlQuery, error) {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{tokens: tokens}
+	q := &sqlQuery{limit: -1}
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	q.distinct = p.accept("DISTINCT")
+	items, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		n := len(item)
+		if n > 2 && item[n-2].is("AS") && item[n-1].isIdentifier() {
+			q.items = append(q.items, sqlItem{tokens: item[:n-2], alias: item[n-1].text})
+		} else {
+			q.items = append(q.items, sqlItem{tokens: item})
+		}
+	}
+
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	if q.from, err = p.table(); err != nil {
+		return nil, err
+	}
+	for {
+		if p.accept("LEFT", "RIGHT", "FULL", "CROSS") {
+			return nil, errors.New("only inner joins are supported")
+		}
+		if p.accept("INNER") {
+			if err := p.expect("JOIN"); err != nil {
+				return nil, err
+			}
+		} else if !p.accept("JOIN") {
+			break
+		}
+		join := sqlJoin{}
+		if join.table, err = p.table(); err != nil {
+			return nil, err
+		}
+		switch {
+		case p.accept("ON"):
+			if join.on, err = p.expression(false); err != nil {
+				return nil, err
+			}
+		case p.accept("USING"):
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			columns, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range columns {
+				if len(c) != 1 || !c[0].isIdentifier() {
+					return nil, fmt.Errorf("USING expects column names, not %s", sqlText(c))
+				}
+				join.using = append(join.using, c[0].text)
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.unexpected("ON or USING")
+		}
+		q.joins = append(q.joins, join)
+	}
+	if p.accept("WHERE") {
+		if q.where, err = p.expression(false); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		if q.groupBy, err = p.list(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().is("HAVING") {
+		return nil, errors.New("HAVING is not supported, filter the grouped result with where")
+	}
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		items, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			order := sqlOrder{tokens: item}
+			if n := len(item); n > 1 && (item[n-1].is("ASC") || item[n-1].is("DESC")) {
+				order = sqlOrder{tokens: item[:n-1], descending: item[n-1].is("DESC")}
+			}
+			q.orderBy = append(q.orderBy, order)
+		}
+	}
+	if p.accept("LIMIT") {
+		t := p.peek()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != 'n' || err != nil || n < 0 {
+			return nil, p.unexpected("a row count")
+		}
+		p.pos++
+		q.limit = n
+	}
+	p.accept(";")
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s", sqlText(p.tokens[p.pos:]))
+	}
+	return q, nil
+}
+
+// sqlColumn is a column a query can refer to: column name of table, which
+// is the column frame of the DataFrame being built.
+type sqlColumn struct {
+	table  string
+	column string
+	frame  string
+}
+
+type sqlScope []sqlColumn
+
+// resolve returns the DataFrame column of a, possibly qualified, column.
+func (s sqlScope) resolve(table, column string) (string, error) {
+	found := ""
+	for _, c := range s {
+		if c.column != column || table != "" && c.table != table {
+			continue
+		}
+		if found != "" && found != c.frame {
+			return "", fmt.Errorf("column %s is ambiguous, qualify it with its table", column)
+		}
+		found = c.frame
+	}
+	if found == "" {
+		if table != "" {
+			column = table + "." + column
+		}
+		return "", fmt.Errorf("unknown column %s", column)
+	}
+	return found, nil
+}
+
+// columnRef returns the table and column of tokens that are a plain column
+// reference.
+func columnRef(tokens []sqlToken) (string, string, bool) {
+	switch {
+	case len(tokens) == 1 && tokens[0].isIdentifier() && !tokens[0].is("NULL") && !tokens[0].is("TRUE") && !tokens[0].is("FALSE"):
+		return "", tokens[0].text, true
+	case len(tokens) == 3 && tokens[0].isIdentifier() && tokens[1].text == "." && tokens[2].isIdentifier():
+		return tokens[0].text, tokens[2].text, true
+	}
+	return "", "", false
+}
+
+// likePattern turns a LIKE pattern into a regular expression.
+func likePattern(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// translate turns a SQL expression into an expr expression. Row wise the
+// columns are values, as in Where, otherwise they are columns indexed by
+// #index in a map, as in Transform. It also returns the columns used.
+func translate(tokens []sqlToken, resolve func(table, column string) (string, error), rowWise bool) (string, []string, error) {
+	out := make([]string, 0, len(tokens))
+	used := make([]string, 0)
+	closers := make([]string, 0)
+	column := func(table, name string) error {
+		c, err := resolve(table, name)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(used, c) {
+			used = append(used, c)
+		}
+		if rowWise {
+			out = append(out, c)
+		} else {
+			out = append(out, c+"[#index]")
+		}
+		return nil
+	}
+	// list opens the ( after IN as a [
+	list := func(i int) error {
+		if i+1 >= len(tokens) || tokens[i+1].text != "(" {
+			return errors.New("IN expects a list in parentheses")
+		}
+		closers = append(closers, "]")
+		out = append(out, "[")
+		return nil
+	}
+	like := func(i int) error {
+		if i+1 >= len(tokens) || tokens[i+1].kind != 's' {
+			return errors.New("LIKE expects a string pattern")
+		}
+		out = append(out, strconv.Quote(likePattern(tokens[i+1].text)))
+		return nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		next := sqlToken{}
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		switch {
+		case t.is("AND"), t.is("OR"):
+			out = append(out, strings.ToLower(t.text))
+		case t.is("TRUE"), t.is("FALSE"):
+			out = append(out, strings.ToLower(t.text))
+		case t.is("NULL"):
+			out = append(out, "nil")
+		case t.is("NOT") && next.is("IN"):
+			out = append(out, "not in")
+			if err := list(i + 1); err != nil {
+				return "", nil, err
+			}
+			i += 2
+		case t.is("NOT") && next.is("LIKE"):
+			out = append(out, "not matches")
+			if err := like(i + 1); err != nil {
+				return "", nil, err
+			}
+			i += 2
+		case t.is("NOT"):
+			out = append(out, "not")
+		case t.is("IN"):
+			out = append(out, "in")
+			if err := list(i); err != nil {
+				return "", nil, err
+			}
+			i++
+		case t.is("LIKE"):
+			out = append(out, "matches")
+			if err := like(i); err != nil {
+				return "", nil, err
+			}
+			i++
+		case t.is("BETWEEN"):
+			return "", nil, errors.New("BETWEEN is not supported, compare with >= and <=")
+		case t.is("IS"):
+			op := "=="
+			if next.is("NOT") {
+				op = "!="
+				i++
+			}
+			if i+1 >= len(tokens) || !tokens[i+1].is("NULL") {
+				return "", nil, errors.New("IS expects NULL or NOT NULL")
+			}
+			out = append(out, op, "nil")
+			i++
+		case t.kind == 'i' && next.text == "(" && next.kind == 'o':
+			// functions are the expr builtins, FUNC is written func
+			name := t.text
+			if name == strings.ToUpper(name) {
+				name = strings.ToLower(name)
+			}
+			out = append(out, name)
+		case t.isIdentifier() && next.text == "." && i+2 < len(tokens) && tokens[i+2].isIdentifier():
+			if err := column(t.text, tokens[i+2].text); err != nil {
+				return "", nil, err
+			}
+			i += 2
+		case t.isIdentifier():
+			if err := column("", t.text); err != nil {
+				return "", nil, err
+			}
+		case t.kind == 'n':
+			out = append(out, t.text)
+		case t.kind == 's':
+			out = append(out, strconv.Quote(t.text))
+		case t.text == "=":
+			out = append(out, "==")
+		case t.text == "<>":
+			out = append(out, "!=")
+		case t.text == "||":
+			out = append(out, "+")
+		case t.text == "(":
+			closers = append(closers, ")")
+			out = append(out, "(")
+		case t.text == ")":
+			if len(closers) == 0 {
+				return "", nil, errors.New("unbalanced parentheses")
+			}
+			out = append(out, closers[len(closers)-1])
+			closers = closers[:len(closers)-1]
+		default:
+			out = append(out, t.text)
+		}
+	}
+	if len(closers) > 0 {
+		return "", nil, errors.New("unbalanced parentheses")
+	}
+	return strings.Join(out, " "), used, nil
+}
+
+// sqlNullResults are the operators that fail on nil in expr and what they
+// return on NULL in a query instead: comparisons are false and arithmetic
+// is NULL.
+var sqlNullResults = map[string]string{
+	"<": "false", "<=": "false", ">": "false", ">=": "false",
+	"matches": "false", "contains": "false", "startsWith": "false", "endsWith": "false",
+	"+": "nil", "-": "nil", "*": "nil", "/": "nil", "%": "nil", "**": "nil", "^": "nil",
+}
+
+// rawNode prints as its text, it replaces a rewritten part of an expression.
+type rawNode struct {
+	ast.NilNode
+	text string
+}
+
+func (n *rawNode) String() string {
+	return n.text
+}
+
+// nullVisitor guards the operators in sqlNullResults and negation against
+// nil operands. Negation is written as a subtraction, which is defined on
+// Decimal values.
+type nullVisitor struct {
+	n int
+}
+
+func (v *nullVisitor) Visit(node *ast.Node) {
+	if u, ok := (*node).(*ast.UnaryNode); ok && u.Operator == "-" {
+		if _, literal := constantValue(u); !literal {
+			v.n++
+			x := fmt.Sprintf("__x%d", v.n)
+			*node = &rawNode{text: fmt.Sprintf("(let %s = %s; %s == nil ? nil : 0 - %s)", x, u.Node, x, x)}
+		}
+		return
+	}
+	b, ok := (*node).(*ast.BinaryNode)
+	if !ok {
+		return
+	}
+	result, guarded := sqlNullResults[b.Operator]
+	if !guarded {
+		return
+	}
+	v.n++
+	l, r := fmt.Sprintf("__l%d", v.n), fmt.Sprintf("__r%d", v.n)
+	*node = &rawNode{text: fmt.Sprintf("(let %s = %s; let %s = %s; %s == nil or %s == nil ? %s : %s %s %s)",
+		l, b.Left, r, b.Right, l, r, result, l, b.Operator, r)}
+}
+
+// nullSafe rewrites an expression so that NULL behaves as in SQL instead of
+// failing the query.
+func nullSafe(src string) (string, error) {
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	ast.Walk(&tree.Node, &nullVisitor{})
+	return tree.Node.String(), nil
+}
+
+// sqlAggregates maps the SQL aggregate functions to GroupBy functions.
+var sqlAggregates = map[string]string{
+	"COUNT": "count",
+	"SUM":   "sum",
+	"AVG":   "mean",
+	"MEAN":  "mean",
+	"MIN":   "min",
+	"MAX":   "max",
+	"FIRST": "first",
+	"LAST":  "last",
+}
+
+// aggregate returns the GroupBy function and the argument of an item that
+// is an aggregate call.
+func aggregate(tokens []sqlToken) (string, []sqlToken, bool) {
+	n := len(tokens)
+	if n < 4 || tokens[0].kind != 'i' || tokens[1].text != "(" || tokens[n-1].text != ")" {
+		return "", nil, false
+	}
+	function, ok := sqlAggregates[strings.ToUpper(tokens[0].text)]
+	if !ok {
+		return "", nil, false
+	}
+	depth := 0
+	for _, t := range tokens[1 : n-1] {
+		if t.kind == 'o' && t.text == "(" {
+			depth++
+		}
+		if t.kind == 'o' && t.text == ")" {
+			depth--
+		}
+		if depth == 0 {
+			// the call ends before the last token, as in max(a) - min(a)
+			return "", nil, false
+		}
+	}
+	return function, tokens[2 : n-1], true
+}
+
+// compute adds a column holding a column wise expression with Transform.
+func compute(d *DataFrame, name, expression string, used []string) error {
+	if len(used) > 0 {
+		expression = fmt.Sprintf("map(%s, %s)", used[0], expression)
+	}
+	expression, err := nullSafe(expression)
+	if err != nil {
+		return err
+	}
+	return d.transform(context.Background(), fmt.Sprintf("let %s = %s; %s", name, expression, name))
+}
+
+// filter runs a row wise condition with Where.
+func filter(d *DataFrame, condition string) (*DataFrame, error) {
+	condition, err := nullSafe(condition)
+	if err != nil {
+		return nil, err
+	}
+	return d.where(context.Background(), condition)
+}
+
+// copyFrame returns a DataFrame sharing the values of d that Transform can
+// add and replace columns of.
+func copyFrame(d *DataFrame) *DataFrame {
+	data := make([]*Data, len(d.Schema.Fields))
+	for i := range data {
+		column := d.Data.getColumn(i)
+		data[i] = &column
+	}
+	return NewDataFrameWithArgs(slices.Clone(d.Schema.Fields), data)
+}
+
+// sqlJoinFrame joins the right table to the frame built so far. The right
+// key columns are renamed to the left ones and the other right columns that
+// clash with the frame are prefixed with the table alias.
+func sqlJoinFrame(d *DataFrame, scope sqlScope, right *DataFrame, join sqlJoin) (*DataFrame, sqlScope, error) {
+	alias := join.table.alias
+	rightColumns := right.GetFieldNames()
+	// keys maps right columns to left frame columns
+	keys := make(map[string]string)
+	if join.using != nil {
+		for _, c := range join.using {
+			left, err := scope.resolve("", c)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !slices.Contains(rightColumns, c) {
+				return nil, nil, fmt.Errorf("unknown column %s.%s", alias, c)
+			}
+			keys[c] = left
+		}
+	} else {
+		errOn := fmt.Errorf("JOIN %s ON supports equalities of columns joined with AND", join.table.name)
+		condition := join.on
+		for len(condition) > 0 {
+			end := slices.IndexFunc(condition, func(t sqlToken) bool { return t.is("AND") })
+			if end < 0 {
+				end = len(condition)
+			}
+			equality := condition[:end]
+			condition = condition[min(end+1, len(condition)):]
+			eq := slices.IndexFunc(equality, func(t sqlToken) bool { return t.kind == 'o' && t.text == "=" })
+			if eq < 0 {
+				return nil, nil, errOn
+			}
+			lt, lc, lok := columnRef(equality[:eq])
+			rt, rc, rok := columnRef(equality[eq+1:])
+			if !lok || !rok {
+				return nil, nil, errOn
+			}
+			// the side qualified with the alias, or only found on the
+			// right, is the right column
+			onRight := func(table, column string) bool {
+				if table != "" {
+					return table == alias
+				}
+				_, err := scope.resolve("", column)
+				return err != nil && slices.Contains(rightColumns, column)
+			}
+			if onRight(lt, lc) {
+				lt, lc, rt, rc = rt, rc, lt, lc
+			}
+			if !onRight(rt, rc) || !slices.Contains(rightColumns, rc) {
+				return nil, nil, fmt.Errorf("JOIN %s ON %s does not compare a column of %s", join.table.name, sqlText(equality), alias)
+			}
+			left, err := scope.resolve(lt, lc)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys[rc] = left
+		}
+	}
+
+	r := copyFrame(right)
+	names := d.GetFieldNames()
+	keyNames := make([]string, 0, len(keys))
+	for i := range r.Schema.Fields {
+		f := &r.Schema.Fields[i]
+		column := f.FieldName
+		if left, isKey := keys[column]; isKey {
+			f.FieldName = left
+			keyNames = append(keyNames, left)
+		} else {
+			name := column
+			for n := 2; slices.Contains(names, name); n++ {
+				name = alias + "_" + column
+				if n > 2 {
+					name += strconv.Itoa(n - 1)
+				}
+			}
+			f.FieldName = name
+			names = append(names, name)
+		}
+		scope = append(scope, sqlColumn{table: alias, column: column, frame: f.FieldName})
+	}
+	result, err := d.join(context.Background(), r, keyNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, scope, nil
+}
+
+// uniqueNames names the columns of the result that have the same name, like
+// a.name and b.name of a self join, after their table as in a_name and
+// b_name. Other names that are not unique need an alias.
+func uniqueNames(names, qualifiers []string) error {
+	counts := make(map[string]int)
+	for _, name := range names {
+		counts[name]++
+	}
+	for i, name := range names {
+		if counts[name] > 1 && qualifiers[i] != "" {
+			names[i] = qualifiers[i] + "_" + name
+		}
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("the result has two columns named %s, name one with AS", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func runSQL(query string, tables map[string]*DataFrame, from *DataFrame) (*DataFrame, error) {
+	q, err := parseSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	table := func(name string) (*DataFrame, error) {
+		if d, found := tables[name]; found {
+			return d, nil
+		}
+		return nil, fmt.Errorf("unknown table %s", name)
+	}
+	if from == nil {
+		if from, err = table(q.from.name); err != nil {
+			return nil, err
+		}
+	} else {
+		tables = maps.Clone(tables)
+		if tables == nil {
+			tables = make(map[string]*DataFrame)
+		}
+		tables[q.from.name] = from
+	}
+
+	d := copyFrame(from)
+	scope := make(sqlScope, 0)
+	for _, name := range d.GetFieldNames() {
+		scope = append(scope, sqlColumn{table: q.from.alias, column: name, frame: name})
+	}
+	for _, join := range q.joins {
+		right, err := table(join.table.name)
+		if err != nil {
+			return nil, err
+		}
+		if d, scope, err = sqlJoinFrame(d, scope, right, join); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.where != nil {
+		condition, _, err := translate(q.where, scope.resolve, true)
+		if err != nil {
+			return nil, fmt.Errorf("WHERE: %w", err)
+		}
+		if d, err = filter(d, condition); err != nil {
+			return nil, fmt.Errorf("WHERE: %w", err)
+		}
+	}
+
+	// Every select item becomes a column of d, columns holds its name in d
+	// and names its name in the result. qualifiers holds the table of the
+	// items that are columns named after their column, see uniqueNames.
+	columns := make([]string, 0)
+	names := make([]string, 0)
+	qualifiers := make([]string, 0)
+	temporary := 0
+	temp := func() string {
+		temporary++
+		return fmt.Sprintf("__sql_%d", temporary)
+	}
+	grouped := q.groupBy != nil
+	for _, item := range q.items {
+		if _, _, ok := aggregate(item.tokens); ok {
+			grouped = true
+		}
+	}
+
+	if !grouped {
+		for _, item := range q.items {
+			if len(item.tokens) == 1 && item.tokens[0].text == "*" {
+				for _, c := range scope {
+					if !slices.Contains(columns, c.frame) {
+						columns = append(columns, c.frame)
+						names = append(names, c.column)
+						qualifiers = append(qualifiers, c.table)
+					}
+				}
+				continue
+			}
+			name := item.alias
+			if table, column, ok := columnRef(item.tokens); ok {
+				c, err := scope.resolve(table, column)
+				if err != nil {
+					return nil, err
+				}
+				qualifier := ""
+				if name == "" {
+					name, qualifier = column, table
+				}
+				columns = append(columns, c)
+				names = append(names, name)
+				qualifiers = append(qualifiers, qualifier)
+				continue
+			}
+			expression, used, err := translate(item.tokens, scope.resolve, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", sqlText(item.tokens), err)
+			}
+			c := temp()
+			if err := compute(d, c, expression, used); err != nil {
+				return nil, fmt.Errorf("%s: %w", sqlText(item.tokens), err)
+			}
+			if name == "" {
+				name = sqlText(item.tokens)
+			}
+			columns = append(columns, c)
+			names = append(names, name)
+			qualifiers = append(qualifiers, "")
+		}
+	} else {
+		keys := make([]string, 0, len(q.groupBy))
+		for _, key := range q.groupBy {
+			table, column, ok := columnRef(key)
+			if !ok {
+				return nil, fmt.Errorf("GROUP BY %s: group by columns, not expressions", sqlText(key))
+			}
+			c, err := scope.resolve(table, column)
+			if err != nil {
+				return nil, fmt.Errorf("GROUP BY: %w", err)
+			}
+			keys = append(keys, c)
+		}
+		// After grouping only the keys can be referred to
+		keyScope := make(sqlScope, 0)
+		for _, c := range scope {
+			if slices.Contains(keys, c.frame) {
+				keyScope = append(keyScope, c)
+			}
+		}
+		aggs := make([]Aggregation, 0)
+		deferred := make(map[string]sqlItem)
+		for _, item := range q.items {
+			function, argument, isAggregate := aggregate(item.tokens)
+			name, qualifier := item.alias, ""
+			switch {
+			case isAggregate:
+				agg := Aggregation{Function: function}
+				if table, column, ok := columnRef(argument); ok {
+					if agg.Column, err = scope.resolve(table, column); err != nil {
+						return nil, err
+					}
+					agg.Alias = strings.ToLower(item.tokens[0].text) + "_" + column
+				} else if len(argument) == 1 && argument[0].text == "*" && function == "count" {
+					agg.Column, agg.Alias = "*", "count"
+				} else {
+					expression, used, err := translate(argument, scope.resolve, false)
+					if err != nil {
+						return nil, fmt.Errorf("%s: %w", sqlText(item.tokens), err)
+					}
+					agg.Column = temp()
+					if err := compute(d, agg.Column, expression, used); err != nil {
+						return nil, fmt.Errorf("%s: %w", sqlText(item.tokens), err)
+					}
+					agg.Alias = sqlText(item.tokens)
+				}
+				if name != "" {
+					agg.Alias = name
+				}
+				name = agg.Alias
+				agg.Alias = temp()
+				aggs = append(aggs, agg)
+				columns = append(columns, agg.Alias)
+			case len(item.tokens) == 1 && item.tokens[0].text == "*":
+				return nil, errors.New("SELECT * can not be grouped")
+			default:
+				if table, column, ok := columnRef(item.tokens); ok {
+					c, err := keyScope.resolve(table, column)
+					if err != nil {
+						return nil, fmt.Errorf("%s must be in GROUP BY or an aggregate", sqlText(item.tokens))
+					}
+					if name == "" {
+						name, qualifier = column, table
+					}
+					columns = append(columns, c)
+					break
+				}
+				c := temp()
+				deferred[c] = item
+				columns = append(columns, c)
+				if name == "" {
+					name = sqlText(item.tokens)
+				}
+			}
+			names = append(names, name)
+			qualifiers = append(qualifiers, qualifier)
+		}
+		if d, err = d.groupBy(context.Background(), keys, aggs...); err != nil {
+			return nil, err
+		}
+		for c, item := range deferred {
+			expression, used, err := translate(item.tokens, keyScope.resolve, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be in GROUP BY or an aggregate: %w", sqlText(item.tokens), err)
+			}
+			if err := compute(d, c, expression, used); err != nil {
+				return nil, fmt.Errorf("%s: %w", sqlText(item.tokens), err)
+			}
+		}
+		scope = keyScope
+	}
+
+	if err := uniqueNames(names, qualifiers); err != nil {
+		return nil, err
+	}
+
+	if q.orderBy != nil {
+		keys := make([]SortKey, 0, len(q.orderBy))
+		for _, order := range q.orderBy {
+			key := SortKey{Descending: order.descending}
+			table, column, isColumn := columnRef(order.tokens)
+			if n, err := strconv.Atoi(order.tokens[0].text); err == nil && len(order.tokens) == 1 {
+				if n < 1 || n > len(columns) {
+					return nil, fmt.Errorf("ORDER BY %d is not a select item", n)
+				}
+				key.Column = columns[n-1]
+			} else if i := slices.Index(names, column); isColumn && table == "" && i >= 0 {
+				key.Column = columns[i]
+			} else if isColumn {
+				if key.Column, err = scope.resolve(table, column); err != nil {
+					return nil, fmt.Errorf("ORDER BY: %w", err)
+				}
+			} else if i := slices.Index(names, sqlText(order.tokens)); i >= 0 {
+				key.Column = columns[i]
+			} else {
+				expression, used, err := translate(order.tokens, scope.resolve, false)
+				if err != nil {
+					return nil, fmt.Errorf("ORDER BY %s: %w", sqlText(order.tokens), err)
+				}
+				key.Column = temp()
+				if err := compute(d, key.Column, expression, used); err != nil {
+					return nil, fmt.Errorf("ORDER BY %s: %w", sqlText(order.tokens), err)
+				}
+			}
+			keys = append(keys, key)
+		}
+		if d, err = d.Sort(keys...); err != nil {
+			return nil, err
+		}
+	}
+
+	if d, err = d.project(columns...); err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		d.Schema.Fields[i].FieldName = name
+	}
+	if q.distinct {
+		if d, err = d.DistinctRows(); err != nil {
+			return nil, err
+		}
+	}
+	if q.limit >= 0 {
+		d = d.Head(q.limit)
+	}
+	return d, nil
+}
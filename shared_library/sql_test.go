@@ -0,0 +1,106 @@
+package sharedlibrary
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func sqlTables(t *testing.T) map[string]*DataFrame {
+	t.Helper()
+	return map[string]*DataFrame{
+		"people": newTestFrame(t, "id:int,amount:decimal(10,2)", []string{"id", "name", "cid", "amount", "boss"},
+			[]string{"1", "anna", "1", "10.50", ""},
+			[]string{"2", "bo", "2", "", "1"},
+			[]string{"3", "cid", "1", "4.25", "1"},
+			[]string{"4", "dana", "3", "7.00", "2"},
+		),
+		"countries": newTestFrame(t, "", []string{"cid", "country"},
+			[]string{"1", "se"},
+			[]string{"2", "no"},
+		),
+	}
+}
+
+func TestSQL(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{
+			"SELECT name, amount * 2 AS double FROM people WHERE amount > 5 ORDER BY amount DESC",
+			[]string{"name,double", "anna,21.00", "dana,14.00"},
+		},
+		{
+			"SELECT id FROM people WHERE amount IS NULL OR name LIKE 'd%'",
+			[]string{"id", "2", "4"},
+		},
+		{
+			"SELECT cid, COUNT(*) AS n, SUM(amount) FROM people GROUP BY cid ORDER BY cid",
+			[]string{"cid,n,sum_amount", "1,2,14.75", "2,1,<nil>", "3,1,7.00"},
+		},
+		{
+			"SELECT p.name, c.country FROM people p JOIN countries c ON p.cid = c.cid ORDER BY 1",
+			[]string{"name,country", "anna,se", "bo,no", "cid,se"},
+		},
+		{
+			"SELECT name, country FROM people JOIN countries USING (cid) WHERE country = 'se' LIMIT 1",
+			[]string{"name,country", "anna,se"},
+		},
+		{
+			"SELECT DISTINCT cid FROM people ORDER BY cid DESC",
+			[]string{"cid", "3", "2", "1"},
+		},
+		{
+			"SELECT e.name, m.name FROM people e JOIN people m ON e.boss = m.id ORDER BY e.id",
+			[]string{"e_name,m_name", "bo,anna", "cid,anna", "dana,bo"},
+		},
+	}
+	for _, tt := range tests {
+		d, err := SQL(tt.query, sqlTables(t))
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := rows(t, d); !slices.Equal(got, tt.want) {
+			t.Errorf("%s =\n%q\nwant\n%q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDataFrameSQLSelfJoin(t *testing.T) {
+	people := sqlTables(t)["people"]
+	// the receiver is the table in FROM under any name, joins find it there
+	d, err := people.SQL("SELECT a.name, b.name FROM input a JOIN input b ON a.boss = b.id WHERE a.id = 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rows(t, d), []string{"a_name,b_name", "dana,bo"}; !slices.Equal(got, want) {
+		t.Errorf("self join = %q, want %q", got, want)
+	}
+	if _, err := people.SQL("SELECT * FROM input JOIN other USING (id)"); err == nil || !strings.Contains(err.Error(), "unknown table other") {
+		t.Errorf("join with an unknown table: %v", err)
+	}
+}
+
+func TestSQLErrors(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT name, name FROM people":       "two columns named name",
+		"SELECT name, id AS name FROM people": "two columns named name",
+		"SELECT missing FROM people":          "unknown column missing",
+		"SELECT cid FROM people p JOIN countries c USING (cid) JOIN countries d USING (cid)": "",
+		"SELECT name, COUNT(*) FROM people GROUP BY cid":                                     "must be in GROUP BY",
+		"SELECT * FROM nowhere":                                             "unknown table nowhere",
+		"SELECT name FROM people WHERE name = 'unclosed":                    "unterminated",
+		"SELECT cid, COUNT(*) FROM people GROUP BY cid HAVING COUNT(*) > 1": "HAVING",
+		"SELECT name FROM people WHERE cid BETWEEN 1 AND 3":                 "BETWEEN is not supported",
+	} {
+		_, err := SQL(query, sqlTables(t))
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%s: %v", query, err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+			t.Errorf("%s: error %v, want %q", query, err, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package sharedlibrary
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the spans of the operators and of the operations on
+// DataFrames. They go to the tracer provider set with otel.SetTracerProvider,
+// which StartTracing does for the operators. Without one nothing is recorded.
+var tracer = otel.Tracer("github.com/magpierre/operators/shared_library")
+
+// startSpan starts the span of an operation on a DataFrame.
+func startSpan(ctx context.Context, name string, d *DataFrame, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.Int("rows.in", d.GetNumberOfRows()), attribute.Int("columns.in", d.GetNumberOfColumns()))
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span with the size of the result, or with the error.
+func endSpan(span trace.Span, result *DataFrame, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if result != nil {
+		span.SetAttributes(attribute.Int("rows.out", result.GetNumberOfRows()), attribute.Int("columns.out", result.GetNumberOfColumns()))
+	}
+	span.End()
+}
+
+// TraceParentEnv is the environment variable with the W3C trace context an
+// operator continues when its input carries none.
+const TraceParentEnv = "TRACEPARENT"
+
+// TraceParent returns the W3C traceparent of the span in ctx, empty when
+// there is none.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// ContextWithTraceParent returns ctx with the span of a W3C traceparent as
+// the parent of the spans started from it.
+func ContextWithTraceParent(ctx context.Context, traceparent string) context.Context {
+	carrier := propagation.MapCarrier{"traceparent": traceparent}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// Trace exporters of StartTracing. The stdout exporter writes its JSON to
+// stderr, stdout carries the output of the operators. The OTLP file exporter
+// appends to the file after the prefix, one OTLP/JSON request per line like
+// the file exporter of the OpenTelemetry collector writes.
+const (
+	StdoutExporter   = "stdout"
+	OTLPFileExporter = "otlp-file:"
+)
+
+// StartTracing sets a tracer provider that exports to "stdout" or to
+// "otlp-file:path". Spans without a parent get traceID, or a new trace id
+// when it is empty. The returned function flushes the spans and stops it.
+func StartTracing(exporter, traceID string) (func(context.Context) error, error) {
+	var e sdktrace.SpanExporter
+	switch {
+	case exporter == StdoutExporter:
+		var err error
+		if e, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr)); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(exporter, OTLPFileExporter) && len(exporter) > len(OTLPFileExporter):
+		e = &otlpFileExporter{path: strings.TrimPrefix(exporter, OTLPFileExporter)}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, use stdout or otlp-file:path", exporter)
+	}
+	ids := &traceIDs{}
+	if traceID != "" {
+		var err error
+		if ids.traceID, err = trace.TraceIDFromHex(traceID); err != nil {
+			return nil, fmt.Errorf("trace id %q is not 32 hex digits", traceID)
+		}
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(e),
+		sdktrace.WithIDGenerator(ids),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "operators"))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// traceIDs generates random ids, the trace id of spans without a parent is
+// fixed when one was given.
+type traceIDs struct {
+	traceID trace.TraceID
+}
+
+func (g *traceIDs) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	id := g.traceID
+	if !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id, g.NewSpanID(ctx, id)
+}
+
+func (g *traceIDs) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	var id trace.SpanID
+	rand.Read(id[:])
+	return id
+}
+
+// otlpFileExporter appends the spans of every export to a file as an
+// OTLP/JSON ExportTraceServiceRequest on one line, in a single write so
+// that the operators of a pipeline can share the file.
+type otlpFileExporter struct {
+	path string
+	mu   sync.Mutex
+}
+
+// The OTLP/JSON encoding: ids are hex, 64 bit integers are strings and
+// enums are numbers.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Links             []otlpLink     `json:"links,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpLink struct {
+		TraceID    string         `json:"traceId"`
+		SpanID     string         `json:"spanId"`
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string     `json:"stringValue,omitempty"`
+		BoolValue   *bool       `json:"boolValue,omitempty"`
+		IntValue    *string     `json:"intValue,omitempty"`
+		DoubleValue *float64    `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpValues `json:"arrayValue,omitempty"`
+	}
+	otlpValues struct {
+		Values []otlpValue `json:"values"`
+	}
+)
+
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, len(attrs))
+	for i, a := range attrs {
+		kvs[i] = otlpKeyValue{Key: string(a.Key), Value: otlpAttributeValue(a.Value)}
+	}
+	return kvs
+}
+
+func otlpAttributeValue(v attribute.Value) otlpValue {
+	values := func(n int, at func(i int) otlpValue) otlpValue {
+		array := &otlpValues{Values: make([]otlpValue, n)}
+		for i := range array.Values {
+			array.Values[i] = at(i)
+		}
+		return otlpValue{ArrayValue: array}
+	}
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpValue{BoolValue: &b}
+	case attribute.INT64:
+		s := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpValue{IntValue: &s}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		s := v.AsBoolSlice()
+		return values(len(s), func(i int) otlpValue { return otlpAttributeValue(attribute.BoolValue(s[i])) })
+	case attribute.INT64SLICE:
+		s := v.AsInt64Slice()
+		return values(len(s), func(i int) otlpValue { return otlpAttributeValue(attribute.Int64Value(s[i])) })
+	case attribute.FLOAT64SLICE:
+		s := v.AsFloat64Slice()
+		return values(len(s), func(i int) otlpValue { return otlpAttributeValue(attribute.Float64Value(s[i])) })
+	case attribute.STRINGSLICE:
+		s := v.AsStringSlice()
+		return values(len(s), func(i int) otlpValue { return otlpAttributeValue(attribute.StringValue(s[i])) })
+	}
+	s := v.Emit()
+	return otlpValue{StringValue: &s}
+}
+
+// otlpStatusCode maps a span status to OTLP, where Ok and Error are
+// numbered the other way around.
+func otlpStatusCode(c codes.Code) int {
+	switch c {
+	case codes.Ok:
+		return 1
+	case codes.Error:
+		return 2
+	}
+	return 0
+}
+
+func nanos(t interface{ UnixNano() int64 }) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (e *otlpFileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	request := otlpRequest{}
+	resources := make(map[*resource.Resource]int)
+	for _, s := range spans {
+		r, found := resources[s.Resource()]
+		if !found {
+			r = len(request.ResourceSpans)
+			resources[s.Resource()] = r
+			request.ResourceSpans = append(request.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: otlpAttributes(s.Resource().Attributes())},
+			})
+		}
+		rs := &request.ResourceSpans[r]
+		scope := s.InstrumentationScope()
+		ss := slices.IndexFunc(rs.ScopeSpans, func(ss otlpScopeSpans) bool {
+			return ss.Scope.Name == scope.Name && ss.Scope.Version == scope.Version
+		})
+		if ss < 0 {
+			ss = len(rs.ScopeSpans)
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{Scope: otlpScope{Name: scope.Name, Version: scope.Version}})
+		}
+
+		span := otlpSpan{
+			TraceID:           s.SpanContext().TraceID().String(),
+			SpanID:            s.SpanContext().SpanID().String(),
+			Name:              s.Name(),
+			Kind:              int(s.SpanKind()),
+			StartTimeUnixNano: nanos(s.StartTime()),
+			EndTimeUnixNano:   nanos(s.EndTime()),
+			Attributes:        otlpAttributes(s.Attributes()),
+			Status:            otlpStatus{Code: otlpStatusCode(s.Status().Code), Message: s.Status().Description},
+		}
+		if s.Parent().SpanID().IsValid() {
+			span.ParentSpanID = s.Parent().SpanID().String()
+		}
+		for _, ev := range s.Events() {
+			span.Events = append(span.Events, otlpEvent{TimeUnixNano: nanos(ev.Time), Name: ev.Name, Attributes: otlpAttributes(ev.Attributes)})
+		}
+		for _, l := range s.Links() {
+			span.Links = append(span.Links, otlpLink{TraceID: l.SpanContext.TraceID().String(), SpanID: l.SpanContext.SpanID().String(), Attributes: otlpAttributes(l.Attributes)})
+		}
+		rs.ScopeSpans[ss].Spans = append(rs.ScopeSpans[ss].Spans, span)
+	}
+
+	b, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	file, err := os.OpenFile(e.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (e *otlpFileExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package sharedlibrary
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// readSpans returns the spans of the OTLP/JSON lines of a file.
+func readSpans(t *testing.T, path string) []otlpSpan {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	spans := make([]otlpSpan, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var request otlpRequest
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			t.Fatalf("%s: %v", scanner.Text(), err)
+		}
+		for _, rs := range request.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestTraceParent(t *testing.T) {
+	if got := TraceParent(context.Background()); got != "" {
+		t.Errorf("TraceParent without a span = %q, want empty", got)
+	}
+	ctx := ContextWithTraceParent(context.Background(), testTraceParent)
+	if got := TraceParent(ctx); got != testTraceParent {
+		t.Errorf("TraceParent = %q, want %q", got, testTraceParent)
+	}
+	if got := TraceParent(ContextWithTraceParent(context.Background(), "not a traceparent")); got != "" {
+		t.Errorf("TraceParent of an invalid traceparent = %q, want empty", got)
+	}
+}
+
+func TestStartTracingErrors(t *testing.T) {
+	for _, tt := range []struct{ exporter, traceID, err string }{
+		{"jaeger", "", `unknown trace exporter "jaeger"`},
+		{"otlp-file:", "", `unknown trace exporter "otlp-file:"`},
+		{"stdout", "abc", `trace id "abc" is not 32 hex digits`},
+	} {
+		if _, err := StartTracing(tt.exporter, tt.traceID); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("StartTracing(%q, %q) = %v, want %s", tt.exporter, tt.traceID, err, tt.err)
+		}
+	}
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(&otlpFileExporter{path: path}))
+	defer provider.Shutdown(context.Background())
+	tracer := provider.Tracer("test")
+
+	ctx, parent := tracer.Start(context.Background(), "Where")
+	_, child := tracer.Start(ctx, "Transform")
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	parent.End()
+
+	spans := readSpans(t, path)
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	transform, where := spans[0], spans[1]
+	if transform.Name != "Transform" || where.Name != "Where" {
+		t.Fatalf("spans %s, %s", transform.Name, where.Name)
+	}
+	if transform.TraceID != where.TraceID || transform.ParentSpanID != where.SpanID || where.ParentSpanID != "" {
+		t.Errorf("Transform %+v is not the child of Where %+v", transform, where)
+	}
+	// OTLP numbers Error 2 where the Go API numbers it 1
+	if transform.Status.Code != 2 || transform.Status.Message != "boom" || where.Status.Code != 0 {
+		t.Errorf("status Transform %+v, Where %+v", transform.Status, where.Status)
+	}
+	if transform.StartTimeUnixNano == "" || transform.EndTimeUnixNano < transform.StartTimeUnixNano {
+		t.Errorf("times %s to %s", transform.StartTimeUnixNano, transform.EndTimeUnixNano)
+	}
+}
+
+func TestOperatorTrace(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.gob")
+	file, err := os.Create(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := NewFrameWriter(file)
+	if err := frames.Write(newTestFrame(t, "", []string{"name"}, []string{"anna"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := errors.Join(frames.Close(), file.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the operator continues the trace of the process that started it
+	t.Setenv(TraceParentEnv, testTraceParent)
+	spans := filepath.Join(dir, "spans.jsonl")
+	out, err := runWithStdout(t, &upperOperator{}, "-input", input, "-col", "name", "-trace", OTLPFileExporter+spans)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var upper *otlpSpan
+	for _, s := range readSpans(t, spans) {
+		if s.Name == "upper" {
+			upper = &s
+		}
+	}
+	if upper == nil {
+		t.Fatal("no span of the operator")
+	}
+	if upper.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || upper.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("operator span in trace %s under %s, want the trace of %s", upper.TraceID, upper.ParentSpanID, testTraceParent)
+	}
+	attributes := make(map[string]string)
+	for _, kv := range upper.Attributes {
+		if kv.Value.IntValue != nil {
+			attributes[kv.Key] = *kv.Value.IntValue
+		}
+	}
+	if attributes["rows.in"] != "1" || attributes["rows.out"] != "1" {
+		t.Errorf("operator span attributes %v", attributes)
+	}
+
+	// the output carries the trace on to the next operator, under this one
+	h, err := NewFrameReader(strings.NewReader(out)).Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "00-" + upper.TraceID + "-" + upper.SpanID + "-01"; h.TraceParent != want || h.TraceID != upper.TraceID {
+		t.Errorf("output header trace %s, traceparent %s, want %s", h.TraceID, h.TraceParent, want)
+	}
+}
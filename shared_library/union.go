@@ -0,0 +1,209 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/expr-lang/expr/vm/runtime"
+)
+
+// UnionOptions controls how UnionByName reconciles two schemas.
+type UnionOptions struct {
+	// AllowMissingColumns adds columns that exist on only one side,
+	// filled with nil on the other side.
+	AllowMissingColumns bool
+	// PromoteTypes converts columns with different but compatible types to
+	// a common type: integers to floats or decimals, decimals to floats and
+	// anything to string.
+	PromoteTypes bool
+}
+
+// SchemaMismatchError lists every difference that prevented two schemas
+// from being combined.
+type SchemaMismatchError struct {
+	Differences []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return "schemas do not match:\n  " + strings.Join(e.Differences, "\n  ")
+}
+
+// typeFamily groups the CSV, Parquet and Go type names used in FieldType.
+func typeFamily(fieldType string) string {
+	switch fieldType {
+	case "int", "INT32", "INT64":
+		return "int"
+	case "float", "FLOAT", "DOUBLE":
+		return "float"
+	case "string", "BYTE_ARRAY", "FIXED_LEN_BYTE_ARRAY":
+		return "string"
+	}
+	return fieldType
+}
+
+// promoteFields returns the field both a and b can be converted to.
+// The name and position of a are kept.
+func promoteFields(a, b Field) (Field, bool) {
+	if a.FieldType == b.FieldType {
+		if a.FieldType == DecimalType {
+			a.Scale = max(a.Scale, b.Scale)
+			a.Precision = max(a.Precision-a.Scale, b.Precision-b.Scale) + a.Scale
+		}
+		return a, true
+	}
+	fa, fb := typeFamily(a.FieldType), typeFamily(b.FieldType)
+	switch {
+	case fa == "string" && fb == "string":
+		a.FieldType = "string"
+		return a, true
+	case fa == fb:
+		// INT32 and FLOAT widen to the 64 bit types.
+		if a.FieldType == "INT32" || a.FieldType == "FLOAT" {
+			a.FieldType = b.FieldType
+		}
+		return a, true
+	case fa == "string":
+		return a, true
+	case fb == "string":
+		a.FieldType = "string"
+		return a, true
+	case fa == "float" && (fb == "int" || fb == DecimalType):
+		return a, true
+	case fb == "float" && (fa == "int" || fa == DecimalType):
+		a.FieldType = b.FieldType
+		return a, true
+	case fa == DecimalType && fb == "int":
+		return a, true
+	case fb == DecimalType && fa == "int":
+		a.FieldType, a.Precision, a.Scale = b.FieldType, b.Precision, b.Scale
+		return a, true
+	}
+	return a, false
+}
+
+// promoteValue converts a value to the type of a field chosen by
+// promoteFields.
+func promoteValue(v any, f Field) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch f.FieldType {
+	case "string":
+		if _, ok := v.(string); ok {
+			return v, nil
+		}
+		return fmt.Sprint(v), nil
+	case DecimalType:
+		dec, err := ToDecimal(v)
+		if err != nil {
+			return nil, err
+		}
+		return dec.Rescale(f.Scale)
+	}
+	if dec, ok := v.(Decimal); ok {
+		v = dec.Float64()
+	}
+	switch f.FieldType {
+	case "int":
+		return runtime.ToInt(v), nil
+	case "INT32":
+		return int32(runtime.ToInt(v)), nil
+	case "INT64":
+		return runtime.ToInt64(v), nil
+	case "float", "DOUBLE":
+		return runtime.ToFloat64(v), nil
+	case "FLOAT":
+		return float32(runtime.ToFloat64(v)), nil
+	}
+	return v, nil
+}
+
+// unionSchema works out the schema of UnionByName and, for every output
+// column, the position of the column in d and in otherDF (-1 when missing).
+func unionSchema(d, otherDF *DataFrame, opts UnionOptions) ([]Field, [][2]int, error) {
+	fields := make([]Field, 0)
+	positions := make([][2]int, 0)
+	differences := make([]string, 0)
+	for i, f := range d.Schema.Fields {
+		x := otherDF.GetFieldNumber(f.FieldName)
+		if x < 0 {
+			if !opts.AllowMissingColumns {
+				differences = append(differences, fmt.Sprintf("- %s (%s): missing in other frame", f.FieldName, f.FieldType))
+			}
+			fields = append(fields, f)
+			positions = append(positions, [2]int{i, -1})
+			continue
+		}
+		other := otherDF.Schema.Fields[x]
+		promoted, ok := promoteFields(f, other)
+		if promoted.FieldType != f.FieldType || promoted.FieldType != other.FieldType {
+			if !ok {
+				differences = append(differences, fmt.Sprintf("~ %s: %s cannot be combined with %s", f.FieldName, f.FieldType, other.FieldType))
+			} else if !opts.PromoteTypes {
+				differences = append(differences, fmt.Sprintf("~ %s: %s differs from %s (promotable to %s)", f.FieldName, f.FieldType, other.FieldType, promoted.FieldType))
+			}
+		}
+		fields = append(fields, promoted)
+		positions = append(positions, [2]int{i, x})
+	}
+	for i, f := range otherDF.Schema.Fields {
+		if d.GetFieldNumber(f.FieldName) >= 0 {
+			continue
+		}
+		if !opts.AllowMissingColumns {
+			differences = append(differences, fmt.Sprintf("+ %s (%s): missing in this frame", f.FieldName, f.FieldType))
+		}
+		fields = append(fields, f)
+		positions = append(positions, [2]int{-1, i})
+	}
+	if len(differences) > 0 {
+		return nil, nil, &SchemaMismatchError{Differences: differences}
+	}
+	for i := range fields {
+		fields[i].FieldPosition = i
+	}
+	return fields, positions, nil
+}
+
+// UnionByName combines the rows of the current DataFrame with those of
+// another DataFrame, matching columns by name rather than by position.
+// Columns of otherDF that are not in the current DataFrame are appended.
+// Returns a *SchemaMismatchError listing all differences that opts does
+// not allow.
+func (d *DataFrame) UnionByName(otherDF *DataFrame, opts UnionOptions) (*DataFrame, error) {
+	fields, positions, err := unionSchema(d, otherDF, opts)
+	if err != nil {
+		return nil, err
+	}
+	sides := [2]*DataFrame{d, otherDF}
+	rows := [2]int{d.GetNumberOfRows(), otherDF.GetNumberOfRows()}
+	newData := make([]*Data, len(fields))
+	for i, f := range fields {
+		column := make(Data, 0, rows[0]+rows[1])
+		for side, df := range sides {
+			x := positions[i][side]
+			if x < 0 {
+				column = append(column, make(Data, rows[side])...)
+				continue
+			}
+			for _, v := range df.Data.getColumn(x) {
+				value, err := promoteValue(v, f)
+				if err != nil {
+					return nil, fmt.Errorf("column %s: %w", f.FieldName, err)
+				}
+				column = append(column, value)
+			}
+		}
+		newData[i] = &column
+	}
+	return &DataFrame{
+		Schema: Schema{
+			Fields: fields,
+		},
+		Data: &InternalDataStructure{
+			Data:    newData,
+			Rows:    rows[0] + rows[1],
+			Columns: len(newData),
+		},
+	}, nil
+}
@@ -0,0 +1,121 @@
+package sharedlibrary
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnionByName(t *testing.T) {
+	a := newTestFrame(t, "id:int,amount:int", []string{"id", "amount", "note"}, []string{"1", "10", "x"})
+	b := newTestFrame(t, "id:int,amount:float", []string{"amount", "id", "extra"}, []string{"2.5", "2", "y"})
+
+	df, err := a.UnionByName(b, UnionOptions{AllowMissingColumns: true, PromoteTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := df.GetFieldNames(), []string{"id", "amount", "note", "extra"}; !slices.Equal(got, want) {
+		t.Fatalf("names = %v, want %v", got, want)
+	}
+	if got, want := df.GetFieldTypes(), []string{"int", "float", "string", "string"}; !slices.Equal(got, want) {
+		t.Errorf("types = %v, want %v", got, want)
+	}
+	for name, want := range map[string][]string{
+		"id":     {"1", "2"},
+		"amount": {"10", "2.5"},
+		"note":   {"x", "<nil>"},
+		"extra":  {"<nil>", "y"},
+	} {
+		if got := columnStrings(t, df, name); !slices.Equal(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	if v, _ := df.GetPositionValue(1, 0); v != 10.0 {
+		t.Errorf("promoted amount = %#v, want float64 10", v)
+	}
+}
+
+func TestUnionByNamePromotion(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		typ   string
+		value []string
+	}{
+		{"v:decimal(6,2)", "v:int", "decimal(6,2)", []string{"1.50", "2.00"}},
+		{"v:decimal(4,1)", "v:decimal(6,3)", "decimal(6,3)", []string{"1.500", "2.000"}},
+		{"v:decimal(6,2)", "v:float", "float", []string{"1.5", "2"}},
+		{"v:int", "v:string", "string", []string{"2", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			first := "1.5"
+			if strings.HasSuffix(tt.a, "int") {
+				first = "2"
+			}
+			a := newTestFrame(t, tt.a, []string{"v"}, []string{first})
+			b := newTestFrame(t, tt.b, []string{"v"}, []string{"2"})
+			df, err := a.UnionByName(b, UnionOptions{PromoteTypes: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := df.Schema.Fields[0].TypeSpec(); got != tt.typ {
+				t.Errorf("type = %s, want %s", got, tt.typ)
+			}
+			if got := columnStrings(t, df, "v"); !slices.Equal(got, tt.value) {
+				t.Errorf("values = %v, want %v", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestUnionByNameMismatch(t *testing.T) {
+	a := newTestFrame(t, "id:int", []string{"id", "note"}, []string{"1", "x"})
+	b := newTestFrame(t, "id:float", []string{"id", "extra"}, []string{"2", "y"})
+
+	_, err := a.UnionByName(b, UnionOptions{})
+	var mismatch *SchemaMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("err = %v, want a *SchemaMismatchError", err)
+	}
+	want := []string{
+		"~ id: int differs from float (promotable to float)",
+		"- note (string): missing in other frame",
+		"+ extra (string): missing in this frame",
+	}
+	if !slices.Equal(mismatch.Differences, want) {
+		t.Errorf("differences = %q, want %q", mismatch.Differences, want)
+	}
+
+	a.Schema.Fields[0].FieldType = "time.Time"
+	_, err = a.UnionByName(b, UnionOptions{AllowMissingColumns: true, PromoteTypes: true})
+	if err == nil || !strings.Contains(err.Error(), "id: time.Time cannot be combined with float") {
+		t.Errorf("err = %v, want time.Time cannot be combined with float", err)
+	}
+}
+
+func TestUnionAll(t *testing.T) {
+	a := newTestFrame(t, "id:int", []string{"id", "name"}, []string{"1", "anna"})
+	b := newTestFrame(t, "id:int", []string{"id", "name"}, []string{"2", "bob"})
+	df, err := a.UnionAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := columnStrings(t, df, "name"), []string{"anna", "bob"}; !slices.Equal(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+
+	c := newTestFrame(t, "", []string{"name", "id"}, []string{"carl", "3"})
+	_, err = a.UnionAll(c)
+	var mismatch *SchemaMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("err = %v, want a *SchemaMismatchError", err)
+	}
+	want := []string{
+		"~ column 0: name id differs from name",
+		"~ column 1: name name differs from id",
+	}
+	if !slices.Equal(mismatch.Differences, want) {
+		t.Errorf("differences = %q, want %q", mismatch.Differences, want)
+	}
+}
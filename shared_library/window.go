@@ -0,0 +1,359 @@
+package sharedlibrary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window kinds of a WindowSpec.
+const (
+	TumblingWindow = "tumbling"
+	SlidingWindow  = "sliding"
+	SessionWindow  = "session"
+)
+
+// timeLayouts are the layouts tried on event times given as strings when
+// a WindowSpec has no TimeLayout.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"01/02/2006 03:04:05 PM",
+	"01/02/2006 15:04:05",
+	"2006-01-02",
+}
+
+// WindowSpec describes windows on the event time in Column.
+//
+//   - tumbling: consecutive windows of Size, aligned on the Unix epoch
+//   - sliding: windows of Size starting every Slide, a row is in every
+//     window that covers it
+//   - session: a window per value of the keys that lasts as long as its rows
+//     are less than Gap apart, it ends Gap after its last row
+//
+// The watermark is the latest event time seen less Lateness. A window is
+// closed once the watermark passes its end, rows that arrive for closed
+// windows only are late and dropped.
+type WindowSpec struct {
+	Kind     string
+	Column   string
+	Size     time.Duration
+	Slide    time.Duration
+	Gap      time.Duration
+	Lateness time.Duration
+	// TimeLayout is the layout of event times given as strings, see
+	// time.Parse. Empty tries RFC 3339 and a few common layouts, numbers are
+	// seconds since the Unix epoch.
+	TimeLayout string
+	Keys       []string
+	Aggs       []Aggregation
+}
+
+// window is an open window and the rows it has collected.
+type window struct {
+	start, end time.Time
+	key        string
+	rows       [][]any
+}
+
+// Windower aggregates a stream of frames by windows of event time, see
+// WindowSpec. Add returns the windows every frame closed and Close the
+// windows left open at the end of the stream.
+type Windower struct {
+	spec      WindowSpec
+	schema    *Schema
+	output    []Field
+	keys      []int
+	open      []*window
+	watermark time.Time
+	seen      bool
+	// Dropped counts the rows that were late or had no event time.
+	Dropped int
+}
+
+// NewWindower checks a WindowSpec and returns a Windower for it.
+func NewWindower(spec WindowSpec) (*Windower, error) {
+	if spec.Column == "" {
+		return nil, errors.New("windows need an event time column")
+	}
+	switch spec.Kind {
+	case TumblingWindow:
+		spec.Slide = spec.Size
+	case SlidingWindow:
+		if spec.Slide <= 0 {
+			return nil, errors.New("sliding windows need a positive slide")
+		}
+	case SessionWindow:
+		if spec.Gap <= 0 {
+			return nil, errors.New("session windows need a positive gap")
+		}
+	default:
+		return nil, fmt.Errorf("unknown window kind %q, use tumbling, sliding or session", spec.Kind)
+	}
+	if spec.Kind != SessionWindow && spec.Size <= 0 {
+		return nil, fmt.Errorf("%s windows need a positive size", spec.Kind)
+	}
+	if spec.Lateness < 0 {
+		return nil, errors.New("lateness can not be negative")
+	}
+	if len(spec.Aggs) == 0 {
+		return nil, errors.New("windows need at least one aggregation")
+	}
+	for _, agg := range spec.Aggs {
+		if _, err := newAggregator(agg.Function); err != nil {
+			return nil, err
+		}
+	}
+	return &Windower{spec: spec}, nil
+}
+
+// eventTime converts the value of the event time column.
+func (w *Windower) eventTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case int:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case int64:
+		return time.Unix(t, 0).UTC(), nil
+	case float64:
+		return time.UnixMilli(int64(t * 1000)).UTC(), nil
+	case string:
+		s := strings.TrimSpace(t)
+		if w.spec.TimeLayout != "" {
+			return time.Parse(w.spec.TimeLayout, s)
+		}
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, s); err == nil {
+				return parsed, nil
+			}
+		}
+		if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+			return time.UnixMilli(int64(seconds * 1000)).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%v is not a time", v)
+}
+
+// closed reports whether the watermark has passed the end of a window.
+func (w *Windower) closed(end time.Time) bool {
+	return w.seen && !end.After(w.watermark)
+}
+
+// Add adds the rows of a frame to their windows and returns one aggregated
+// frame for every window the frame closed, in order of their end.
+func (w *Windower) Add(d *DataFrame) ([]*DataFrame, error) {
+	if w.schema == nil {
+		fields, err := groupByFields(&d.Schema, w.spec.Keys, w.spec.Aggs)
+		if err != nil {
+			return nil, err
+		}
+		// every window has the same schema, whatever its rows
+		w.output = []Field{
+			{FieldName: "window_start", FieldType: "string"},
+			{FieldName: "window_end", FieldType: "string"},
+		}
+		for _, f := range fields {
+			f.FieldPosition = len(w.output)
+			w.output = append(w.output, f)
+		}
+		w.output[1].FieldPosition = 1
+		w.schema = &Schema{Fields: slices.Clone(d.Schema.Fields)}
+		w.keys = make([]int, len(w.spec.Keys))
+		for i, key := range w.spec.Keys {
+			w.keys[i] = d.GetFieldNumber(key)
+		}
+	} else if err := w.schema.MatchByPosition(&d.Schema); err != nil {
+		return nil, err
+	}
+	column := d.GetFieldNumber(w.spec.Column)
+	if column < 0 {
+		return nil, fmt.Errorf("event time column '%s' not found in DataFrame", w.spec.Column)
+	}
+
+	latest := w.watermark.Add(w.spec.Lateness)
+	for i := 0; i < d.GetNumberOfRows(); i++ {
+		row := d.getRow(i)
+		if *row[column] == nil {
+			w.Dropped++
+			continue
+		}
+		t, err := w.eventTime(*row[column])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s: %w", i, w.spec.Column, err)
+		}
+		// the watermark moves with every row, so that which rows are late
+		// does not depend on how the stream is split into frames
+		if !w.seen || t.After(latest) {
+			latest = t
+		}
+		w.seen = true
+		w.watermark = latest.Add(-w.spec.Lateness)
+		values := make([]any, len(row))
+		for j, v := range row {
+			values[j] = *v
+		}
+		if !w.assign(t, values) {
+			w.Dropped++
+		}
+	}
+	return w.emit(func(win *window) bool { return w.closed(win.end) })
+}
+
+// assign adds a row to the open windows it belongs to, it returns false when
+// they are all closed.
+func (w *Windower) assign(t time.Time, values []any) bool {
+	if w.spec.Kind == SessionWindow {
+		return w.assignSession(t, values)
+	}
+	assigned := false
+	slide := int64(w.spec.Slide)
+	first := t.UnixNano() - int64(w.spec.Size) + 1
+	start := first - ((first%slide)+slide)%slide
+	if start < first {
+		start += slide
+	}
+	for ; start <= t.UnixNano(); start += slide {
+		from := time.Unix(0, start).UTC()
+		to := from.Add(w.spec.Size)
+		if w.closed(to) {
+			continue
+		}
+		i := slices.IndexFunc(w.open, func(win *window) bool { return win.start.Equal(from) })
+		if i < 0 {
+			w.open = append(w.open, &window{start: from, end: to})
+			i = len(w.open) - 1
+		}
+		w.open[i].rows = append(w.open[i].rows, values)
+		assigned = true
+	}
+	return assigned
+}
+
+// assignSession adds a row to the session of its keys it is less than Gap
+// away from, merging the sessions it connects, or starts a new session.
+// Sessions closed by the frame being added are done, as if emitted.
+func (w *Windower) assignSession(t time.Time, values []any) bool {
+	key := ""
+	if len(w.keys) > 0 {
+		row := make(Row, len(values))
+		for i := range values {
+			row[i] = &values[i]
+		}
+		key = rowKey(row, w.keys)
+	}
+	session := &window{start: t, end: t.Add(w.spec.Gap), key: key, rows: [][]any{values}}
+	// a merged session can reach sessions the row did not
+	for merged := true; merged; {
+		merged = false
+		others := w.open[:0]
+		for _, win := range w.open {
+			if win.key == key && !w.closed(win.end) && session.start.Before(win.end) && win.start.Before(session.end) {
+				session.start = minTime(session.start, win.start)
+				session.end = maxTime(session.end, win.end)
+				session.rows = append(win.rows, session.rows...)
+				merged = true
+				continue
+			}
+			others = append(others, win)
+		}
+		w.open = others
+	}
+	if w.closed(session.end) {
+		return false
+	}
+	w.open = append(w.open, session)
+	return true
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Close returns the windows that are still open, at the end of the stream.
+func (w *Windower) Close() ([]*DataFrame, error) {
+	return w.emit(func(*window) bool { return true })
+}
+
+// emit aggregates and removes the open windows done selects.
+func (w *Windower) emit(done func(*window) bool) ([]*DataFrame, error) {
+	ready := make([]*window, 0)
+	open := w.open[:0]
+	for _, win := range w.open {
+		if done(win) {
+			ready = append(ready, win)
+		} else {
+			open = append(open, win)
+		}
+	}
+	w.open = open
+	slices.SortStableFunc(ready, func(a, b *window) int {
+		if c := a.end.Compare(b.end); c != 0 {
+			return c
+		}
+		return a.start.Compare(b.start)
+	})
+	frames := make([]*DataFrame, 0, len(ready))
+	for _, win := range ready {
+		df, err := w.aggregate(win)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, df)
+	}
+	return frames, nil
+}
+
+// aggregate groups the rows of a window by the keys and prepends its start
+// and end, as RFC 3339 strings, in the schema Add worked out.
+func (w *Windower) aggregate(win *window) (*DataFrame, error) {
+	fields := slices.Clone(w.schema.Fields)
+	data := make([]*Data, len(fields))
+	for i := range data {
+		column := make(Data, len(win.rows))
+		for j, row := range win.rows {
+			column[j] = row[i]
+		}
+		data[i] = &column
+	}
+	rows := &DataFrame{
+		Schema: Schema{Fields: fields},
+		Data:   &InternalDataStructure{Data: data, Rows: len(win.rows), Columns: len(data)},
+	}
+	df, err := rows.groupBy(context.Background(), w.spec.Keys, w.spec.Aggs...)
+	if err != nil {
+		return nil, err
+	}
+	n := df.GetNumberOfRows()
+	result := &DataFrame{
+		Schema: Schema{Fields: slices.Clone(w.output)},
+		Data:   &InternalDataStructure{Data: make([]*Data, 0, len(w.output)), Rows: n},
+	}
+	for _, bound := range []time.Time{win.start, win.end} {
+		column := make(Data, n)
+		for i := range column {
+			column[i] = bound.Format(time.RFC3339Nano)
+		}
+		result.Data.addColumn(column)
+	}
+	for i := range df.Schema.Fields {
+		result.Data.addColumn(df.Data.getColumn(i))
+	}
+	return result, nil
+}
@@ -0,0 +1,171 @@
+package sharedlibrary
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// runWindows adds events, seconds after midnight with a key, to a Windower
+// in frames of batch rows and returns the windows it emitted as lines of
+// start, end, key and count, and the number of dropped rows.
+func runWindows(t *testing.T, spec WindowSpec, batch int, events ...string) ([]string, int) {
+	t.Helper()
+	spec.Column = "ts"
+	spec.Aggs = []Aggregation{{Column: "ts", Function: "count", Alias: "n"}}
+	w, err := NewWindower(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]string, 0)
+	collect := func(frames []*DataFrame, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, df := range frames {
+			starts, ends, counts := columnStrings(t, df, "window_start"), columnStrings(t, df, "window_end"), columnStrings(t, df, "n")
+			keys := make([]string, len(starts))
+			if df.GetFieldNumber("key") >= 0 {
+				keys = columnStrings(t, df, "key")
+			}
+			for i := range starts {
+				lines = append(lines, fmt.Sprintf("%s-%s %s %s", clock(t, starts[i]), clock(t, ends[i]), keys[i], counts[i]))
+			}
+		}
+	}
+	midnight := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < len(events); i += batch {
+		records := make([][]string, 0, batch)
+		for _, e := range events[i:min(i+batch, len(events))] {
+			var seconds int
+			var key string
+			fmt.Sscanf(e, "%d %s", &seconds, &key)
+			records = append(records, []string{midnight.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339), key})
+		}
+		collect(w.Add(NewDataFrameFromRecords([]string{"ts", "key"}, records)))
+	}
+	collect(w.Close())
+	return lines, w.Dropped
+}
+
+// clock returns the time of day of an RFC 3339 time as m:ss, negative
+// before midnight.
+func clock(t *testing.T, s string) string {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := at.Sub(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	return fmt.Sprintf("%s%d:%02d", sign, int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func TestWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    WindowSpec
+		events  []string
+		want    []string
+		dropped int
+	}{
+		{
+			name:    "tumbling with a late row",
+			spec:    WindowSpec{Kind: TumblingWindow, Size: time.Minute},
+			events:  []string{"5 a", "70 a", "20 a", "90 a"},
+			want:    []string{"0:00-1:00  1", "1:00-2:00  2"},
+			dropped: 1,
+		},
+		{
+			name:   "tumbling within the lateness",
+			spec:   WindowSpec{Kind: TumblingWindow, Size: time.Minute, Lateness: 30 * time.Second},
+			events: []string{"5 a", "70 a", "20 a", "95 a"},
+			want:   []string{"0:00-1:00  2", "1:00-2:00  2"},
+		},
+		{
+			name:   "sliding",
+			spec:   WindowSpec{Kind: SlidingWindow, Size: time.Minute, Slide: 30 * time.Second},
+			events: []string{"10 a", "40 a"},
+			want:   []string{"-0:30-0:30  1", "0:00-1:00  2", "0:30-1:30  1"},
+		},
+		{
+			name:   "sessions by key",
+			spec:   WindowSpec{Kind: SessionWindow, Gap: 30 * time.Second, Keys: []string{"key"}},
+			events: []string{"0 a", "10 b", "20 a", "100 a"},
+			want:   []string{"0:10-0:40 b 1", "0:00-0:50 a 2", "1:40-2:10 a 1"},
+		},
+		{
+			name:    "late row of a closed session",
+			spec:    WindowSpec{Kind: SessionWindow, Gap: 30 * time.Second},
+			events:  []string{"0 a", "100 a", "20 a"},
+			want:    []string{"0:00-0:30  1", "1:40-2:10  1"},
+			dropped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the windows do not depend on how the stream is split into frames
+			for batch := 1; batch <= len(tt.events); batch++ {
+				got, dropped := runWindows(t, tt.spec, batch, tt.events...)
+				if !slices.Equal(got, tt.want) || dropped != tt.dropped {
+					t.Errorf("in frames of %d rows: windows %q and %d dropped, want %q and %d", batch, got, dropped, tt.want, tt.dropped)
+				}
+			}
+		})
+	}
+}
+
+func TestWindowSpecErrors(t *testing.T) {
+	aggs := []Aggregation{{Column: "ts", Function: "count"}}
+	for _, spec := range []WindowSpec{
+		{Kind: TumblingWindow, Size: time.Minute, Aggs: aggs},
+		{Kind: "hopping", Column: "ts", Size: time.Minute, Aggs: aggs},
+		{Kind: SlidingWindow, Column: "ts", Size: time.Minute, Aggs: aggs},
+		{Kind: SessionWindow, Column: "ts", Aggs: aggs},
+		{Kind: TumblingWindow, Column: "ts", Size: time.Minute, Lateness: -time.Second, Aggs: aggs},
+		{Kind: TumblingWindow, Column: "ts", Size: time.Minute},
+		{Kind: TumblingWindow, Column: "ts", Size: time.Minute, Aggs: []Aggregation{{Column: "ts", Function: "median"}}},
+	} {
+		if _, err := NewWindower(spec); err == nil {
+			t.Errorf("NewWindower(%+v) succeeded", spec)
+		}
+	}
+}
+
+func TestWindowSchema(t *testing.T) {
+	w, err := NewWindower(WindowSpec{Kind: TumblingWindow, Column: "ts", Size: time.Minute, Aggs: []Aggregation{
+		{Column: "fare", Function: "sum"}, {Column: "fare", Function: "mean"}, {Column: "*", Function: "count"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first window only has missing fares, the second none
+	d := newTestFrame(t, "fare:decimal(6,2)", []string{"ts", "fare"},
+		[]string{"2024-01-01T00:00:10Z", ""}, []string{"2024-01-01T00:01:10Z", "2.50"})
+	frames, err := w.Add(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames = append(frames, rest...)
+	if len(frames) != 2 {
+		t.Fatalf("%d windows, want 2", len(frames))
+	}
+	if err := frames[0].Schema.MatchByPosition(&frames[1].Schema); err != nil {
+		t.Errorf("windows have different schemas: %v", err)
+	}
+	if _, err := frames[0].UnionAll(frames[1]); err != nil {
+		t.Errorf("UnionAll of the windows: %v", err)
+	}
+	if got, want := frames[1].GetFieldTypes(), []string{"string", "string", DecimalType, DecimalType, "int"}; !slices.Equal(got, want) {
+		t.Errorf("window types = %v, want %v", got, want)
+	}
+}
@@ -83,15 +83,10 @@ func main() {
 	d1.Transform("map(race			, upper(#))")
 	d1.Transform("map(sex			, lower(#))")
 	d1.Transform("map(education		, lower(#))")
-	d1.RenameColumn("native.country", "native_country")
-	d1.RenameColumn("capital.loss", "capital_loss")
-	d1.RenameColumn("capital.gain", "capital_gain")
-	d1.RenameColumn("marital.status", "martial_status")
-	d1.RenameColumn("hours.per.week", "hours_per_week")
-	d1.RenameColumn("education.num", "education_num")
+	d1.NormalizeColumnNames()
 	d1.Transform("map(native_country, upper(#))")
 	d1.Transform("map(hours_per_week, int(#))")
-	d1.Transform("map(martial_status, upper(#))")
+	d1.Transform("map(marital_status, upper(#))")
 	d1.Transform("map(capital_loss	, int(#))")
 	d1.Transform("map(capital_gain	, int(#))")
 	d1.Transform("map(education_num	, int(#))")
@@ -125,6 +120,9 @@ func main() {
 	fmt.Fprintln(os.Stderr, df4.Count())
 	df4.Transform("map(Vendor_id, int(#))")
 	vendors := df4.Distinct("Vendor_id")
+	if err := df4.CreateIndex("Vendor_id", lib.HashIndex); err != nil {
+		log.Fatal(err)
+	}
 	frames := make([]*lib.DataFrame, len(vendors))
 	for i, v := range vendors {
 		s := fmt.Sprint("Vendor_id", " == ", v)
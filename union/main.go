@@ -0,0 +1,15 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/magpierre/operators/operators/ops"
+	lib "github.com/magpierre/operators/shared_library"
+)
+
+func main() {
+	if err := lib.RunOperator(&ops.Union{}, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}